@@ -17,7 +17,8 @@ public class ReportsController(ISendRequestService sendRequestService) : Control
     public async Task<ActionResult<ApiResponse<List<SprintReportDto>>>> GetSprintsReports(
         Guid? managerId = null,
         DateTime? startDate = null,
-        DateTime? endDate = null)
+        DateTime? endDate = null,
+        int? version = null)
     {
         var queryParameters = new List<string>();
 
@@ -27,6 +28,8 @@ public class ReportsController(ISendRequestService sendRequestService) : Control
             queryParameters.Add($"startDate={startDate.Value:yyyy-MM-dd}");
         if (endDate.HasValue)
             queryParameters.Add($"endDate={endDate.Value:yyyy-MM-dd}");
+        if (version.HasValue)
+            queryParameters.Add($"version={version.Value}");
 
         var queryString = queryParameters.Count != 0 ? "?" + string.Join("&", queryParameters) : string.Empty;
 
@@ -44,7 +47,8 @@ public class ReportsController(ISendRequestService sendRequestService) : Control
     public async Task<ActionResult<ApiResponse<List<TeamReportDto>>>> GetTeamsReports(
         Guid? managerId = null,
         DateTime? startDate = null,
-        DateTime? endDate = null)
+        DateTime? endDate = null,
+        int? version = null)
     {
         var queryParameters = new List<string>();
 
@@ -54,6 +58,8 @@ public class ReportsController(ISendRequestService sendRequestService) : Control
             queryParameters.Add($"startDate={startDate.Value:yyyy-MM-dd}");
         if (endDate.HasValue)
             queryParameters.Add($"endDate={endDate.Value:yyyy-MM-dd}");
+        if (version.HasValue)
+            queryParameters.Add($"version={version.Value}");
 
         var queryString = queryParameters.Count != 0 ? "?" + string.Join("&", queryParameters) : string.Empty;
 
@@ -71,7 +77,8 @@ public class ReportsController(ISendRequestService sendRequestService) : Control
     public async Task<ActionResult<ApiResponse<List<ProjectReportDto>>>> GetProjectsReports(
         Guid? managerId = null,
         DateTime? startDate = null,
-        DateTime? endDate = null)
+        DateTime? endDate = null,
+        int? version = null)
     {
         var queryParameters = new List<string>();
 
@@ -81,6 +88,8 @@ public class ReportsController(ISendRequestService sendRequestService) : Control
             queryParameters.Add($"startDate={startDate.Value:yyyy-MM-dd}");
         if (endDate.HasValue)
             queryParameters.Add($"endDate={endDate.Value:yyyy-MM-dd}");
+        if (version.HasValue)
+            queryParameters.Add($"version={version.Value}");
 
         var queryString = queryParameters.Count != 0 ? "?" + string.Join("&", queryParameters) : string.Empty;
 
@@ -11,6 +11,10 @@ public class ProjectReportDto
     [JsonPropertyName("taskCount")] public int TaskCount { get; set; }
     [JsonPropertyName("taskCountCompleted")] public int TaskCountCompleted { get; set; }
     [JsonPropertyName("totalTaskTime")] public string TotalTaskTime { get; set; }
+    [JsonPropertyName("totalTaskTimeSeconds"), JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public double? TotalTaskTimeSeconds { get; set; }
+    [JsonPropertyName("totalTaskTimeIso"), JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public string? TotalTaskTimeIso { get; set; }
     [JsonPropertyName("projectStartDate")] public DateTime ProjectStartDate { get; set; }
     [JsonPropertyName("projectEndDate")] public DateTime ProjectEndDate { get; set; }
     [JsonPropertyName("completedRatio")] public float? CompletedRatio { get; set; }
@@ -9,5 +9,9 @@ public class SprintReportDto
     [JsonPropertyName("taskCount")] public int TaskCount { get; set; }
     [JsonPropertyName("taskCountCompleted")] public int TaskCountCompleted { get; set; }
     [JsonPropertyName("totalTaskTime")] public string TotalTaskTime { get; set; } = string.Empty;
+    [JsonPropertyName("totalTaskTimeSeconds"), JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public double? TotalTaskTimeSeconds { get; set; }
+    [JsonPropertyName("totalTaskTimeIso"), JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public string? TotalTaskTimeIso { get; set; }
     [JsonPropertyName("completedRatio")] public float? CompletedRatio { get; set; }
 }
\ No newline at end of file
@@ -10,4 +10,8 @@ public class TeamReportDto
     [JsonPropertyName("taskCount")] public int TaskCount { get; set; }
     [JsonPropertyName("taskCountCompleted")] public int TaskCountCompleted { get; set; }
     [JsonPropertyName("totalTaskTime")] public string TotalTaskTime { get; set; }
+    [JsonPropertyName("totalTaskTimeSeconds"), JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public double? TotalTaskTimeSeconds { get; set; }
+    [JsonPropertyName("totalTaskTimeIso"), JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public string? TotalTaskTimeIso { get; set; }
 }
\ No newline at end of file
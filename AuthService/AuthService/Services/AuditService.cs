@@ -1,4 +1,5 @@
 using RabbitMQ.Client;
+using System.Diagnostics;
 using System.Text;
 using System.Text.Json;
 
@@ -39,9 +40,24 @@ public class AuditService(IConnection connection, ILogger<AuditService> logger)
             var json = JsonSerializer.Serialize(auditMessage);
             var body = Encoding.UTF8.GetBytes(json);
 
+            // The request's trace, which ASP.NET Core continues from an incoming
+            // traceparent, with a new span id so consumers join the same trace.
+            var traceId = Activity.Current?.TraceId ?? ActivityTraceId.CreateRandom();
+            var sampled = Activity.Current == null || Activity.Current.Recorded;
+            var properties = new BasicProperties
+            {
+                ContentType = "application/json",
+                Headers = new Dictionary<string, object?>
+                {
+                    ["traceparent"] = $"00-{traceId}-{ActivitySpanId.CreateRandom()}-{(sampled ? "01" : "00")}"
+                }
+            };
+
             await channel.BasicPublishAsync(
                 exchange: "audit_logs",
                 routingKey: "audit.authservice",
+                mandatory: false,
+                basicProperties: properties,
                 body: body
             );
 
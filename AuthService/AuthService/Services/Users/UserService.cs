@@ -140,7 +140,7 @@ public class UserService(AppDbContext context, IConfiguration configuration, IAu
 
     public async Task<Result<List<UserResponse>>> GetAllUsersByRole(string role)
     {
-        List<string> roles = ["admin", "manager", "developer"];
+        List<string> roles = ["admin", "manager", "developer", "auditor"];
         if (!roles.Contains(role))
         {
             return Result<List<UserResponse>>.NotFound("Unknown role");
@@ -170,7 +170,7 @@ public class UserService(AppDbContext context, IConfiguration configuration, IAu
             return Result<UserResponse?>.NotFound("User not found");
         }
 
-        List<string> roles = ["admin", "manager", "developer"];
+        List<string> roles = ["admin", "manager", "developer", "auditor"];
         var user = await context.Users.FindAsync(id);
 
         if (request.Username != null)
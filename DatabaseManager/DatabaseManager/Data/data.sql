@@ -33,6 +33,7 @@
                 public."TaskHistories"
         ,
                 public."Tasks",
+                public."TaskSprintChanges",
                 public."Sprints",
                 public."Teams",
                 public."Users",
@@ -645,3 +646,7 @@ VALUES
         WHERE "Name" = 'Task 12'), '2025-01-24 18:00:00', (SELECT "Name"
         FROM public."TaskStatuses"
         WHERE "Name" = 'Created'), '');
+
+-- The seeded tasks have backdated history; drop the sprint changes their
+-- inserts recorded so reports count them from that history instead.
+DELETE FROM public."TaskSprintChanges";
@@ -0,0 +1,94 @@
+﻿using System;
+using Microsoft.EntityFrameworkCore.Migrations;
+using Npgsql.EntityFrameworkCore.PostgreSQL.Metadata;
+
+#nullable disable
+
+namespace DatabaseManager.Migrations
+{
+    /// <inheritdoc />
+    public partial class TaskSprintChanges : Migration
+    {
+        /// <inheritdoc />
+        protected override void Up(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.CreateTable(
+                name: "TaskSprintChanges",
+                columns: table => new
+                {
+                    Id = table.Column<long>(type: "bigint", nullable: false)
+                        .Annotation("Npgsql:ValueGenerationStrategy", NpgsqlValueGenerationStrategy.IdentityByDefaultColumn),
+                    TaskId = table.Column<Guid>(type: "uuid", nullable: false),
+                    OldSprintId = table.Column<Guid>(type: "uuid", nullable: true),
+                    NewSprintId = table.Column<Guid>(type: "uuid", nullable: true),
+                    ChangeDate = table.Column<DateTime>(type: "timestamp with time zone", nullable: false)
+                },
+                constraints: table =>
+                {
+                    table.PrimaryKey("PK_TaskSprintChanges", x => x.Id);
+                });
+
+            migrationBuilder.CreateIndex(
+                name: "IX_TaskSprintChanges_NewSprintId",
+                table: "TaskSprintChanges",
+                column: "NewSprintId");
+
+            migrationBuilder.CreateIndex(
+                name: "IX_TaskSprintChanges_OldSprintId",
+                table: "TaskSprintChanges",
+                column: "OldSprintId");
+
+            migrationBuilder.CreateIndex(
+                name: "IX_TaskSprintChanges_TaskId",
+                table: "TaskSprintChanges",
+                column: "TaskId");
+
+            // Tasks are moved between sprints by several services, so the
+            // change is recorded in the database rather than by each writer.
+            // Creating a task in a sprint is recorded as a move in from no
+            // sprint, so it enters the sprint's scope when it was created.
+            migrationBuilder.Sql("""
+                CREATE OR REPLACE FUNCTION RecordTaskSprintChange()
+                    RETURNS trigger
+                    LANGUAGE plpgsql
+                AS
+                $$
+                BEGIN
+                    IF TG_OP = 'INSERT' THEN
+                        IF NEW."SprintId" IS NOT NULL THEN
+                            INSERT INTO "TaskSprintChanges" ("TaskId", "OldSprintId", "NewSprintId", "ChangeDate")
+                            VALUES (NEW."Id", NULL, NEW."SprintId", now());
+                        END IF;
+                    ELSIF TG_OP = 'UPDATE' THEN
+                        IF NEW."SprintId" IS DISTINCT FROM OLD."SprintId" THEN
+                            INSERT INTO "TaskSprintChanges" ("TaskId", "OldSprintId", "NewSprintId", "ChangeDate")
+                            VALUES (NEW."Id", OLD."SprintId", NEW."SprintId", now());
+                        END IF;
+                    ELSIF OLD."SprintId" IS NOT NULL THEN
+                        INSERT INTO "TaskSprintChanges" ("TaskId", "OldSprintId", "NewSprintId", "ChangeDate")
+                        VALUES (OLD."Id", OLD."SprintId", NULL, now());
+                    END IF;
+                    RETURN NULL;
+                END;
+                $$;
+
+                CREATE TRIGGER "TR_Tasks_SprintChange"
+                    AFTER INSERT OR DELETE OR UPDATE OF "SprintId" ON "Tasks"
+                    FOR EACH ROW
+                EXECUTE FUNCTION RecordTaskSprintChange();
+                """);
+        }
+
+        /// <inheritdoc />
+        protected override void Down(MigrationBuilder migrationBuilder)
+        {
+            migrationBuilder.Sql("""
+                DROP TRIGGER IF EXISTS "TR_Tasks_SprintChange" ON "Tasks";
+                DROP FUNCTION IF EXISTS RecordTaskSprintChange();
+                """);
+
+            migrationBuilder.DropTable(
+                name: "TaskSprintChanges");
+        }
+    }
+}
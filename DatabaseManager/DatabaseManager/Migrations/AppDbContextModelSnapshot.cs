@@ -202,6 +202,37 @@ namespace DatabaseManager.Migrations
                     b.ToTable("TaskHistories");
                 });
 
+            modelBuilder.Entity("SharedObjects.Models.TaskSprintChange", b =>
+                {
+                    b.Property<long>("Id")
+                        .ValueGeneratedOnAdd()
+                        .HasColumnType("bigint");
+
+                    NpgsqlPropertyBuilderExtensions.UseIdentityByDefaultColumn(b.Property<long>("Id"));
+
+                    b.Property<DateTime>("ChangeDate")
+                        .HasColumnType("timestamp with time zone");
+
+                    b.Property<Guid?>("NewSprintId")
+                        .HasColumnType("uuid");
+
+                    b.Property<Guid?>("OldSprintId")
+                        .HasColumnType("uuid");
+
+                    b.Property<Guid>("TaskId")
+                        .HasColumnType("uuid");
+
+                    b.HasKey("Id");
+
+                    b.HasIndex("NewSprintId");
+
+                    b.HasIndex("OldSprintId");
+
+                    b.HasIndex("TaskId");
+
+                    b.ToTable("TaskSprintChanges");
+                });
+
             modelBuilder.Entity("SharedObjects.Models.TaskStatus", b =>
                 {
                     b.Property<int>("Id")
@@ -320,6 +351,13 @@ namespace DatabaseManager.Migrations
                     b.UseTphMappingStrategy();
                 });
 
+            modelBuilder.Entity("SharedObjects.Models.Auditor", b =>
+                {
+                    b.HasBaseType("SharedObjects.Models.User");
+
+                    b.HasDiscriminator().HasValue("auditor");
+                });
+
             modelBuilder.Entity("SharedObjects.Models.Developer", b =>
                 {
                     b.HasBaseType("SharedObjects.Models.User");
@@ -15,6 +15,7 @@ public class AppDbContext(DbContextOptions<AppDbContext> options) : DbContext(op
     public DbSet<TaskType> TaskTypes { get; set; }
     public DbSet<Team> Teams { get; set; }
     public DbSet<AuditLog> AuditLogs { get; set; }
+    public DbSet<TaskSprintChange> TaskSprintChanges { get; set; }
 
     protected override void OnModelCreating(ModelBuilder modelBuilder)
     {
@@ -27,7 +28,12 @@ public class AppDbContext(DbContextOptions<AppDbContext> options) : DbContext(op
             .HasDiscriminator<string>("Role")
             .HasValue<User>("admin")
             .HasValue<Developer>("developer")
-            .HasValue<Manager>("manager");
+            .HasValue<Manager>("manager")
+            .HasValue<Auditor>("auditor");
+
+        modelBuilder.Entity<TaskSprintChange>().HasIndex(c => c.TaskId);
+        modelBuilder.Entity<TaskSprintChange>().HasIndex(c => c.OldSprintId);
+        modelBuilder.Entity<TaskSprintChange>().HasIndex(c => c.NewSprintId);
 
         base.OnModelCreating(modelBuilder);
     }
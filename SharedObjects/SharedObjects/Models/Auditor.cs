@@ -0,0 +1,7 @@
+﻿namespace SharedObjects.Models;
+
+// Can read task histories, including the live stream, without managing a
+// team.
+public class Auditor : User
+{
+}
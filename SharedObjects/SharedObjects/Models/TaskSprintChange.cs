@@ -0,0 +1,16 @@
+﻿using System.ComponentModel.DataAnnotations;
+
+namespace SharedObjects.Models;
+
+// Written by a trigger on "Tasks" whenever a task is created in a sprint,
+// moves between sprints or is deleted. TaskId has no foreign key so rows
+// outlive the task.
+public class TaskSprintChange
+{
+    [Key]
+    public long Id { get; set; }
+    public Guid TaskId { get; set; }
+    public Guid? OldSprintId { get; set; }
+    public Guid? NewSprintId { get; set; }
+    public DateTime ChangeDate { get; set; }
+}
@@ -14,6 +14,7 @@ from models import Tasks, TaskHistories, TaskStatuses, Sprints, Users, \
     Teams, Projects, SprintCreate, SprintUpdate
 from rabbit_mq_config import RabbitMQConfig
 from audit_service import audit_service
+from tracing import start_request, end_request
 
 app = FastAPI()
 
@@ -27,6 +28,15 @@ def check_rabbitmq_connection():
     except Exception:
         return False
 
+@app.middleware("http")
+async def propagate_trace(request: Request, call_next):
+    tokens = start_request(request.headers.get("traceparent"), request.headers.get("tracestate"))
+    try:
+        return await call_next(request)
+    finally:
+        end_request(tokens)
+
+
 @app.middleware("http")
 async def log_requests(request: Request, call_next):
     print(f"\n{'='*50}")
@@ -2,6 +2,8 @@ import pika
 import json
 import os
 from typing import Optional
+
+from tracing import trace_headers
 from datetime import datetime
 
 
@@ -64,7 +66,7 @@ class AuditService:
                 exchange='audit_logs',
                 routing_key='audit.fastapiservice',
                 body=json.dumps(audit_message),
-                properties=pika.BasicProperties(delivery_mode=2)
+                properties=pika.BasicProperties(delivery_mode=2, headers=trace_headers())
             )
             
             print(f"Audit log sent: {action} - {entity} - {description}")
@@ -3,6 +3,8 @@ import json
 import os
 from typing import Optional
 
+from tracing import trace_headers
+
 
 class RabbitMQConfig:
 
@@ -50,5 +52,5 @@ class RabbitMQConfig:
             exchange='',
             routing_key='task_queue',
             body=json.dumps(message),
-            properties=pika.BasicProperties(delivery_mode=2)
+            properties=pika.BasicProperties(delivery_mode=2, headers=trace_headers())
         )
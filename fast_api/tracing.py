@@ -0,0 +1,44 @@
+import os
+import re
+from contextvars import ContextVar
+from typing import Optional
+
+# W3C trace context (https://www.w3.org/TR/trace-context/) for the messages
+# this service publishes, so the Go consumers continue the caller's trace.
+_TRACEPARENT = re.compile(r"^00-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$")
+
+_trace_id: ContextVar[Optional[str]] = ContextVar("trace_id", default=None)
+_trace_flags: ContextVar[str] = ContextVar("trace_flags", default="01")
+_tracestate: ContextVar[Optional[str]] = ContextVar("tracestate", default=None)
+
+
+def start_request(traceparent: Optional[str], tracestate: Optional[str]):
+    """Remembers the caller's trace for the current request, or starts a new
+    one when the request carries no valid traceparent."""
+    match = _TRACEPARENT.match((traceparent or "").strip().lower())
+    if match and match.group(1) != "0" * 32 and match.group(2) != "0" * 16:
+        trace_id, flags = match.group(1), match.group(3)
+    else:
+        trace_id, flags, tracestate = os.urandom(16).hex(), "01", None
+    return (
+        _trace_id.set(trace_id),
+        _trace_flags.set(flags),
+        _tracestate.set(tracestate),
+    )
+
+
+def end_request(tokens):
+    _trace_id.reset(tokens[0])
+    _trace_flags.reset(tokens[1])
+    _tracestate.reset(tokens[2])
+
+
+def trace_headers() -> dict:
+    """Returns AMQP headers carrying the current trace with a new span id,
+    starting a trace when called outside a request."""
+    trace_id = _trace_id.get() or os.urandom(16).hex()
+    headers = {"traceparent": f"00-{trace_id}-{os.urandom(8).hex()}-{_trace_flags.get()}"}
+    tracestate = _tracestate.get()
+    if tracestate:
+        headers["tracestate"] = tracestate
+    return headers
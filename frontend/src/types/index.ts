@@ -6,7 +6,7 @@ export interface ApiResponse<T> {
 export interface User {
     id: string;
     username: string;
-    role: 'admin' | 'manager' | 'developer';
+    role: 'admin' | 'manager' | 'developer' | 'auditor';
 }
 
 export interface AuthResponse {
@@ -20,7 +20,7 @@ export interface LoginCredentials {
 }
 
 export interface RegisterCredentials extends LoginCredentials {
-    role: 'admin' | 'manager' | 'developer' | '';
+    role: 'admin' | 'manager' | 'developer' | 'auditor' | '';
     email: string;
     firstName: string;
     lastName: string;
@@ -194,7 +194,7 @@ export interface DeveloperTask {
 
 export interface UpdateUser {
     username: string | null;
-    role: 'admin' | 'manager' | 'developer' | null;
+    role: 'admin' | 'manager' | 'developer' | 'auditor' | null;
     email: string | null;
     firstName: string | null;
     lastName: string | null;
@@ -207,6 +207,9 @@ export interface TeamReportDto {
     taskCount: number;
     taskCountCompleted: number;
     totalTaskTime: string;
+    // Only present when the report is requested with version=2
+    totalTaskTimeSeconds?: number;
+    totalTaskTimeIso?: string;
 }
 
 export interface SprintReportDto {
@@ -215,6 +218,9 @@ export interface SprintReportDto {
     taskCount: number;
     taskCountCompleted: number;
     totalTaskTime: string;
+    // Only present when the report is requested with version=2
+    totalTaskTimeSeconds?: number;
+    totalTaskTimeIso?: string;
     completedRatio: number;
 }
 
@@ -226,6 +232,9 @@ export interface ProjectReportDto {
     taskCount: number;
     taskCountCompleted: number;
     totalTaskTime: string;
+    // Only present when the report is requested with version=2
+    totalTaskTimeSeconds?: number;
+    totalTaskTimeIso?: string;
     projectStartDate: Date;
     projectEndDate: Date;
     completedRatio: number;
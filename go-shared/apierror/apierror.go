@@ -0,0 +1,133 @@
+// Package apierror defines the error envelope returned by the Go HTTP
+// services.
+//
+// Every error response has the same shape:
+//
+//	{
+//	  "message": "Invalid query parameters",
+//	  "error": {
+//	    "code": "invalid_argument",
+//	    "message": "Invalid query parameters",
+//	    "details": [{"field": "taskId", "issue": "not a UUID: abc"}]
+//	  }
+//	}
+//
+// The top level message is kept so clients that only read ApiResponse.Message
+// keep working. Internal errors never carry the underlying error text; it is
+// logged together with a reference that is returned to the client instead.
+package apierror
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+// Codes used in Error.Code.
+const (
+	CodeInvalidArgument = "invalid_argument"
+	CodeUnauthenticated = "unauthenticated"
+	CodeForbidden       = "permission_denied"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeInternal        = "internal"
+	CodeUnavailable     = "unavailable"
+)
+
+// Detail describes one problem with the request, usually one parameter.
+type Detail struct {
+	Field string `json:"field,omitempty"`
+	Issue string `json:"issue"`
+}
+
+// Error is the error object of the envelope. Status is the HTTP status the
+// error is sent with.
+type Error struct {
+	Status    int      `json:"-"`
+	Code      string   `json:"code"`
+	Message   string   `json:"message"`
+	Details   []Detail `json:"details,omitempty"`
+	Reference string   `json:"reference,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Envelope is the response body for an error.
+type Envelope struct {
+	Message string `json:"message"`
+	Error   *Error `json:"error"`
+}
+
+// Envelope wraps e in the response body.
+func (e *Error) Envelope() Envelope {
+	return Envelope{Message: e.Message, Error: e}
+}
+
+// Field returns a Detail for the named parameter.
+func Field(field, issue string) Detail {
+	return Detail{Field: field, Issue: issue}
+}
+
+// InvalidArgument is a 400 for a malformed request.
+func InvalidArgument(message string, details ...Detail) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidArgument, Message: message, Details: details}
+}
+
+// Unauthenticated is a 401 for a missing or invalid token.
+func Unauthenticated(message string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthenticated, Message: message}
+}
+
+// Forbidden is a 403 for a caller whose role does not allow the request.
+func Forbidden(message string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Message: message}
+}
+
+// NotFound is a 404.
+func NotFound(message string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: message}
+}
+
+// Conflict is a 409, e.g. for a request that contradicts the current state.
+func Conflict(message string, details ...Detail) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: message, Details: details}
+}
+
+// Internal is a 500. cause is logged with a random reference and only the
+// reference and message are sent to the client.
+func Internal(message string, cause error) *Error {
+	ref := newReference()
+	log.Printf("%s [ref %s]: %v", message, ref, cause)
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Reference: ref}
+}
+
+// Validation collects details so a handler can report every bad parameter in
+// one response instead of stopping at the first.
+type Validation struct {
+	details []Detail
+}
+
+// Add records a problem with field.
+func (v *Validation) Add(field, issue string) {
+	v.details = append(v.details, Field(field, issue))
+}
+
+// Err returns an InvalidArgument error with the collected details, or nil
+// when nothing was added.
+func (v *Validation) Err(message string) *Error {
+	if len(v.details) == 0 {
+		return nil
+	}
+	return InvalidArgument(message, v.details...)
+}
+
+func newReference() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
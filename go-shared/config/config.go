@@ -0,0 +1,198 @@
+// Package config loads service settings from environment variables.
+//
+// A Loader records every key it is asked for, whether the value came from
+// the environment, an alias or the default, and any value that failed to
+// parse. Services read all of their settings up front, log Report and abort
+// on Validate so misconfiguration shows up at startup rather than on the
+// first request that needs it.
+//
+// An empty variable is treated the same as an unset one. docker-compose
+// passes ${VAR} through as an empty string when it is missing from .env, and
+// falling back to the default is what every service wants in that case.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type source string
+
+const (
+	sourceEnv     source = "env"
+	sourceAlias   source = "alias"
+	sourceDefault source = "default"
+	sourceMissing source = "missing"
+)
+
+type entry struct {
+	key    string
+	value  string
+	source source
+	alias  string
+	secret bool
+}
+
+type Loader struct {
+	lookup  func(string) (string, bool)
+	aliases map[string][]string
+	entries map[string]entry
+	errs    []error
+}
+
+func NewLoader() *Loader {
+	return &Loader{
+		lookup:  os.LookupEnv,
+		aliases: make(map[string][]string),
+		entries: make(map[string]entry),
+	}
+}
+
+// Alias makes key fall back to the deprecated variable old when key itself is
+// not set, e.g. Alias("DB_USERNAME", "DB_USER").
+func (l *Loader) Alias(key, old string) *Loader {
+	l.aliases[key] = append(l.aliases[key], old)
+	return l
+}
+
+func (l *Loader) get(key string) (string, source, string) {
+	if value, ok := l.lookup(key); ok && value != "" {
+		return value, sourceEnv, ""
+	}
+	for _, alias := range l.aliases[key] {
+		if value, ok := l.lookup(alias); ok && value != "" {
+			return value, sourceAlias, alias
+		}
+	}
+	return "", sourceMissing, ""
+}
+
+func (l *Loader) record(e entry) {
+	l.entries[e.key] = e
+}
+
+func (l *Loader) String(key, defaultValue string) string {
+	value, src, alias := l.get(key)
+	if src == sourceMissing {
+		value, src = defaultValue, sourceDefault
+	}
+	l.record(entry{key: key, value: value, source: src, alias: alias})
+	return value
+}
+
+// Secret behaves like String but masks the value in Report.
+func (l *Loader) Secret(key, defaultValue string) string {
+	value := l.String(key, defaultValue)
+	e := l.entries[key]
+	e.secret = true
+	l.record(e)
+	return value
+}
+
+// Required returns the value of key and records a validation error when it
+// is not set.
+func (l *Loader) Required(key string) string {
+	value, src, alias := l.get(key)
+	if src == sourceMissing {
+		l.errs = append(l.errs, fmt.Errorf("%s is required", key))
+	}
+	l.record(entry{key: key, value: value, source: src, alias: alias})
+	return value
+}
+
+// RequiredSecret behaves like Required but masks the value in Report.
+func (l *Loader) RequiredSecret(key string) string {
+	value := l.Required(key)
+	e := l.entries[key]
+	e.secret = true
+	l.record(e)
+	return value
+}
+
+func (l *Loader) Int(key string, defaultValue int) int {
+	raw, src, alias := l.get(key)
+	if src == sourceMissing {
+		l.record(entry{key: key, value: strconv.Itoa(defaultValue), source: sourceDefault})
+		return defaultValue
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not an integer", key, raw))
+		value = defaultValue
+	}
+	l.record(entry{key: key, value: raw, source: src, alias: alias})
+	return value
+}
+
+func (l *Loader) Bool(key string, defaultValue bool) bool {
+	raw, src, alias := l.get(key)
+	if src == sourceMissing {
+		l.record(entry{key: key, value: strconv.FormatBool(defaultValue), source: sourceDefault})
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a boolean", key, raw))
+		value = defaultValue
+	}
+	l.record(entry{key: key, value: raw, source: src, alias: alias})
+	return value
+}
+
+func (l *Loader) Duration(key string, defaultValue time.Duration) time.Duration {
+	raw, src, alias := l.get(key)
+	if src == sourceMissing {
+		l.record(entry{key: key, value: defaultValue.String(), source: sourceDefault})
+		return defaultValue
+	}
+	value, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a duration", key, raw))
+		value = defaultValue
+	}
+	l.record(entry{key: key, value: raw, source: src, alias: alias})
+	return value
+}
+
+// Invalid records a setting that parsed but is out of range, so it is
+// reported by Validate together with the other configuration errors.
+func (l *Loader) Invalid(key, format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+// Validate returns every missing or malformed setting seen so far.
+func (l *Loader) Validate() error {
+	return errors.Join(l.errs...)
+}
+
+// Report writes one line per setting, sorted by key, noting where each value
+// came from. Secrets are masked.
+func (l *Loader) Report(logf func(format string, args ...any)) {
+	keys := make([]string, 0, len(l.entries))
+	for key := range l.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	logf("Configuration (%d settings):", len(keys))
+	for _, key := range keys {
+		e := l.entries[key]
+		value := e.value
+		if e.secret && value != "" {
+			value = "******"
+		}
+		switch e.source {
+		case sourceAlias:
+			logf("  %-28s = %-24q (from deprecated %s)", key, value, e.alias)
+		case sourceMissing:
+			logf("  %-28s   MISSING", key)
+		default:
+			logf("  %-28s = %-24q (%s)", key, value, e.source)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Package csvcell prepares values for CSV files that are opened in
+// spreadsheets.
+package csvcell
+
+import "strings"
+
+// formulaPrefixes are the leading characters that make spreadsheets read a
+// cell as a formula.
+const formulaPrefixes = "=+-@"
+
+// Text returns s with an apostrophe in front when a spreadsheet would read
+// it as a formula, so names such as =HYPERLINK(...) show up as typed. Use it
+// for free text only; numbers such as -1 would stop being numbers.
+func Text(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
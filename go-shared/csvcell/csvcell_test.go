@@ -0,0 +1,20 @@
+package csvcell
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := map[string]string{
+		"":                  "",
+		"plain":             "plain",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-1":                "'-1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"a=b":               "a=b",
+	}
+	for in, want := range tests {
+		if got := Text(in); got != want {
+			t.Errorf("Text(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package database opens the Postgres connection pool shared by the Go
+// services through GORM.
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
+
+	"go-shared/config"
+	"go-shared/health"
+	"go-shared/retry"
+)
+
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+type Config struct {
+	Host     string
+	Port     string
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+	Pool     PoolConfig
+	Connect  retry.Backoff
+}
+
+// LoadConfig reads the DB_* variables. DB_USER is still accepted for
+// DB_USERNAME because the gin services used to be configured with it.
+func LoadConfig(l *config.Loader) Config {
+	l.Alias("DB_USERNAME", "DB_USER")
+	cfg := Config{
+		Host:     l.String("DB_HOST", "database"),
+		Port:     l.String("DB_PORT", "5432"),
+		Name:     l.String("DB_DATABASE", "project"),
+		User:     l.String("DB_USERNAME", "postgres"),
+		Password: l.Secret("DB_PASSWORD", "password"),
+		SSLMode:  l.String("DB_SSLMODE", "disable"),
+		Pool: PoolConfig{
+			MaxOpenConns:    l.Int("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    l.Int("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: l.Duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime: l.Duration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		},
+		Connect: retry.LoadBackoff(l, "DB"),
+	}
+
+	if cfg.Pool.MaxOpenConns < 1 {
+		l.Invalid("DB_MAX_OPEN_CONNS", "must be at least 1, got %d", cfg.Pool.MaxOpenConns)
+	}
+	if cfg.Pool.MaxIdleConns < 0 || cfg.Pool.MaxIdleConns > cfg.Pool.MaxOpenConns {
+		l.Invalid("DB_MAX_IDLE_CONNS", "must be between 0 and DB_MAX_OPEN_CONNS (%d), got %d",
+			cfg.Pool.MaxOpenConns, cfg.Pool.MaxIdleConns)
+	}
+	return cfg
+}
+
+func (c Config) DSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=UTC",
+		c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
+}
+
+// Open connects to Postgres, retrying with exponential backoff until
+// cfg.Connect.Deadline while the database is still starting, then applies the pool settings and registers the OpenTelemetry GORM plugin.
+// gormConfig may be nil.
+func Open(ctx context.Context, cfg Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	if gormConfig == nil {
+		gormConfig = &gorm.Config{}
+	}
+	if gormConfig.Logger == nil {
+		gormConfig.Logger = logger.Default.LogMode(logger.Info)
+	}
+
+	log.Printf("Connecting to database: %s@%s:%s/%s", cfg.User, cfg.Host, cfg.Port, cfg.Name)
+
+	var db *gorm.DB
+	err := retry.WithBackoff(ctx, "postgres", cfg.Connect, func(ctx context.Context) error {
+		conn, err := gorm.Open(postgres.New(postgres.Config{
+			DriverName: "pgx",
+			DSN:        cfg.DSN(),
+		}), gormConfig)
+		if err != nil {
+			return err
+		}
+		sqlDB, err := conn.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			_ = sqlDB.Close()
+			return err
+		}
+		db = conn
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+		return nil, fmt.Errorf("register tracing plugin: %w", err)
+	}
+
+	log.Printf("Connected to database successfully (max open %d, max idle %d, lifetime %s, idle time %s)",
+		cfg.Pool.MaxOpenConns, cfg.Pool.MaxIdleConns, cfg.Pool.ConnMaxLifetime, cfg.Pool.ConnMaxIdleTime)
+	return db, nil
+}
+
+func HealthCheck(db *gorm.DB) health.Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// PoolStats mirrors sql.DBStats with JSON names matching the health output.
+type PoolStats struct {
+	MaxOpenConnections int     `json:"maxOpenConnections"`
+	OpenConnections    int     `json:"openConnections"`
+	InUse              int     `json:"inUse"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"waitCount"`
+	WaitDurationMs     float64 `json:"waitDurationMs"`
+	MaxIdleClosed      int64   `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64   `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64   `json:"maxLifetimeClosed"`
+}
+
+// Stats returns a health.Details reporting the current pool usage. It reads
+// sql.DB counters only and never touches the network.
+func Stats(db *gorm.DB) health.Details {
+	return func() any {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil
+		}
+		s := sqlDB.Stats()
+		return PoolStats{
+			MaxOpenConnections: s.MaxOpenConnections,
+			OpenConnections:    s.OpenConnections,
+			InUse:              s.InUse,
+			Idle:               s.Idle,
+			WaitCount:          s.WaitCount,
+			WaitDurationMs:     float64(s.WaitDuration.Microseconds()) / 1000,
+			MaxIdleClosed:      s.MaxIdleClosed,
+			MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  s.MaxLifetimeClosed,
+		}
+	}
+}
+
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,81 @@
+// Package duration converts Postgres intervals to exact Go durations and
+// formats durations for API responses.
+//
+// Version 1 responses keep each service's legacy representation. Clients
+// that ask for version 2 also get the duration as a number of seconds and as
+// an ISO-8601 string, computed here so both services agree.
+package duration
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version2 is the first API version whose responses carry numeric and
+// ISO-8601 durations.
+const Version2 = 2
+
+// RequestVersion returns the response version a client asked for, either
+// with ?version=N or with a version parameter on the Accept header
+// ("application/json; version=2"). It is 1 when neither is given or valid.
+func RequestVersion(r *http.Request) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get("version")); err == nil && v > 0 {
+		return v
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			_, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			if v, err := strconv.Atoi(params["version"]); err == nil && v > 0 {
+				return v
+			}
+		}
+	}
+	return 1
+}
+
+// Seconds returns d in seconds with microsecond precision, the resolution
+// Postgres stores intervals at.
+func Seconds(d time.Duration) float64 {
+	return float64(d.Round(time.Microsecond)) / float64(time.Second)
+}
+
+// ISO8601 formats d as an ISO-8601 duration using hours, minutes and
+// seconds only, e.g. "PT26H3M4.5S" or "-PT0.25S". Days are not used because
+// their length is ambiguous in ISO-8601.
+func ISO8601(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+
+	if hours > 0 {
+		b.WriteString(strconv.FormatInt(int64(hours), 10))
+		b.WriteByte('H')
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.FormatInt(int64(minutes), 10))
+		b.WriteByte('M')
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+		b.WriteByte('S')
+	}
+	return b.String()
+}
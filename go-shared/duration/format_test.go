@@ -0,0 +1,61 @@
+package duration
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestISO8601(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{time.Second, "PT1S"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{time.Microsecond, "PT0.000001S"},
+		{90 * time.Minute, "PT1H30M"},
+		{26*time.Hour + 3*time.Minute + 4500*time.Millisecond, "PT26H3M4.5S"},
+		{-250 * time.Millisecond, "-PT0.25S"},
+		{-(2*time.Hour + time.Second), "-PT2H1S"},
+	}
+	for _, tt := range tests {
+		if got := ISO8601(tt.in); got != tt.want {
+			t.Errorf("ISO8601(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	if got := Seconds(90*time.Minute + 1500*time.Microsecond); got != 5400.0015 {
+		t.Fatalf("Seconds = %v, want 5400.0015", got)
+	}
+}
+
+func TestRequestVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   int
+	}{
+		{"default", "/api/reports/sprints", "", 1},
+		{"query", "/api/reports/sprints?version=2", "", 2},
+		{"accept parameter", "/api/reports/sprints", "application/json; version=2", 2},
+		{"accept list", "/api/reports/sprints", "text/html, application/json;version=2;q=0.9", 2},
+		{"query wins", "/api/reports/sprints?version=1", "application/json; version=2", 1},
+		{"invalid", "/api/reports/sprints?version=two", "application/json", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := RequestVersion(r); got != tt.want {
+				t.Fatalf("RequestVersion = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
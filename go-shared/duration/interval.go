@@ -0,0 +1,379 @@
+package duration
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	microsPerSecond = int64(time.Second / time.Microsecond)
+	microsPerMinute = 60 * microsPerSecond
+	microsPerHour   = 60 * microsPerMinute
+	microsPerDay    = 24 * microsPerHour
+
+	// Same factors EXTRACT(EPOCH FROM interval) uses: a year is 365.25
+	// days and any remaining month is 30 days.
+	microsPerYear  = 36525 * microsPerDay / 100
+	microsPerMonth = 30 * microsPerDay
+)
+
+// Interval scans a Postgres interval column into pgx's native interval
+// type. pgx normally decodes intervals from the binary protocol, but the
+// text it hands to database/sql, and anything read over the simple protocol,
+// can be in any of the server's IntervalStyle formats: postgres,
+// postgres_verbose, sql_standard and iso_8601. All of them are accepted.
+type Interval struct {
+	pgtype.Interval
+}
+
+func (i *Interval) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		i.Interval = pgtype.Interval{}
+		return nil
+	case string:
+		return i.scanText(v)
+	case []byte:
+		return i.scanText(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Interval", src)
+	}
+}
+
+func (i *Interval) scanText(s string) error {
+	parsed, err := ParseInterval(s)
+	if err != nil {
+		return err
+	}
+	i.Interval = parsed
+	return nil
+}
+
+// Duration converts the interval to an exact time.Duration using the same
+// month and year lengths as EXTRACT(EPOCH ...). A NULL interval is zero.
+func (i Interval) Duration() (time.Duration, error) {
+	if !i.Valid {
+		return 0, nil
+	}
+
+	years := int64(i.Months) / 12
+	months := int64(i.Months) % 12
+
+	// Each term fits in an int64 of microseconds, but their sum may not fit
+	// in a time.Duration, so check the magnitude before converting.
+	approx := float64(years)*float64(microsPerYear) +
+		float64(months)*float64(microsPerMonth) +
+		float64(i.Days)*float64(microsPerDay) +
+		float64(i.Microseconds)
+	if math.Abs(approx) >= float64(math.MaxInt64/int64(time.Microsecond)) {
+		return 0, fmt.Errorf("interval %d mons %d days %d us is out of range for a duration",
+			i.Months, i.Days, i.Microseconds)
+	}
+
+	micros := years*microsPerYear + months*microsPerMonth + int64(i.Days)*microsPerDay + i.Microseconds
+	return time.Duration(micros) * time.Microsecond, nil
+}
+
+// ParseInterval decodes the text form of an interval in any IntervalStyle.
+func ParseInterval(s string) (pgtype.Interval, error) {
+	text := strings.TrimSpace(s)
+	var (
+		iv  pgtype.Interval
+		err error
+	)
+	switch {
+	case text == "":
+		err = fmt.Errorf("empty value")
+	case strings.HasPrefix(text, "@"):
+		iv, err = parseVerboseInterval(text)
+	case strings.HasPrefix(text, "P"):
+		iv, err = parseISOInterval(text)
+	case strings.IndexFunc(text, isLetter) >= 0:
+		iv, err = parsePostgresStyleInterval(text)
+	default:
+		iv, err = parseSQLStandardInterval(text)
+	}
+	if err != nil {
+		return pgtype.Interval{}, fmt.Errorf("invalid interval %q: %w", s, err)
+	}
+	iv.Valid = true
+	return iv, nil
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// parsePostgresStyleInterval handles IntervalStyle postgres, e.g.
+// "1 year 2 mons -3 days +04:05:06.5". pgx's own text encoding
+// ("14 mon 3 day 04:05:06") is a subset of it.
+func parsePostgresStyleInterval(text string) (pgtype.Interval, error) {
+	var iv pgtype.Interval
+	fields := strings.Fields(text)
+	for i := 0; i < len(fields); i++ {
+		if strings.Contains(fields[i], ":") {
+			if i != len(fields)-1 {
+				return iv, fmt.Errorf("time %q must be the last field", fields[i])
+			}
+			micros, err := parseClock(fields[i])
+			if err != nil {
+				return iv, err
+			}
+			iv.Microseconds += micros
+			continue
+		}
+		if i+1 >= len(fields) {
+			return iv, fmt.Errorf("missing unit after %q", fields[i])
+		}
+		n, err := parseInt32(fields[i])
+		if err != nil {
+			return iv, err
+		}
+		i++
+		switch fields[i] {
+		case "year", "years":
+			iv.Months += n * 12
+		case "mon", "mons":
+			iv.Months += n
+		case "day", "days":
+			iv.Days += n
+		default:
+			return iv, fmt.Errorf("unknown unit %q", fields[i])
+		}
+	}
+	return iv, nil
+}
+
+// parseVerboseInterval handles IntervalStyle postgres_verbose, e.g.
+// "@ 1 year 2 mons -3 days 4 hours 5 mins 6.5 secs ago".
+func parseVerboseInterval(text string) (pgtype.Interval, error) {
+	var iv pgtype.Interval
+	fields := strings.Fields(strings.TrimPrefix(text, "@"))
+
+	negate := false
+	if len(fields) > 0 && fields[len(fields)-1] == "ago" {
+		negate = true
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) == 1 && fields[0] == "0" {
+		return iv, nil
+	}
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return iv, fmt.Errorf("expected number and unit pairs")
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		value, unit := fields[i], fields[i+1]
+		if unit == "sec" || unit == "secs" {
+			micros, err := parseSeconds(value)
+			if err != nil {
+				return iv, err
+			}
+			iv.Microseconds += micros
+			continue
+		}
+
+		n, err := parseInt32(value)
+		if err != nil {
+			return iv, err
+		}
+		switch unit {
+		case "year", "years":
+			iv.Months += n * 12
+		case "mon", "mons":
+			iv.Months += n
+		case "day", "days":
+			iv.Days += n
+		case "hour", "hours":
+			iv.Microseconds += int64(n) * microsPerHour
+		case "min", "mins":
+			iv.Microseconds += int64(n) * microsPerMinute
+		default:
+			return iv, fmt.Errorf("unknown unit %q", unit)
+		}
+	}
+
+	if negate {
+		iv.Months, iv.Days, iv.Microseconds = -iv.Months, -iv.Days, -iv.Microseconds
+	}
+	return iv, nil
+}
+
+// parseSQLStandardInterval handles IntervalStyle sql_standard, e.g. "1-2",
+// "3 4:05:06" or, with mixed signs, "-1-2 +3 -4:05:06". A sign applies to
+// its own field only.
+func parseSQLStandardInterval(text string) (pgtype.Interval, error) {
+	var iv pgtype.Interval
+	for _, field := range strings.Fields(text) {
+		switch {
+		case strings.Contains(field, ":"):
+			micros, err := parseClock(field)
+			if err != nil {
+				return iv, err
+			}
+			iv.Microseconds += micros
+		case strings.Contains(strings.TrimLeft(field, "+-"), "-"):
+			sign, body := splitSign(field)
+			yearsText, monthsText, _ := strings.Cut(body, "-")
+			years, err := parseUnsigned(yearsText)
+			if err != nil {
+				return iv, err
+			}
+			months, err := parseUnsigned(monthsText)
+			if err != nil {
+				return iv, err
+			}
+			iv.Months += int32(sign * (years*12 + months))
+		default:
+			days, err := parseInt32(field)
+			if err != nil {
+				return iv, err
+			}
+			iv.Days += days
+		}
+	}
+	return iv, nil
+}
+
+// parseISOInterval handles IntervalStyle iso_8601, e.g. "P1Y2M3DT4H5M6.5S"
+// or "P-1Y-2M3DT-4H-5M-6S". Only seconds may be fractional, as in
+// Postgres's output.
+func parseISOInterval(text string) (pgtype.Interval, error) {
+	var iv pgtype.Interval
+	rest := strings.TrimPrefix(text, "P")
+	if rest == "" {
+		return iv, fmt.Errorf("no fields after P")
+	}
+
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime {
+				return iv, fmt.Errorf("duplicate T")
+			}
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+
+		end := strings.IndexFunc(rest, isLetter)
+		if end <= 0 {
+			return iv, fmt.Errorf("expected number before designator in %q", rest)
+		}
+		value, designator := rest[:end], rest[end]
+		rest = rest[end+1:]
+
+		if inTime && designator == 'S' {
+			micros, err := parseSeconds(value)
+			if err != nil {
+				return iv, err
+			}
+			iv.Microseconds += micros
+			continue
+		}
+
+		n, err := parseInt32(value)
+		if err != nil {
+			return iv, err
+		}
+		switch {
+		case !inTime && designator == 'Y':
+			iv.Months += n * 12
+		case !inTime && designator == 'M':
+			iv.Months += n
+		case !inTime && designator == 'W':
+			iv.Days += n * 7
+		case !inTime && designator == 'D':
+			iv.Days += n
+		case inTime && designator == 'H':
+			iv.Microseconds += int64(n) * microsPerHour
+		case inTime && designator == 'M':
+			iv.Microseconds += int64(n) * microsPerMinute
+		default:
+			return iv, fmt.Errorf("unexpected designator %q", designator)
+		}
+	}
+	return iv, nil
+}
+
+// parseClock parses [+-]h:mm:ss[.ffffff]. Hours may exceed 24.
+func parseClock(field string) (int64, error) {
+	sign, body := splitSign(field)
+	parts := strings.Split(body, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("time %q is not h:mm:ss", field)
+	}
+	hours, err := parseUnsigned(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := parseUnsigned(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := parseSeconds(parts[2])
+	if err != nil {
+		return 0, err
+	}
+	if minutes >= 60 || seconds < 0 || seconds >= microsPerMinute {
+		return 0, fmt.Errorf("time %q is out of range", field)
+	}
+	return sign * (hours*microsPerHour + minutes*microsPerMinute + seconds), nil
+}
+
+// parseSeconds parses a signed decimal number of seconds with at most
+// microsecond precision.
+func parseSeconds(value string) (int64, error) {
+	sign, body := splitSign(value)
+	whole, frac, hasFrac := strings.Cut(body, ".")
+	seconds, err := parseUnsigned(whole)
+	if err != nil {
+		return 0, err
+	}
+	var micros int64
+	if hasFrac {
+		if frac == "" || len(frac) > 6 {
+			return 0, fmt.Errorf("seconds %q must have 1 to 6 fractional digits", value)
+		}
+		micros, err = parseUnsigned(frac + strings.Repeat("0", 6-len(frac)))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return sign * (seconds*microsPerSecond + micros), nil
+}
+
+func splitSign(value string) (int64, string) {
+	switch {
+	case strings.HasPrefix(value, "-"):
+		return -1, value[1:]
+	case strings.HasPrefix(value, "+"):
+		return 1, value[1:]
+	default:
+		return 1, value
+	}
+}
+
+func parseUnsigned(value string) (int64, error) {
+	if value == "" || strings.ContainsAny(value, "+-") {
+		return 0, fmt.Errorf("%q is not an unsigned number", value)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number: %w", value, err)
+	}
+	return n, nil
+}
+
+func parseInt32(value string) (int32, error) {
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number: %w", value, err)
+	}
+	return int32(n), nil
+}
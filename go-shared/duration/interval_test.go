@@ -0,0 +1,225 @@
+package duration
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func interval(months, days int32, micros int64) pgtype.Interval {
+	return pgtype.Interval{Months: months, Days: days, Microseconds: micros, Valid: true}
+}
+
+// Each case is one interval value as Postgres renders it under every
+// IntervalStyle (SET intervalstyle = ...; SELECT value::interval).
+var intervalStyleCases = []struct {
+	name        string
+	want        pgtype.Interval
+	postgres    string
+	verbose     string
+	sqlStandard string
+	iso8601     string
+}{
+	{
+		name:        "zero",
+		want:        interval(0, 0, 0),
+		postgres:    "00:00:00",
+		verbose:     "@ 0",
+		sqlStandard: "0",
+		iso8601:     "PT0S",
+	},
+	{
+		name:        "year-month",
+		want:        interval(14, 0, 0),
+		postgres:    "1 year 2 mons",
+		verbose:     "@ 1 year 2 mons",
+		sqlStandard: "1-2",
+		iso8601:     "P1Y2M",
+	},
+	{
+		name:        "day-time",
+		want:        interval(0, 3, 4*microsPerHour+5*microsPerMinute+6*microsPerSecond),
+		postgres:    "3 days 04:05:06",
+		verbose:     "@ 3 days 4 hours 5 mins 6 secs",
+		sqlStandard: "3 4:05:06",
+		iso8601:     "P3DT4H5M6S",
+	},
+	{
+		name:        "all fields positive",
+		want:        interval(14, 3, 4*microsPerHour+5*microsPerMinute+6*microsPerSecond),
+		postgres:    "1 year 2 mons 3 days 04:05:06",
+		verbose:     "@ 1 year 2 mons 3 days 4 hours 5 mins 6 secs",
+		sqlStandard: "+1-2 +3 +4:05:06",
+		iso8601:     "P1Y2M3DT4H5M6S",
+	},
+	{
+		name:        "mixed signs",
+		want:        interval(-14, 3, -(4*microsPerHour + 5*microsPerMinute + 6*microsPerSecond)),
+		postgres:    "-1 years -2 mons +3 days -04:05:06",
+		verbose:     "@ 1 year 2 mons -3 days 4 hours 5 mins 6 secs ago",
+		sqlStandard: "-1-2 +3 -4:05:06",
+		iso8601:     "P-1Y-2M3DT-4H-5M-6S",
+	},
+	{
+		name:        "single day",
+		want:        interval(0, 1, 0),
+		postgres:    "1 day",
+		verbose:     "@ 1 day",
+		sqlStandard: "1 0:00:00",
+		iso8601:     "P1D",
+	},
+	{
+		name:        "negative fractional second",
+		want:        interval(0, 0, -500000),
+		postgres:    "-00:00:00.5",
+		verbose:     "@ 0.5 secs ago",
+		sqlStandard: "-0:00:00.5",
+		iso8601:     "PT-0.5S",
+	},
+	{
+		name:        "microseconds",
+		want:        interval(0, 0, microsPerSecond+1),
+		postgres:    "00:00:01.000001",
+		verbose:     "@ 1.000001 secs",
+		sqlStandard: "0:00:01.000001",
+		iso8601:     "PT1.000001S",
+	},
+	{
+		name:        "more than a day of hours",
+		want:        interval(0, 0, 100*microsPerHour),
+		postgres:    "100:00:00",
+		verbose:     "@ 100 hours",
+		sqlStandard: "100:00:00",
+		iso8601:     "PT100H",
+	},
+	{
+		name:        "negative days positive time",
+		want:        interval(0, -1, 2*microsPerHour+3*microsPerMinute),
+		postgres:    "-1 days +02:03:00",
+		verbose:     "@ 1 day -2 hours -3 mins ago",
+		sqlStandard: "-1 +2:03:00",
+		iso8601:     "P-1DT2H3M",
+	},
+}
+
+func TestParseIntervalStyles(t *testing.T) {
+	for _, tc := range intervalStyleCases {
+		styles := map[string]string{
+			"postgres":         tc.postgres,
+			"postgres_verbose": tc.verbose,
+			"sql_standard":     tc.sqlStandard,
+			"iso_8601":         tc.iso8601,
+		}
+		for style, text := range styles {
+			t.Run(tc.name+"/"+style, func(t *testing.T) {
+				got, err := ParseInterval(text)
+				if err != nil {
+					t.Fatalf("ParseInterval(%q): %v", text, err)
+				}
+				if got != tc.want {
+					t.Fatalf("ParseInterval(%q) = %+v, want %+v", text, got, tc.want)
+				}
+			})
+		}
+	}
+}
+
+func TestParseIntervalPgxText(t *testing.T) {
+	// pgx re-encodes binary intervals as "N mon N day hh:mm:ss.ffffff"
+	// before handing them to database/sql.
+	got, err := ParseInterval("14 mon 3 day -04:05:06.000789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := interval(14, 3, -(4*microsPerHour + 5*microsPerMinute + 6*microsPerSecond + 789))
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseIntervalErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"abc",
+		"1 fortnight",
+		"04:05:06 3 days",
+		"1 day 02:00",
+		"00:61:00",
+		"00:00:00.1234567",
+		"@ 1 lightyear",
+		"@ 1",
+		"P",
+		"P1X",
+		"P1.5D",
+		"PT1H2",
+		"1-x",
+		"99999999999 days",
+	}
+	for _, text := range tests {
+		t.Run(text, func(t *testing.T) {
+			if got, err := ParseInterval(text); err == nil {
+				t.Fatalf("ParseInterval(%q) = %+v, want error", text, got)
+			}
+		})
+	}
+}
+
+func TestIntervalDuration(t *testing.T) {
+	tests := []struct {
+		name string
+		in   pgtype.Interval
+		want time.Duration
+	}{
+		{"null", pgtype.Interval{}, 0},
+		{"zero", interval(0, 0, 0), 0},
+		{"time only", interval(0, 0, 90*microsPerMinute+1), 90*time.Minute + time.Microsecond},
+		{"day is 24 hours", interval(0, 1, 0), 24 * time.Hour},
+		{"month is 30 days", interval(1, 0, 0), 30 * 24 * time.Hour},
+		{"year is 365.25 days", interval(12, 0, 0), 8766 * time.Hour},
+		{"year and a month", interval(13, 0, 0), 8766*time.Hour + 720*time.Hour},
+		{"negative days positive time", interval(0, -1, 2*microsPerHour), -22 * time.Hour},
+		{"negative", interval(-1, 0, -microsPerSecond), -(720*time.Hour + time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Interval{tt.in}.Duration()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (Interval{interval(12*300, 0, 0)}).Duration(); err == nil {
+		t.Fatal("expected out of range error for 300 years")
+	}
+}
+
+func TestIntervalScan(t *testing.T) {
+	var iv Interval
+	if err := iv.Scan([]byte("P1DT1H")); err != nil {
+		t.Fatal(err)
+	}
+	if want := interval(0, 1, microsPerHour); iv.Interval != want {
+		t.Fatalf("got %+v, want %+v", iv.Interval, want)
+	}
+
+	if err := iv.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if iv.Valid {
+		t.Fatal("NULL should scan as an invalid interval")
+	}
+
+	err := iv.Scan("not an interval")
+	if err == nil || !strings.Contains(err.Error(), "not an interval") {
+		t.Fatalf("expected error naming the input, got %v", err)
+	}
+	if err := iv.Scan(42); err == nil {
+		t.Fatal("expected error scanning an int")
+	}
+}
@@ -0,0 +1,179 @@
+// Package health implements the liveness and readiness checks shared by the
+// Go services.
+//
+// Dependency checks run concurrently and their results are cached for a
+// short TTL so that frequent probes don't hammer Postgres, Redis or
+// RabbitMQ. Readiness turns false as soon as MarkShuttingDown is called so
+// load balancers stop routing to an instance that is draining.
+package health
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-shared/config"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	StatusAlive        = "alive"
+	StatusReady        = "ready"
+	StatusNotReady     = "not_ready"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check reports whether a dependency is usable. It should respect ctx.
+type Check func(ctx context.Context) error
+
+// Details returns extra, cheap-to-collect state for a dependency, such as
+// connection pool statistics. It is evaluated on every readiness probe and
+// is not cached.
+type Details func() any
+
+type Dependency struct {
+	Status      string     `json:"status"`
+	LatencyMs   float64    `json:"latencyMs"`
+	CheckedAt   time.Time  `json:"checkedAt"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+	Details     any        `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status       string                `json:"status"`
+	Dependencies map[string]Dependency `json:"dependencies"`
+}
+
+// Ready reports whether the probe should answer 200.
+func (r Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+type named struct {
+	name    string
+	check   Check
+	details Details
+}
+
+type Checker struct {
+	ttl          time.Duration
+	timeout      time.Duration
+	checks       []named
+	shuttingDown atomic.Bool
+
+	mu      sync.Mutex
+	results map[string]Dependency
+	lastRun time.Time
+}
+
+func NewChecker(ttl, timeout time.Duration) *Checker {
+	return &Checker{
+		ttl:     ttl,
+		timeout: timeout,
+		results: make(map[string]Dependency),
+	}
+}
+
+// Add registers a dependency check. It must be called before the checker
+// starts serving probes.
+func (c *Checker) Add(name string, check Check) *Checker {
+	c.checks = append(c.checks, named{name: name, check: check})
+	return c
+}
+
+// AddWithDetails registers a dependency check whose probe output also
+// carries details().
+func (c *Checker) AddWithDetails(name string, check Check, details Details) *Checker {
+	c.checks = append(c.checks, named{name: name, check: check, details: details})
+	return c
+}
+
+func (c *Checker) MarkShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Liveness only reports that the process is running; it never touches
+// dependencies so a broker outage doesn't get the container restarted.
+func (c *Checker) Liveness() Report {
+	return Report{Status: StatusAlive, Dependencies: map[string]Dependency{}}
+}
+
+func (c *Checker) Readiness(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.lastRun) >= c.ttl {
+		c.refresh(ctx)
+	}
+
+	report := Report{Status: StatusReady, Dependencies: make(map[string]Dependency, len(c.results))}
+	for _, dep := range c.checks {
+		result := c.results[dep.name]
+		if dep.details != nil {
+			result.Details = dep.details()
+		}
+		report.Dependencies[dep.name] = result
+		if result.Status != StatusUp {
+			report.Status = StatusNotReady
+		}
+	}
+	if c.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func (c *Checker) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type outcome struct {
+		name    string
+		err     error
+		latency time.Duration
+	}
+
+	outcomes := make(chan outcome, len(c.checks))
+	for _, dep := range c.checks {
+		go func(dep named) {
+			start := time.Now()
+			err := dep.check(ctx)
+			outcomes <- outcome{name: dep.name, err: err, latency: time.Since(start)}
+		}(dep)
+	}
+
+	now := time.Now()
+	for range c.checks {
+		o := <-outcomes
+		result := c.results[o.name]
+		result.CheckedAt = now
+		result.LatencyMs = float64(o.latency.Microseconds()) / 1000
+		if o.err != nil {
+			result.Status = StatusDown
+			result.LastError = o.err.Error()
+			result.LastErrorAt = &now
+			log.Printf("[WARN] Health check for %s failed: %v", o.name, o.err)
+		} else {
+			result.Status = StatusUp
+		}
+		c.results[o.name] = result
+	}
+	c.lastRun = now
+}
+
+// FromConfig builds a Checker using HEALTH_CACHE_TTL and HEALTH_CHECK_TIMEOUT.
+func FromConfig(l *config.Loader) *Checker {
+	return NewChecker(
+		l.Duration("HEALTH_CACHE_TTL", 2*time.Second),
+		l.Duration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+	)
+}
@@ -0,0 +1,69 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2025, 1, 6, 9, 0, 0, 500_000_000, time.UTC)
+	etag := ETag("histories", "taskId=1", 3, modified)
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		want   bool
+	}{
+		{"no conditions", http.MethodGet, nil, false},
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": etag}, true},
+		{"etag in a list", http.MethodGet, map[string]string{"If-None-Match": `"other", W/` + etag}, true},
+		{"star", http.MethodGet, map[string]string{"If-None-Match": "*"}, true},
+		{"other etag", http.MethodGet, map[string]string{"If-None-Match": `"other"`}, false},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": "Mon, 06 Jan 2025 09:00:00 GMT"}, true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": "Mon, 06 Jan 2025 08:59:59 GMT"}, false},
+		{"etag wins over date", http.MethodGet, map[string]string{
+			"If-None-Match":     `"other"`,
+			"If-Modified-Since": "Mon, 06 Jan 2025 09:00:00 GMT",
+		}, false},
+		{"head", http.MethodHead, map[string]string{"If-None-Match": etag}, true},
+		{"post", http.MethodPost, map[string]string{"If-None-Match": etag}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/taskHistories", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			if got := NotModified(w, r, etag, modified); got != tt.want {
+				t.Fatalf("NotModified = %v, want %v", got, tt.want)
+			}
+			if tt.want && w.Code != http.StatusNotModified {
+				t.Errorf("status = %d, want 304", w.Code)
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("ETag header = %q", w.Header().Get("ETag"))
+			}
+			if got := w.Header().Get("Last-Modified"); got != "Mon, 06 Jan 2025 09:00:00 GMT" {
+				t.Errorf("Last-Modified = %q", got)
+			}
+		})
+	}
+}
+
+func TestETag(t *testing.T) {
+	base := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	if ETag("a", 1, base) != ETag("a", 1, base.In(time.FixedZone("CET", 3600))) {
+		t.Error("the same instant in another zone changed the tag")
+	}
+	if ETag("a", 1, base) == ETag("a", 1, base.Add(time.Microsecond)) {
+		t.Error("a newer timestamp kept the tag")
+	}
+	if ETag("ab", "c") == ETag("a", "bc") {
+		t.Error("parts are not separated")
+	}
+}
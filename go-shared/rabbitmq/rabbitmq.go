@@ -0,0 +1,125 @@
+// Package rabbitmq dials the broker used by the Go services and carries W3C
+// trace context in AMQP message headers.
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+
+	"go-shared/config"
+	"go-shared/health"
+	"go-shared/retry"
+)
+
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Connect  retry.Backoff
+}
+
+func LoadConfig(l *config.Loader) Config {
+	return Config{
+		Host:     l.String("RABBITMQ_HOST", "rabbitmq"),
+		Port:     l.String("RABBITMQ_PORT", "5672"),
+		User:     l.String("RABBITMQ_USER", "user"),
+		Password: l.Secret("RABBITMQ_PASS", "password"),
+		Connect:  retry.LoadBackoff(l, "RABBITMQ"),
+	}
+}
+
+func (c Config) URL() string {
+	return fmt.Sprintf("amqp://%s:%s@%s:%s/", c.User, c.Password, c.Host, c.Port)
+}
+
+// Dial connects to RabbitMQ, retrying with exponential backoff until
+// cfg.Connect.Deadline while the broker is still starting.
+func Dial(ctx context.Context, cfg Config) (*amqp.Connection, error) {
+	log.Printf("Connecting to RabbitMQ at %s:%s", cfg.Host, cfg.Port)
+
+	var conn *amqp.Connection
+	err := retry.WithBackoff(ctx, "rabbitmq", cfg.Connect, func(context.Context) error {
+		c, err := amqp.Dial(cfg.URL())
+		if err != nil {
+			return err
+		}
+		conn = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Connected to RabbitMQ successfully")
+	return conn, nil
+}
+
+// HealthCheck reports the state of an already open connection and channel
+// instead of dialling the broker on every probe.
+func HealthCheck(conn *amqp.Connection, ch *amqp.Channel) health.Check {
+	return func(context.Context) error {
+		if conn == nil || conn.IsClosed() {
+			return errors.New("connection closed")
+		}
+		if ch == nil || ch.IsClosed() {
+			return errors.New("channel closed")
+		}
+		return nil
+	}
+}
+
+// HeaderCarrier adapts AMQP message headers to the OpenTelemetry
+// TextMapCarrier interface.
+type HeaderCarrier amqp.Table
+
+func (c HeaderCarrier) Get(key string) string {
+	value, ok := c[key]
+	if !ok {
+		return ""
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ExtractTraceContext returns ctx enriched with the trace context found in
+// the message headers, if any.
+func ExtractTraceContext(ctx context.Context, headers amqp.Table) context.Context {
+	if headers == nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(headers))
+}
+
+// InjectTraceContext writes the trace context of ctx into headers, allocating
+// the table when needed.
+func InjectTraceContext(ctx context.Context, headers amqp.Table) amqp.Table {
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(headers))
+	return headers
+}
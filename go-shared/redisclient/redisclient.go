@@ -0,0 +1,57 @@
+// Package redisclient connects to the Redis instance that holds issued
+// manager tokens.
+package redisclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+
+	"go-shared/config"
+	"go-shared/health"
+	"go-shared/retry"
+)
+
+type Config struct {
+	Host    string
+	Port    string
+	Connect retry.Backoff
+}
+
+func LoadConfig(l *config.Loader) Config {
+	return Config{
+		Host:    l.String("REDIS_HOST", "redis"),
+		Port:    l.String("REDIS_PORT", "6379"),
+		Connect: retry.LoadBackoff(l, "REDIS"),
+	}
+}
+
+func (c Config) Addr() string {
+	return fmt.Sprintf("%s:%s", c.Host, c.Port)
+}
+
+// Connect creates a client and waits until Redis answers PING, retrying with
+// exponential backoff until cfg.Connect.Deadline.
+func Connect(ctx context.Context, cfg Config) (*redis.Client, error) {
+	log.Printf("Connecting to Redis at %s", cfg.Addr())
+
+	client := redis.NewClient(&redis.Options{Addr: cfg.Addr()})
+	err := retry.WithBackoff(ctx, "redis", cfg.Connect, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	})
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	log.Println("Connected to Redis successfully")
+	return client, nil
+}
+
+func HealthCheck(client *redis.Client) health.Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
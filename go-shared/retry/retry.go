@@ -0,0 +1,70 @@
+// Package retry runs startup connection attempts until they succeed.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go-shared/config"
+)
+
+// Backoff is an exponential retry schedule bounded by a deadline rather than
+// an attempt count, so a slow-starting dependency gets as long as the
+// operator allows instead of a fixed number of tries.
+type Backoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Deadline time.Duration
+}
+
+// LoadBackoff reads <prefix>_CONNECT_RETRY_INITIAL, <prefix>_CONNECT_RETRY_MAX
+// and <prefix>_CONNECT_TIMEOUT, so every dependency is retried the same way
+// and configured with the same keys.
+func LoadBackoff(l *config.Loader, prefix string) Backoff {
+	b := Backoff{
+		Initial:  l.Duration(prefix+"_CONNECT_RETRY_INITIAL", 500*time.Millisecond),
+		Max:      l.Duration(prefix+"_CONNECT_RETRY_MAX", 10*time.Second),
+		Deadline: l.Duration(prefix+"_CONNECT_TIMEOUT", 60*time.Second),
+	}
+	if b.Initial <= 0 || b.Max < b.Initial {
+		l.Invalid(prefix+"_CONNECT_RETRY_MAX", "must be at least %s_CONNECT_RETRY_INITIAL (%s), got %s",
+			prefix, b.Initial, b.Max)
+	}
+	if b.Deadline <= 0 {
+		l.Invalid(prefix+"_CONNECT_TIMEOUT", "must be positive, got %s", b.Deadline)
+	}
+	return b
+}
+
+// WithBackoff calls fn until it succeeds, doubling the wait after each
+// failure up to b.Max. It gives up once b.Deadline has passed since the first
+// attempt or ctx is cancelled, returning the last error.
+func WithBackoff(ctx context.Context, name string, b Backoff, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, b.Deadline)
+	defer cancel()
+
+	delay := b.Initial
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		deadline, _ := ctx.Deadline()
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("%s: giving up after %d attempts in %s: %w", name, attempt, b.Deadline, err)
+		}
+		wait := min(delay, remaining)
+		log.Printf("[WARN] %s: attempt %d failed, retrying in %s (%s left): %v",
+			name, attempt, wait, remaining.Round(time.Second), err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: giving up after %d attempts in %s: %w", name, attempt, b.Deadline, err)
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, b.Max)
+	}
+}
@@ -0,0 +1,79 @@
+// Package telemetry configures OpenTelemetry tracing for the Go services.
+package telemetry
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-shared/config"
+)
+
+type Config struct {
+	Endpoint    string
+	ServiceName string
+}
+
+func LoadConfig(l *config.Loader, defaultServiceName string) Config {
+	return Config{
+		Endpoint:    l.String("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		ServiceName: l.String("OTEL_SERVICE_NAME", defaultServiceName),
+	}
+}
+
+// Init configures the global tracer provider and W3C propagators. Spans are
+// exported over OTLP/HTTP when an endpoint is configured; otherwise only
+// context propagation is enabled and spans are dropped. The returned function
+// flushes pending spans and must be called on shutdown.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Endpoint == "" {
+		log.Println("OTEL_EXPORTER_OTLP_ENDPOINT not set, trace export disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// The endpoint is normally a URL such as http://otel-collector:4318,
+	// whose scheme decides on TLS. A bare host:port is taken as plain HTTP.
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(cfg.Endpoint)}
+	if !strings.Contains(cfg.Endpoint, "://") {
+		options = []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint), otlptracehttp.WithInsecure()}
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Printf("Exporting traces via OTLP to %s", cfg.Endpoint)
+	return provider.Shutdown, nil
+}
+
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
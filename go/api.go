@@ -2,36 +2,38 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"regexp"
-	"strconv"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	amqp "github.com/rabbitmq/amqp091-go"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-shared/config"
+	"go-shared/database"
+	"go-shared/duration"
+	"go-shared/health"
+	"go-shared/rabbitmq"
+	"go-shared/redisclient"
+	"go-shared/telemetry"
 )
 
 var (
-	clients   = make(map[*websocket.Conn]*ManagerSession)
-	broadcast = make(chan TaskMessage)
-	upgrader  = websocket.Upgrader{
+	upgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
-	redisClient *redis.Client
-	db          *gorm.DB
+	tracer = otel.Tracer("goservice")
 )
 
 func logInfo(format string, args ...any) {
@@ -55,6 +57,9 @@ type ManagerSession struct {
 	ManagerID uuid.UUID
 	TeamID    uuid.UUID
 	SprintID  uuid.UUID
+	// APIVersion is the message version requested when the socket was
+	// opened (/ws?version=2).
+	APIVersion int
 }
 
 type TaskMessage struct {
@@ -65,88 +70,91 @@ type TaskMessage struct {
 	Error    string    `json:"error,omitempty"`
 }
 
+// broadcastMessage carries the trace context of the RabbitMQ delivery that
+// produced msg so the broadcast span joins the same trace.
+type broadcastMessage struct {
+	ctx context.Context
+	msg TaskMessage
+}
+
 type TaskInfo struct {
 	ID            uuid.UUID     `json:"id"`
 	Name          string        `json:"name"`
 	TotalDuration time.Duration `json:"total_duration"`
-	InProgress    bool          `json:"in_progress"`
-	DeveloperName string        `json:"developer_name,omitempty"`
-	Status        string        `json:"status,omitempty"`
-	IsStarted     bool          `json:"is_started"`
-	IsPaused      bool          `json:"is_paused"`
-	IsStopped     bool          `json:"is_stopped"`
-	StartTime     *time.Time    `json:"start_time,omitempty"`
-	UpdateTime    time.Time     `json:"update_time,omitempty"`
+	// Version 2 only: the same duration in seconds and as ISO-8601.
+	TotalDurationSeconds *float64   `json:"total_duration_seconds,omitempty"`
+	TotalDurationISO     string     `json:"total_duration_iso,omitempty"`
+	InProgress           bool       `json:"in_progress"`
+	DeveloperName        string     `json:"developer_name,omitempty"`
+	Status               string     `json:"status,omitempty"`
+	IsStarted            bool       `json:"is_started"`
+	IsPaused             bool       `json:"is_paused"`
+	IsStopped            bool       `json:"is_stopped"`
+	StartTime            *time.Time `json:"start_time,omitempty"`
+	UpdateTime           time.Time  `json:"update_time,omitempty"`
 }
 
-type RabbitMQConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
+// forVersion returns msg as it should be sent to a client that asked for
+// version. The shared TaskData is copied, never modified.
+func (msg TaskMessage) forVersion(version int) TaskMessage {
+	if msg.TaskData == nil || version < duration.Version2 {
+		return msg
+	}
+	task := *msg.TaskData
+	seconds := duration.Seconds(task.TotalDuration)
+	task.TotalDurationSeconds = &seconds
+	task.TotalDurationISO = duration.ISO8601(task.TotalDuration)
+	msg.TaskData = &task
+	return msg
 }
 
-func initRedis() {
-	redisHost := getEnv("REDIS_HOST", "redis")
-	redisPort := getEnv("REDIS_PORT", "6379")
-	redisAddr := fmt.Sprintf("%s:%s", redisHost, redisPort)
-
-	logInfo("Connecting to Redis at %s", redisAddr)
-
-	redisClient = redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
+type JWTConfig struct {
+	Secret   string
+	Issuer   string
+	Audience string
+}
 
-	ctx := context.Background()
-	_, err := redisClient.Ping(ctx).Result()
-	if err != nil {
-		logError("Failed to connect to Redis: %v", err)
-		log.Fatalf("Redis connection failed")
+func loadJWTConfig(l *config.Loader) JWTConfig {
+	return JWTConfig{
+		Secret:   l.RequiredSecret("JWT_TOKEN"),
+		Issuer:   l.Required("JWT_ISSUER"),
+		Audience: l.Required("JWT_AUDIENCE"),
 	}
-	logInfo("Successfully connected to Redis")
 }
 
-func initDatabase() {
-	dbHost := getEnv("DB_HOST", "database")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbName := getEnv("DB_DATABASE", "project")
-	dbUser := getEnv("DB_USERNAME", "postgres")
-	dbPassword := getEnv("DB_PASSWORD", "password")
-
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// server holds the connected dashboards and the dependencies the handlers
+// need. Everything it talks to is passed in so it can run against fakes.
+type server struct {
+	repo   TaskRepository
+	tokens TokenStore
+	jwt    JWTConfig
 
-	logInfo("Connecting to database: %s@%s:%s/%s", dbUser, dbHost, dbPort, dbName)
-
-	var err error
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		logError("Failed to connect to database: %v", err)
-		log.Fatalf("Database connection failed")
-	}
+	// settleDelay is how long handleTaskEvent waits before reading a task
+	// that was just changed.
+	settleDelay time.Duration
 
-	sqlDB, err := db.DB()
-	if err != nil {
-		logError("Failed to get underlying sql.DB: %v", err)
-		log.Fatalf("Database configuration failed")
-	}
+	clients   map[*websocket.Conn]*ManagerSession
+	clientsMu sync.RWMutex
+	broadcast chan broadcastMessage
+}
 
-	if err = sqlDB.Ping(); err != nil {
-		logError("Failed to ping database: %v", err)
-		log.Fatalf("Database ping failed")
+func newServer(repo TaskRepository, tokens TokenStore, jwt JWTConfig) *server {
+	return &server{
+		repo:        repo,
+		tokens:      tokens,
+		jwt:         jwt,
+		settleDelay: 200 * time.Millisecond,
+		clients:     make(map[*websocket.Conn]*ManagerSession),
+		broadcast:   make(chan broadcastMessage),
 	}
-
-	logInfo("Successfully connected to database")
 }
 
-func validateJWT(tokenString string) (uuid.UUID, error) {
+func (s *server) validateJWT(tokenString string) (uuid.UUID, error) {
 	logDebug("Validating JWT token")
 
-	jwtSecret := getEnv("JWT_TOKEN", "")
-	jwtIssuer := getEnv("JWT_ISSUER", "")
-	jwtAudience := getEnv("JWT_AUDIENCE", "")
+	jwtSecret := s.jwt.Secret
+	jwtIssuer := s.jwt.Issuer
+	jwtAudience := s.jwt.Audience
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -193,265 +201,9 @@ func validateJWT(tokenString string) (uuid.UUID, error) {
 	return uuid.Nil, fmt.Errorf("invalid token")
 }
 
-func checkTokenInRedis(managerID uuid.UUID, token string) bool {
-	ctx := context.Background()
-	redisKey := fmt.Sprintf("manager_token:%s", managerID.String())
-
-	logDebug("Checking token in Redis for manager ID: %s", managerID)
-
-	storedToken, err := redisClient.Get(ctx, redisKey).Result()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			logWarn("Token not found in Redis for manager ID: %s", managerID)
-		} else {
-			logError("Redis error while checking token: %v", err)
-		}
-		return false
-	}
-
-	if storedToken == token {
-		logInfo("Token validated successfully from Redis for manager ID: %s", managerID)
-		return true
-	}
-
-	logWarn("Token mismatch in Redis for manager ID: %s", managerID)
-	return false
-}
-
-func getRabbitMQConfig() RabbitMQConfig {
-	return RabbitMQConfig{
-		Host:     getEnv("RABBITMQ_HOST", "rabbitmq"),
-		Port:     getEnv("RABBITMQ_PORT", "5672"),
-		User:     getEnv("RABBITMQ_USER", "user"),
-		Password: getEnv("RABBITMQ_PASS", "password"),
-	}
-}
-
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return defaultValue
-}
-
-func getManagerTeamAndSprint(managerID uuid.UUID) (uuid.UUID, uuid.UUID, error) {
-	logDebug("Getting team and sprint for manager ID: %s using procedure", managerID)
-
-	rows, err := db.Raw("SELECT * FROM GetManagerTeamAndSprint(?)", managerID.String()).Rows()
-	if err != nil {
-		logError("Failed to call GetManagerTeamAndSprint procedure for manager %s: %v", managerID, err)
-		return uuid.Nil, uuid.Nil, fmt.Errorf("failed to call GetManagerTeamAndSprint procedure: %v", err)
-	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			logError("Error closing rows: %v", err)
-		}
-	}(rows)
-
-	if !rows.Next() {
-		logWarn("No team found for manager ID: %s", managerID)
-		return uuid.Nil, uuid.Nil, fmt.Errorf("no team found for manager")
-	}
-
-	var teamIDStr, sprintIDStr string
-	err = rows.Scan(&teamIDStr, &sprintIDStr)
-	if err != nil {
-		logError("Failed to scan team and sprint data: %v", err)
-		return uuid.Nil, uuid.Nil, fmt.Errorf("failed to scan team and sprint data: %v", err)
-	}
-
-	teamID, err := uuid.Parse(teamIDStr)
-	if err != nil {
-		logError("Failed to parse team ID as UUID: %v", err)
-		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid team ID format: %v", err)
-	}
-
-	var sprintID uuid.UUID
-	if sprintIDStr != "" {
-		sprintID, err = uuid.Parse(sprintIDStr)
-		if err != nil {
-			logError("Failed to parse sprint ID as UUID: %v", err)
-			return teamID, uuid.Nil, fmt.Errorf("invalid sprint ID format: %v", err)
-		}
-	} else {
-		logWarn("No sprint found for manager's team %s", teamID)
-		return teamID, uuid.Nil, nil
-	}
-
-	logInfo("Found team ID: %s, sprint ID: %s for manager ID: %s", teamID, sprintID, managerID)
-	return teamID, sprintID, nil
-}
-
-func parsePostgresInterval(intervalStr string) (time.Duration, error) {
-	if intervalStr == "00:00:00" {
-		return 0, nil
-	}
-
-	var totalDuration time.Duration
-
-	if strings.Contains(intervalStr, "day") {
-		parts := strings.Split(intervalStr, " ")
-		for i, part := range parts {
-			if part == "day" && i > 0 {
-				days, err := strconv.Atoi(parts[i-1])
-				if err == nil {
-					totalDuration += time.Duration(days) * 24 * time.Hour
-				}
-				intervalStr = strings.Join(parts[i+1:], " ")
-				break
-			}
-		}
-	}
-
-	if strings.TrimSpace(intervalStr) != "" {
-		// Regex HH:MM:SS.ssssss
-		re := regexp.MustCompile(`(\d+):(\d+):(\d+)\.?(\d*)`)
-		matches := re.FindStringSubmatch(intervalStr)
-
-		if len(matches) >= 4 {
-			hours, _ := strconv.Atoi(matches[1])
-			minutes, _ := strconv.Atoi(matches[2])
-			seconds, _ := strconv.Atoi(matches[3])
-
-			totalDuration += time.Duration(hours) * time.Hour
-			totalDuration += time.Duration(minutes) * time.Minute
-			totalDuration += time.Duration(seconds) * time.Second
-
-			// micro/nanoseconds
-			if len(matches) > 4 && matches[4] != "" {
-				microStr := matches[4]
-				for len(microStr) < 6 {
-					microStr += "0"
-				}
-				microseconds, _ := strconv.Atoi(microStr[:6])
-				totalDuration += time.Duration(microseconds) * time.Microsecond
-			}
-		}
-	}
-
-	return totalDuration, nil
-}
-
-func getManagerTasks(managerID uuid.UUID) ([]TaskInfo, error) {
-	logInfo("Getting tasks for manager ID: %s using optimized procedure", managerID)
-
-	rows, err := db.Raw("SELECT * FROM GetManagerTasksWithDetails(?)", managerID.String()).Rows()
-	if err != nil {
-		logError("Failed to call GetManagerTasksWithDetails procedure for manager %s: %v", managerID, err)
-		return nil, fmt.Errorf("failed to call GetManagerTasksWithDetails procedure: %v", err)
-	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			logError("Error closing rows: %v", err)
-		}
-	}(rows)
-
-	var taskInfos []TaskInfo
-
-	for rows.Next() {
-		var taskID, sprintID, teamID, taskName, totalDurationStr, currentStatus, developerName string
-		var isStarted, isPaused, isStopped bool
-		var startTime, updateTime *time.Time
-
-		err := rows.Scan(&taskID, &taskName, &totalDurationStr, &isStarted, &isPaused, &isStopped,
-			&currentStatus, &developerName, &startTime, &updateTime, &sprintID, &teamID)
-		if err != nil {
-			logError("Failed to scan task row: %v", err)
-			continue
-		}
-
-		taskUUID, err := uuid.Parse(taskID)
-		if err != nil {
-			logError("Failed to parse task ID as UUID: %v", err)
-			continue
-		}
-
-		totalDuration, err := parsePostgresInterval(totalDurationStr)
-		if err != nil {
-			logWarn("Failed to parse duration for task %s: %v, using 0", taskID, err)
-			totalDuration = 0
-		}
-
-		taskInfo := TaskInfo{
-			ID:            taskUUID,
-			Name:          taskName,
-			TotalDuration: totalDuration,
-			InProgress:    isStarted,
-			DeveloperName: developerName,
-			Status:        currentStatus,
-			IsStarted:     isStarted,
-			IsPaused:      isPaused,
-			IsStopped:     isStopped,
-			StartTime:     startTime,
-			UpdateTime:    *updateTime,
-		}
-
-		taskInfos = append(taskInfos, taskInfo)
-
-		logDebug("Task %s (%s) - Duration: %v, IsStarted: %t, IsPaused: %t, IsStopped: %t, Developer: %s, Status: %s",
-			taskUUID, taskName, totalDuration, isStarted, isPaused, isStopped, developerName, currentStatus)
-	}
-
-	if err = rows.Err(); err != nil {
-		logError("Error iterating over task rows: %v", err)
-		return nil, fmt.Errorf("error iterating over task rows: %v", err)
-	}
-
-	logInfo("Successfully retrieved %d tasks for manager %s", len(taskInfos), managerID)
-	return taskInfos, nil
-}
-
-func checkRabbitMQConnection() bool {
-	config := getRabbitMQConfig()
-	connURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		config.User, config.Password, config.Host, config.Port)
-
-	conn, err := amqp.Dial(connURL)
-	if err != nil {
-		log.Printf("Error connecting to RabbitMQ: %v", err)
-		return false
-	}
-	defer func(conn *amqp.Connection) {
-		err := conn.Close()
-		if err != nil {
-			log.Printf("Error closing RabbitMQ connection: %v", err)
-		}
-	}(conn)
-
-	return true
-}
-
-func healthHandler(w http.ResponseWriter, _ *http.Request) {
-	rabbitmqOK := checkRabbitMQConnection()
-
-	w.Header().Set("Content-Type", "application/json")
-
-	if !rabbitmqOK {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		err := json.NewEncoder(w).Encode(map[string]string{
-			"status":   "unhealthy",
-			"rabbitmq": "disconnected",
-		})
-		if err != nil {
-			return
-		}
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	err := json.NewEncoder(w).Encode(map[string]string{
-		"status":   "healthy",
-		"rabbitmq": "connected",
-	})
-	if err != nil {
-		return
-	}
-}
-
-func handleConnections(w http.ResponseWriter, r *http.Request) {
+func (s *server) handleConnections(w http.ResponseWriter, r *http.Request) {
 	logInfo("New WebSocket connection request from %s", r.RemoteAddr)
+	apiVersion := duration.RequestVersion(r)
 
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -497,7 +249,7 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 
 	logDebug("Received authentication message with token")
 
-	managerID, err := validateJWT(authMsg.Token)
+	managerID, err := s.validateJWT(authMsg.Token)
 	if err != nil {
 		logError("JWT validation failed for WebSocket connection: %v", err)
 		err := ws.WriteMessage(websocket.TextMessage, []byte(`{"action":"auth_error","error":"Invalid token"}`))
@@ -510,7 +262,7 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 
 	logInfo("JWT validated for manager ID: %s", managerID)
 
-	if !checkTokenInRedis(managerID, authMsg.Token) {
+	if !s.tokens.ValidToken(r.Context(), managerID, authMsg.Token) {
 		logWarn("Token validation failed in Redis for manager %s", managerID)
 		err := ws.WriteMessage(websocket.TextMessage, []byte(`{"action":"auth_error","error":"Token not found or expired"}`))
 		if err != nil {
@@ -522,7 +274,7 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 
 	logInfo("Token validated in Redis for manager %s", managerID)
 
-	teamID, sprintID, err := getManagerTeamAndSprint(managerID)
+	teamID, sprintID, err := s.repo.ManagerTeamAndSprint(r.Context(), managerID)
 	if err != nil {
 		logError("Failed to get manager data for %s: %v", managerID, err)
 		err := ws.WriteMessage(websocket.TextMessage, []byte(`{"action":"auth_error","error":"Manager data not found"}`))
@@ -546,16 +298,17 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 	logInfo("Authentication successful for manager %s", managerID)
 
 	session := &ManagerSession{
-		Conn:      ws,
-		ManagerID: managerID,
-		TeamID:    teamID,
-		SprintID:  sprintID,
+		Conn:       ws,
+		ManagerID:  managerID,
+		TeamID:     teamID,
+		SprintID:   sprintID,
+		APIVersion: apiVersion,
 	}
 
-	clients[ws] = session
-	logInfo("Added manager %s to active WebSocket clients (total: %d)", managerID, len(clients))
+	total := s.addClient(ws, session)
+	logInfo("Added manager %s to active WebSocket clients (total: %d)", managerID, total)
 
-	tasks, err := getManagerTasks(managerID)
+	tasks, err := s.repo.ManagerTasks(r.Context(), managerID)
 	if err != nil {
 		logError("Failed to get initial tasks for manager %s: %v", managerID, err)
 	} else {
@@ -568,7 +321,7 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 				TaskData: &task,
 			}
 
-			msgBytes, _ := json.Marshal(taskMsg)
+			msgBytes, _ := json.Marshal(taskMsg.forVersion(apiVersion))
 			err := ws.WriteMessage(websocket.TextMessage, msgBytes)
 			if err != nil {
 				logError("Failed to send task %s to manager %s: %v", task.ID, managerID, err)
@@ -594,26 +347,80 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 		_, _, err := ws.ReadMessage()
 		if err != nil {
 			logInfo("WebSocket connection closed for manager %s: %v", managerID, err)
-			delete(clients, ws)
-			logInfo("Removed manager %s from active clients (remaining: %d)", managerID, len(clients))
+			remaining := s.removeClient(ws)
+			logInfo("Removed manager %s from active clients (remaining: %d)", managerID, remaining)
 			break
 		}
 	}
 }
 
-func handleMessages() {
+func (s *server) addClient(conn *websocket.Conn, session *ManagerSession) int {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	s.clients[conn] = session
+	return len(s.clients)
+}
+
+func (s *server) removeClient(conn *websocket.Conn) int {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	delete(s.clients, conn)
+	return len(s.clients)
+}
+
+func (s *server) snapshotClients() map[*websocket.Conn]*ManagerSession {
+	s.clientsMu.RLock()
+	defer s.clientsMu.RUnlock()
+	snapshot := make(map[*websocket.Conn]*ManagerSession, len(s.clients))
+	for conn, session := range s.clients {
+		snapshot[conn] = session
+	}
+	return snapshot
+}
+
+// closeAllClients sends every connected manager a server_shutdown close frame
+// with a hint on when to reconnect. The per-connection read loops in
+// handleConnections notice the close and remove themselves from clients.
+func (s *server) closeAllClients(reconnectAfter time.Duration) {
+	reason := fmt.Sprintf(`{"action":"server_shutdown","reconnect_after_ms":%d}`, reconnectAfter.Milliseconds())
+	frame := websocket.FormatCloseMessage(websocket.CloseServiceRestart, reason)
+	deadline := time.Now().Add(time.Second)
+
+	sessions := s.snapshotClients()
+	logInfo("Sending server_shutdown to %d WebSocket clients", len(sessions))
+	for conn, session := range sessions {
+		if err := conn.WriteControl(websocket.CloseMessage, frame, deadline); err != nil {
+			logError("Failed to send close frame to manager %s: %v", session.ManagerID, err)
+			if err := conn.Close(); err != nil {
+				logError("Error closing WebSocket connection for manager %s: %v", session.ManagerID, err)
+			}
+			s.removeClient(conn)
+		}
+	}
+}
+
+func (s *server) handleMessages(done chan<- struct{}) {
+	defer close(done)
 	logInfo("Started message handling goroutine for WebSocket broadcasts")
-	for {
-		msg := <-broadcast
+	for bm := range s.broadcast {
+		msg := bm.msg
+		ctx, span := tracer.Start(bm.ctx, "websocket.broadcast", trace.WithAttributes(
+			attribute.String("task.id", msg.TaskID.String()),
+			attribute.String("task.action", msg.Action),
+		))
 		logInfo("Processing broadcast message - Action: %s, TaskID: %s", msg.Action, msg.TaskID)
 
 		clientCount := 0
 		sentCount := 0
-		for conn, session := range clients {
+		for conn, session := range s.snapshotClients() {
 			clientCount++
 
 			if msg.TaskData != nil {
-				taskBelongsToManager := checkTaskBelongsToManager(msg.TaskID, session.ManagerID)
+				taskBelongsToManager, err := s.repo.TaskBelongsToManager(ctx, msg.TaskID, session.ManagerID)
+				if err != nil {
+					logError("Failed to check if task belongs to manager: %v", err)
+					continue
+				}
 				if !taskBelongsToManager {
 					logDebug("Task %s does not belong to manager %s, skipping",
 						msg.TaskID, session.ManagerID)
@@ -623,7 +430,7 @@ func handleMessages() {
 					msg.TaskID, session.ManagerID)
 			}
 
-			msgBytes, err := json.Marshal(msg)
+			msgBytes, err := json.Marshal(msg.forVersion(session.APIVersion))
 			if err != nil {
 				logError("Error marshaling message for manager %s: %v", session.ManagerID, err)
 				continue
@@ -635,9 +442,8 @@ func handleMessages() {
 				err := conn.Close()
 				if err != nil {
 					logError("Error closing WebSocket connection for manager %s: %v", session.ManagerID, err)
-					return
 				}
-				delete(clients, conn)
+				s.removeClient(conn)
 			} else {
 				sentCount++
 				logDebug("Successfully sent message to manager %s", session.ManagerID)
@@ -645,223 +451,170 @@ func handleMessages() {
 		}
 
 		logInfo("Broadcast completed - Checked %d clients, sent to %d clients", clientCount, sentCount)
+		span.SetAttributes(
+			attribute.Int("websocket.clients_checked", clientCount),
+			attribute.Int("websocket.clients_sent", sentCount),
+		)
+		span.End()
 	}
+	logInfo("Broadcast channel closed, message handling goroutine stopped")
 }
 
-func getTaskInfo(taskID uuid.UUID) (*TaskInfo, error) {
-	logDebug("Getting task info for task ID: %s using optimized procedure", taskID)
+func (s *server) handleTaskEvent(ev TaskEvent) {
+	logInfo("Processing task event - TaskID: %s, Action: %s", ev.TaskID, ev.Action)
 
-	rows, err := db.Raw("SELECT * FROM GetSingleTaskDetails(?)", taskID.String()).Rows()
-	if err != nil {
-		logError("Failed to call GetSingleTaskDetails procedure for task %s: %v", taskID, err)
-		return nil, fmt.Errorf("failed to call GetSingleTaskDetails procedure: %v", err)
-	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			logError("Error closing rows: %v", err)
-		}
-	}(rows)
-
-	if !rows.Next() {
-		logWarn("Task %s not found", taskID)
-		return nil, fmt.Errorf("task not found")
-	}
+	// Give the publisher's transaction time to become visible before the
+	// task is re-read.
+	time.Sleep(s.settleDelay)
 
-	var taskIDStr, sprintID, teamID, managerID, taskName, totalDurationStr, currentStatus, developerName string
-	var isStarted, isPaused, isStopped bool
-	var startTime, updateTime *time.Time
-
-	err = rows.Scan(&taskIDStr, &taskName, &totalDurationStr, &isStarted, &isPaused, &isStopped,
-		&currentStatus, &developerName, &startTime, &updateTime, &sprintID, &teamID, &managerID)
+	taskInfo, err := s.repo.TaskInfo(ev.Ctx, ev.TaskID)
 	if err != nil {
-		logError("Failed to scan task row: %v", err)
-		return nil, fmt.Errorf("failed to scan task row: %v", err)
+		logError("Failed to get task info for task %s: %v", ev.TaskID, err)
+		return
 	}
 
-	taskUUID, err := uuid.Parse(taskIDStr)
-	if err != nil {
-		logError("Failed to parse task ID as UUID: %v", err)
-		return nil, fmt.Errorf("invalid task ID format: %v", err)
+	taskMessage := TaskMessage{
+		Action:   ev.Action,
+		TaskID:   ev.TaskID,
+		TaskData: taskInfo,
 	}
 
-	totalDuration, err := parsePostgresInterval(totalDurationStr)
-	if err != nil {
-		logWarn("Failed to parse duration for task %s: %v, using 0", taskID, err)
-		totalDuration = 0
-	}
-
-	taskInfo := TaskInfo{
-		ID:            taskUUID,
-		Name:          taskName,
-		TotalDuration: totalDuration,
-		InProgress:    isStarted,
-		DeveloperName: developerName,
-		Status:        currentStatus,
-		IsStarted:     isStarted,
-		IsPaused:      isPaused,
-		IsStopped:     isStopped,
-		StartTime:     startTime,
-		UpdateTime:    *updateTime,
-	}
-
-	logInfo("Retrieved task info: %s (%s) - Duration: %v, Status: %s, Developer: %s",
-		taskID, taskName, totalDuration, currentStatus, developerName)
-	return &taskInfo, nil
+	logInfo("Broadcasting task update - TaskID: %s, Action: %s, Status: %s",
+		ev.TaskID, ev.Action, taskInfo.Status)
+	s.broadcast <- broadcastMessage{ctx: ev.Ctx, msg: taskMessage}
 }
 
-func checkTaskBelongsToManager(taskID, managerID uuid.UUID) bool {
-	logDebug("Checking if task %s belongs to manager %s", taskID, managerID)
-
-	var belongs bool
-	err := db.Raw("SELECT CheckTaskBelongsToManager(?, ?)", taskID.String(), managerID.String()).Row().Scan(&belongs)
-	if err != nil {
-		logError("Failed to check if task belongs to manager: %v", err)
-		return false
-	}
-
-	logDebug("Task %s belongs to manager %s: %t", taskID, managerID, belongs)
-	return belongs
-}
+func main() {
+	logInfo("=== Starting Go WebSocket Service for Manager Dashboard ===")
 
-func consumeRabbitMQ() {
-	logInfo("Starting RabbitMQ consumer")
-	var conn *amqp.Connection
-	var err error
+	loader := config.NewLoader()
+	dbConfig := database.LoadConfig(loader)
+	redisConfig := redisclient.LoadConfig(loader)
+	rabbitConfig := rabbitmq.LoadConfig(loader)
+	telemetryConfig := telemetry.LoadConfig(loader, "goservice")
+	jwtSettings := loadJWTConfig(loader)
+	shutdownTimeout := loader.Duration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	reconnectAfter := loader.Duration("WS_RECONNECT_AFTER", 5*time.Second)
+	checker := health.FromConfig(loader)
 
-	config := getRabbitMQConfig()
-	connURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		config.User, config.Password, config.Host, config.Port)
+	loader.Report(logInfo)
+	if err := loader.Validate(); err != nil {
+		logError("Invalid configuration: %v", err)
+		log.Fatalf("Configuration validation failed")
+	}
 
-	logInfo("Connecting to RabbitMQ at %s:%s", config.Host, config.Port)
-	conn, err = amqp.Dial(connURL)
+	startupCtx := context.Background()
 
+	shutdownTracing, err := telemetry.Init(startupCtx, telemetryConfig)
 	if err != nil {
-		logError("Failed to connect to RabbitMQ: %v", err)
-		log.Fatalf("Failed to connect to RabbitMQ after 5 attempts: %v", err)
+		logError("Failed to initialize tracing: %v", err)
+		log.Fatalf("Tracing initialization failed")
 	}
-	defer func(conn *amqp.Connection) {
-		err := conn.Close()
-		if err != nil {
-			logError("Error closing RabbitMQ connection: %v", err)
-		}
-	}(conn)
-	logInfo("Successfully connected to RabbitMQ")
 
-	ch, err := conn.Channel()
+	logInfo("Initializing Redis connection...")
+	redisClient, err := redisclient.Connect(startupCtx, redisConfig)
 	if err != nil {
-		logError("Error opening RabbitMQ channel: %v", err)
-		log.Fatalf("Error opening channel: %v", err)
+		logError("Failed to connect to Redis: %v", err)
+		log.Fatalf("Redis connection failed")
 	}
-	defer func(ch *amqp.Channel) {
-		err := ch.Close()
-		if err != nil {
-			logError("Error closing RabbitMQ channel: %v", err)
-		}
-	}(ch)
-	logInfo("RabbitMQ channel opened successfully")
-
-	q, err := ch.QueueDeclare(
-		"task_queue",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		logError("Error declaring queue: %v", err)
-		log.Fatalf("Error declaring queue: %v", err)
-	}
-	logInfo("Queue 'task_queue' declared successfully")
-
-	messages, err := ch.Consume(
-		q.Name,
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+
+	logInfo("Initializing database connection...")
+	db, err := database.Open(startupCtx, dbConfig, nil)
 	if err != nil {
-		logError("Error consuming messages: %v", err)
-		log.Fatalf("Error consuming messages: %v", err)
+		logError("Failed to connect to database: %v", err)
+		log.Fatalf("Database connection failed")
 	}
-	logInfo("Started consuming messages from task_queue")
 
-	for d := range messages {
-		logInfo("Received message from RabbitMQ queue: %s", string(d.Body))
+	logInfo("Connecting RabbitMQ consumer...")
+	consumer, err := newTaskConsumer(startupCtx, rabbitConfig)
+	if err != nil {
+		logError("Failed to start RabbitMQ consumer: %v", err)
+		log.Fatalf("RabbitMQ consumer failed: %v", err)
+	}
 
-		var rabbitMsg map[string]any
-		if err := json.Unmarshal(d.Body, &rabbitMsg); err != nil {
-			logError("Failed to parse RabbitMQ message: %v", err)
-			continue
-		}
+	srv := newServer(newPostgresTaskRepository(db), newRedisTokenStore(redisClient), jwtSettings)
 
-		taskIDStr, ok := rabbitMsg["task_id"].(string)
-		if !ok {
-			logError("Invalid task_id in RabbitMQ message: %v", rabbitMsg)
-			continue
-		}
+	checker.
+		AddWithDetails("postgres", database.HealthCheck(db), database.Stats(db)).
+		Add("redis", redisclient.HealthCheck(redisClient)).
+		Add("rabbitmq", rabbitmq.HealthCheck(consumer.conn, consumer.ch))
 
-		taskID, err := uuid.Parse(taskIDStr)
-		if err != nil {
-			logError("Failed to parse task_id as UUID: %v", err)
-			continue
-		}
+	logInfo("Setting up HTTP handlers...")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", srv.handleConnections)
+	mux.HandleFunc("/livez", livenessHandler(checker))
+	mux.HandleFunc("/readyz", readinessHandler(checker))
+	mux.HandleFunc("/health", readinessHandler(checker))
 
-		action, ok := rabbitMsg["action"].(string)
-		if !ok {
-			logError("Invalid action in RabbitMQ message: %v", rabbitMsg)
-			continue
-		}
+	logInfo("Starting message broadcaster goroutine...")
+	broadcasterDone := make(chan struct{})
+	go srv.handleMessages(broadcasterDone)
 
-		logInfo("Processing RabbitMQ message - TaskID: %s, Action: %s", taskID, action)
+	logInfo("Starting RabbitMQ consumer...")
+	var events EventSource = consumer
+	if err := events.Start(srv.handleTaskEvent); err != nil {
+		logError("Failed to start RabbitMQ consumer: %v", err)
+		log.Fatalf("RabbitMQ consumer failed: %v", err)
+	}
 
-		time.Sleep(200 * time.Millisecond)
+	server := &http.Server{
+		Addr:    "0.0.0.0:8080",
+		Handler: mux,
+	}
 
-		taskInfo, err := getTaskInfo(taskID)
-		if err != nil {
-			logError("Failed to get task info for task %s: %v", taskID, err)
-			continue
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		taskMessage := TaskMessage{
-			Action:   action,
-			TaskID:   taskID,
-			TaskData: taskInfo,
+	serverErr := make(chan error, 1)
+	go func() {
+		logInfo("=== Go WebSocket server ready on port :8080 ===")
+		log.Println("Go server with WebSocket on port :8080")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
+	}()
 
-		logInfo("Broadcasting task update - TaskID: %s, Action: %s, Status: %s",
-			taskID, action, taskInfo.Status)
-		broadcast <- taskMessage
+	select {
+	case <-ctx.Done():
+		logInfo("Shutdown signal received, draining (timeout %s)", shutdownTimeout)
+	case err := <-serverErr:
+		logError("Failed to start HTTP server: %v", err)
+		log.Fatal("ListenAndServe: ", err)
 	}
-}
+	stop()
+	checker.MarkShuttingDown()
 
-func main() {
-	logInfo("=== Starting Go WebSocket Service for Manager Dashboard ===")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	logInfo("Initializing Redis connection...")
-	initRedis()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logError("Error shutting down HTTP server: %v", err)
+	}
 
-	logInfo("Initializing database connection...")
-	initDatabase()
+	// The consumer is the only sender on broadcast, so the channel can only
+	// be closed once it has stopped.
+	if events.Shutdown(shutdownCtx) {
+		close(srv.broadcast)
+		select {
+		case <-broadcasterDone:
+		case <-shutdownCtx.Done():
+			logWarn("Timed out waiting for pending broadcasts: %v", shutdownCtx.Err())
+		}
+	}
 
-	logInfo("Starting RabbitMQ consumer goroutine...")
-	go consumeRabbitMQ()
+	srv.closeAllClients(reconnectAfter)
 
-	logInfo("Setting up HTTP handlers...")
-	http.HandleFunc("/ws", handleConnections)
-	http.HandleFunc("/health", healthHandler)
+	if err := redisClient.Close(); err != nil {
+		logError("Error closing Redis client: %v", err)
+	}
 
-	logInfo("Starting message broadcaster goroutine...")
-	go handleMessages()
+	if err := database.Close(db); err != nil {
+		logError("Error closing database pool: %v", err)
+	}
 
-	logInfo("=== Go WebSocket server ready on port :8080 ===")
-	log.Println("Go server with WebSocket on port :8080")
-	if err := http.ListenAndServe("0.0.0.0:8080", nil); err != nil {
-		logError("Failed to start HTTP server: %v", err)
-		log.Fatal("ListenAndServe: ", err)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logError("Error shutting down tracer provider: %v", err)
 	}
+
+	logInfo("=== Go WebSocket service stopped ===")
 }
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+var testJWT = JWTConfig{Secret: "test-secret", Issuer: "test-issuer", Audience: "test-audience"}
+
+func signManagerToken(t *testing.T, managerID uuid.UUID, role string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": testJWT.Issuer,
+		"aud": testJWT.Audience,
+		"http://schemas.microsoft.com/ws/2008/06/identity/claims/role":         role,
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/nameidentifier": managerID.String(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(testJWT.Secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+type testEnv struct {
+	repo   *memoryTaskRepository
+	tokens memoryTokenStore
+	events *fakeEventSource
+	url    string
+}
+
+// startTestServer runs a server with fake dependencies behind httptest and
+// tears everything down in the order main uses.
+func startTestServer(t *testing.T) *testEnv {
+	t.Helper()
+	env := &testEnv{
+		repo:   newMemoryTaskRepository(),
+		tokens: memoryTokenStore{},
+		events: &fakeEventSource{},
+	}
+
+	srv := newServer(env.repo, env.tokens, testJWT)
+	srv.settleDelay = 0
+
+	broadcasterDone := make(chan struct{})
+	go srv.handleMessages(broadcasterDone)
+	if err := env.events.Start(srv.handleTaskEvent); err != nil {
+		t.Fatalf("start events: %v", err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleConnections))
+	env.url = "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if env.events.Shutdown(ctx) {
+			close(srv.broadcast)
+			<-broadcasterDone
+		}
+		srv.closeAllClients(0)
+		ts.Close()
+	})
+	return env
+}
+
+func (env *testEnv) dial(t *testing.T) *websocket.Conn {
+	t.Helper()
+	return env.dialQuery(t, "")
+}
+
+func (env *testEnv) dialQuery(t *testing.T, query string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(env.url+query, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func authenticate(t *testing.T, conn *websocket.Conn, token string) {
+	t.Helper()
+	if err := conn.WriteJSON(TaskMessage{Action: "authenticate", Token: token}); err != nil {
+		t.Fatalf("write authenticate: %v", err)
+	}
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) TaskMessage {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("set read deadline: %v", err)
+	}
+	var msg TaskMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	return msg
+}
+
+func TestWebSocketDeliversInitialTasksAndUpdates(t *testing.T) {
+	env := startTestServer(t)
+
+	managerID := uuid.New()
+	otherManagerID := uuid.New()
+	env.repo.addManager(managerID, uuid.New(), uuid.New())
+	env.repo.addManager(otherManagerID, uuid.New(), uuid.New())
+
+	ownTask := TaskInfo{ID: uuid.New(), Name: "Write tests", Status: "Assigned", UpdateTime: time.Now()}
+	otherTask := TaskInfo{ID: uuid.New(), Name: "Someone else's", Status: "Assigned", UpdateTime: time.Now()}
+	env.repo.putTask(managerID, ownTask)
+	env.repo.putTask(otherManagerID, otherTask)
+
+	token := signManagerToken(t, managerID, "manager")
+	env.tokens[managerID] = token
+
+	conn := env.dial(t)
+	authenticate(t, conn, token)
+
+	if msg := readMessage(t, conn); msg.Action != "auth_success" {
+		t.Fatalf("expected auth_success, got %+v", msg)
+	}
+	msg := readMessage(t, conn)
+	if msg.Action != "task_update" || msg.TaskID != ownTask.ID {
+		t.Fatalf("expected initial task_update for %s, got %+v", ownTask.ID, msg)
+	}
+	if msg := readMessage(t, conn); msg.Action != "initial_tasks" {
+		t.Fatalf("expected initial_tasks, got %+v", msg)
+	}
+
+	// An event for a task of another manager must not reach this client, so
+	// the first message after both events is the update for its own task.
+	otherTask.Status = "Started"
+	env.repo.putTask(otherManagerID, otherTask)
+	env.events.publish(TaskEvent{Ctx: context.Background(), Action: "task_started", TaskID: otherTask.ID})
+
+	ownTask.Status = "Started"
+	ownTask.IsStarted = true
+	env.repo.putTask(managerID, ownTask)
+	env.events.publish(TaskEvent{Ctx: context.Background(), Action: "task_started", TaskID: ownTask.ID})
+
+	msg = readMessage(t, conn)
+	if msg.Action != "task_started" || msg.TaskID != ownTask.ID {
+		t.Fatalf("expected task_started for %s, got %+v", ownTask.ID, msg)
+	}
+	if msg.TaskData == nil || msg.TaskData.Status != "Started" || !msg.TaskData.IsStarted {
+		t.Fatalf("expected started task data, got %+v", msg.TaskData)
+	}
+}
+
+func TestWebSocketRejectsBadAuthentication(t *testing.T) {
+	env := startTestServer(t)
+
+	managerID := uuid.New()
+	env.repo.addManager(managerID, uuid.New(), uuid.New())
+	stale := signManagerToken(t, managerID, "manager")
+	// The gateway replaces the stored token on every login.
+	env.tokens[managerID] = "token-from-a-newer-login"
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"missing token", "", "Missing authentication"},
+		{"bad signature", stale + "x", "Invalid token"},
+		{"not a manager", signManagerToken(t, managerID, "developer"), "Invalid token"},
+		{"stale token", stale, "Token not found or expired"},
+		{"unknown manager", signManagerToken(t, uuid.New(), "manager"), "Token not found or expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := env.dial(t)
+			authenticate(t, conn, tt.token)
+			msg := readMessage(t, conn)
+			if msg.Action != "auth_error" || msg.Error != tt.want {
+				t.Fatalf("expected auth_error %q, got %+v", tt.want, msg)
+			}
+		})
+	}
+}
+
+func TestWebSocketVersion2AddsDurationFields(t *testing.T) {
+	env := startTestServer(t)
+
+	managerID := uuid.New()
+	env.repo.addManager(managerID, uuid.New(), uuid.New())
+	task := TaskInfo{ID: uuid.New(), Name: "Timed", TotalDuration: 90*time.Minute + 500*time.Millisecond, UpdateTime: time.Now()}
+	env.repo.putTask(managerID, task)
+	token := signManagerToken(t, managerID, "manager")
+	env.tokens[managerID] = token
+
+	legacy := env.dial(t)
+	authenticate(t, legacy, token)
+	readMessage(t, legacy)
+	if msg := readMessage(t, legacy); msg.TaskData.TotalDurationSeconds != nil || msg.TaskData.TotalDurationISO != "" {
+		t.Fatalf("version 1 message carries version 2 fields: %+v", msg.TaskData)
+	}
+
+	v2 := env.dialQuery(t, "?version=2")
+	authenticate(t, v2, token)
+	readMessage(t, v2)
+	msg := readMessage(t, v2)
+	if msg.TaskData.TotalDuration != task.TotalDuration {
+		t.Fatalf("legacy total_duration changed: %v", msg.TaskData.TotalDuration)
+	}
+	if msg.TaskData.TotalDurationSeconds == nil || *msg.TaskData.TotalDurationSeconds != 5400.5 {
+		t.Fatalf("total_duration_seconds = %v, want 5400.5", msg.TaskData.TotalDurationSeconds)
+	}
+	if msg.TaskData.TotalDurationISO != "PT1H30M0.5S" {
+		t.Fatalf("total_duration_iso = %q, want PT1H30M0.5S", msg.TaskData.TotalDurationISO)
+	}
+}
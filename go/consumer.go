@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-shared/rabbitmq"
+	"go-shared/telemetry"
+)
+
+// TaskEvent is a task status change published by the FastAPI service.
+// Ctx carries the producer's trace context.
+type TaskEvent struct {
+	Ctx    context.Context
+	Action string
+	TaskID uuid.UUID
+}
+
+// EventSource delivers task events to the server. Start must not block;
+// handle is called for one event at a time and the event counts as processed
+// once it returns.
+type EventSource interface {
+	Start(handle func(TaskEvent)) error
+	// Shutdown stops new deliveries and reports whether the in-flight event
+	// finished before ctx expired.
+	Shutdown(ctx context.Context) bool
+}
+
+const taskConsumerTag = "goservice-task-consumer"
+
+// taskConsumer is the EventSource backed by task_queue.
+type taskConsumer struct {
+	conn *amqp.Connection
+	ch   *amqp.Channel
+	done chan struct{}
+}
+
+func newTaskConsumer(ctx context.Context, cfg rabbitmq.Config) (*taskConsumer, error) {
+	logInfo("Starting RabbitMQ consumer")
+
+	conn, err := rabbitmq.Dial(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error opening channel: %w", err)
+	}
+	logInfo("RabbitMQ channel opened successfully")
+
+	return &taskConsumer{conn: conn, ch: ch, done: make(chan struct{})}, nil
+}
+
+func (c *taskConsumer) Start(handle func(TaskEvent)) error {
+	q, err := c.ch.QueueDeclare(
+		"task_queue",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("error declaring queue: %w", err)
+	}
+	logInfo("Queue 'task_queue' declared successfully")
+
+	// Deliveries are acknowledged only after they have been processed, so
+	// anything still unacknowledged at shutdown is redelivered by the broker.
+	if err = c.ch.Qos(1, 0, false); err != nil {
+		return fmt.Errorf("error setting channel QoS: %w", err)
+	}
+
+	messages, err := c.ch.Consume(
+		q.Name,
+		taskConsumerTag,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("error consuming messages: %w", err)
+	}
+	logInfo("Started consuming messages from task_queue")
+
+	go func() {
+		defer close(c.done)
+		for d := range messages {
+			processTaskMessage(d, handle)
+			if err := d.Ack(false); err != nil {
+				logError("Failed to acknowledge RabbitMQ message: %v", err)
+			}
+		}
+		logInfo("RabbitMQ delivery channel closed, consumer stopped")
+	}()
+
+	return nil
+}
+
+// Shutdown stops new deliveries, waits for the in-flight message to finish
+// and then closes the channel and connection.
+func (c *taskConsumer) Shutdown(ctx context.Context) bool {
+	logInfo("Cancelling RabbitMQ consumer")
+	if err := c.ch.Cancel(taskConsumerTag, false); err != nil {
+		logError("Error cancelling RabbitMQ consumer: %v", err)
+	}
+
+	drained := true
+	select {
+	case <-c.done:
+		logInfo("In-flight RabbitMQ messages processed")
+	case <-ctx.Done():
+		logWarn("Timed out waiting for in-flight RabbitMQ messages: %v", ctx.Err())
+		drained = false
+	}
+
+	if err := c.ch.Close(); err != nil {
+		logError("Error closing RabbitMQ channel: %v", err)
+	}
+	if err := c.conn.Close(); err != nil {
+		logError("Error closing RabbitMQ connection: %v", err)
+	}
+	return drained
+}
+
+func processTaskMessage(d amqp.Delivery, handle func(TaskEvent)) {
+	ctx := rabbitmq.ExtractTraceContext(context.Background(), d.Headers)
+	ctx, span := tracer.Start(ctx, "task_queue process", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", "task_queue"),
+		))
+	defer span.End()
+
+	logInfo("Received message from RabbitMQ queue: %s", string(d.Body))
+
+	var rabbitMsg map[string]any
+	if err := json.Unmarshal(d.Body, &rabbitMsg); err != nil {
+		logError("Failed to parse RabbitMQ message: %v", err)
+		telemetry.RecordError(span, err)
+		return
+	}
+
+	taskIDStr, ok := rabbitMsg["task_id"].(string)
+	if !ok {
+		logError("Invalid task_id in RabbitMQ message: %v", rabbitMsg)
+		return
+	}
+
+	taskID, err := uuid.Parse(taskIDStr)
+	if err != nil {
+		logError("Failed to parse task_id as UUID: %v", err)
+		telemetry.RecordError(span, err)
+		return
+	}
+
+	action, ok := rabbitMsg["action"].(string)
+	if !ok {
+		logError("Invalid action in RabbitMQ message: %v", rabbitMsg)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("task.id", taskID.String()),
+		attribute.String("task.action", action),
+	)
+	handle(TaskEvent{Ctx: ctx, Action: action, TaskID: taskID})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type managerAssignment struct {
+	teamID   uuid.UUID
+	sprintID uuid.UUID
+}
+
+// memoryTaskRepository is an in-memory TaskRepository. Tasks belong to the
+// manager they were added for.
+type memoryTaskRepository struct {
+	mu       sync.Mutex
+	managers map[uuid.UUID]managerAssignment
+	tasks    map[uuid.UUID]TaskInfo
+	owners   map[uuid.UUID]uuid.UUID
+	order    []uuid.UUID
+}
+
+func newMemoryTaskRepository() *memoryTaskRepository {
+	return &memoryTaskRepository{
+		managers: make(map[uuid.UUID]managerAssignment),
+		tasks:    make(map[uuid.UUID]TaskInfo),
+		owners:   make(map[uuid.UUID]uuid.UUID),
+	}
+}
+
+func (r *memoryTaskRepository) addManager(managerID, teamID, sprintID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.managers[managerID] = managerAssignment{teamID: teamID, sprintID: sprintID}
+}
+
+// putTask adds or replaces a task.
+func (r *memoryTaskRepository) putTask(managerID uuid.UUID, task TaskInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tasks[task.ID]; !ok {
+		r.order = append(r.order, task.ID)
+	}
+	r.tasks[task.ID] = task
+	r.owners[task.ID] = managerID
+}
+
+func (r *memoryTaskRepository) ManagerTeamAndSprint(_ context.Context, managerID uuid.UUID) (uuid.UUID, uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.managers[managerID]
+	if !ok {
+		return uuid.Nil, uuid.Nil, errors.New("no team found for manager")
+	}
+	return m.teamID, m.sprintID, nil
+}
+
+func (r *memoryTaskRepository) ManagerTasks(_ context.Context, managerID uuid.UUID) ([]TaskInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tasks []TaskInfo
+	for _, id := range r.order {
+		if r.owners[id] == managerID {
+			tasks = append(tasks, r.tasks[id])
+		}
+	}
+	return tasks, nil
+}
+
+func (r *memoryTaskRepository) TaskInfo(_ context.Context, taskID uuid.UUID) (*TaskInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task, ok := r.tasks[taskID]
+	if !ok {
+		return nil, errors.New("task not found")
+	}
+	return &task, nil
+}
+
+func (r *memoryTaskRepository) TaskBelongsToManager(_ context.Context, taskID, managerID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.owners[taskID] == managerID, nil
+}
+
+// memoryTokenStore accepts exactly the tokens it was given.
+type memoryTokenStore map[uuid.UUID]string
+
+func (s memoryTokenStore) ValidToken(_ context.Context, managerID uuid.UUID, token string) bool {
+	stored, ok := s[managerID]
+	return ok && stored == token
+}
+
+// fakeEventSource hands published events straight to the server.
+type fakeEventSource struct {
+	mu     sync.Mutex
+	handle func(TaskEvent)
+}
+
+func (f *fakeEventSource) Start(handle func(TaskEvent)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handle = handle
+	return nil
+}
+
+func (f *fakeEventSource) Shutdown(context.Context) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handle = nil
+	return true
+}
+
+// publish delivers ev and returns once the server has queued its broadcast.
+func (f *fakeEventSource) publish(ev TaskEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.handle != nil {
+		f.handle(ev)
+	}
+}
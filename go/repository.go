@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"go-shared/duration"
+	"go-shared/telemetry"
+)
+
+// TaskRepository is the data access used by the WebSocket handlers and the
+// task event handler.
+type TaskRepository interface {
+	// ManagerTeamAndSprint returns the manager's team and its current sprint.
+	// The sprint is uuid.Nil when the team has no sprint.
+	ManagerTeamAndSprint(ctx context.Context, managerID uuid.UUID) (teamID, sprintID uuid.UUID, err error)
+	ManagerTasks(ctx context.Context, managerID uuid.UUID) ([]TaskInfo, error)
+	TaskInfo(ctx context.Context, taskID uuid.UUID) (*TaskInfo, error)
+	TaskBelongsToManager(ctx context.Context, taskID, managerID uuid.UUID) (bool, error)
+}
+
+// postgresTaskRepository reads through the stored procedures in
+// DatabaseManager/Data/procedures.sql.
+type postgresTaskRepository struct {
+	db *gorm.DB
+}
+
+func newPostgresTaskRepository(db *gorm.DB) *postgresTaskRepository {
+	return &postgresTaskRepository{db: db}
+}
+
+func closeRows(rows *sql.Rows) {
+	if err := rows.Close(); err != nil {
+		logError("Error closing rows: %v", err)
+	}
+}
+
+func (r *postgresTaskRepository) ManagerTeamAndSprint(ctx context.Context, managerID uuid.UUID) (uuid.UUID, uuid.UUID, error) {
+	logDebug("Getting team and sprint for manager ID: %s using procedure", managerID)
+
+	rows, err := r.db.WithContext(ctx).Raw("SELECT * FROM GetManagerTeamAndSprint(?)", managerID.String()).Rows()
+	if err != nil {
+		logError("Failed to call GetManagerTeamAndSprint procedure for manager %s: %v", managerID, err)
+		return uuid.Nil, uuid.Nil, fmt.Errorf("failed to call GetManagerTeamAndSprint procedure: %v", err)
+	}
+	defer closeRows(rows)
+
+	if !rows.Next() {
+		logWarn("No team found for manager ID: %s", managerID)
+		return uuid.Nil, uuid.Nil, fmt.Errorf("no team found for manager")
+	}
+
+	var teamIDStr, sprintIDStr string
+	err = rows.Scan(&teamIDStr, &sprintIDStr)
+	if err != nil {
+		logError("Failed to scan team and sprint data: %v", err)
+		return uuid.Nil, uuid.Nil, fmt.Errorf("failed to scan team and sprint data: %v", err)
+	}
+
+	teamID, err := uuid.Parse(teamIDStr)
+	if err != nil {
+		logError("Failed to parse team ID as UUID: %v", err)
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid team ID format: %v", err)
+	}
+
+	var sprintID uuid.UUID
+	if sprintIDStr != "" {
+		sprintID, err = uuid.Parse(sprintIDStr)
+		if err != nil {
+			logError("Failed to parse sprint ID as UUID: %v", err)
+			return teamID, uuid.Nil, fmt.Errorf("invalid sprint ID format: %v", err)
+		}
+	} else {
+		logWarn("No sprint found for manager's team %s", teamID)
+		return teamID, uuid.Nil, nil
+	}
+
+	logInfo("Found team ID: %s, sprint ID: %s for manager ID: %s", teamID, sprintID, managerID)
+	return teamID, sprintID, nil
+}
+
+func (r *postgresTaskRepository) ManagerTasks(ctx context.Context, managerID uuid.UUID) ([]TaskInfo, error) {
+	logInfo("Getting tasks for manager ID: %s using optimized procedure", managerID)
+
+	rows, err := r.db.WithContext(ctx).Raw("SELECT * FROM GetManagerTasksWithDetails(?)", managerID.String()).Rows()
+	if err != nil {
+		logError("Failed to call GetManagerTasksWithDetails procedure for manager %s: %v", managerID, err)
+		return nil, fmt.Errorf("failed to call GetManagerTasksWithDetails procedure: %v", err)
+	}
+	defer closeRows(rows)
+
+	var taskInfos []TaskInfo
+
+	for rows.Next() {
+		var taskID, sprintID, teamID, taskName, currentStatus, developerName string
+		var totalInterval duration.Interval
+		var isStarted, isPaused, isStopped bool
+		var startTime, updateTime *time.Time
+
+		err := rows.Scan(&taskID, &taskName, &totalInterval, &isStarted, &isPaused, &isStopped,
+			&currentStatus, &developerName, &startTime, &updateTime, &sprintID, &teamID)
+		if err != nil {
+			logError("Failed to scan task row: %v", err)
+			continue
+		}
+
+		taskUUID, err := uuid.Parse(taskID)
+		if err != nil {
+			logError("Failed to parse task ID as UUID: %v", err)
+			continue
+		}
+
+		totalDuration, err := totalInterval.Duration()
+		if err != nil {
+			logError("Failed to convert duration for task %s: %v", taskID, err)
+			continue
+		}
+
+		taskInfo := TaskInfo{
+			ID:            taskUUID,
+			Name:          taskName,
+			TotalDuration: totalDuration,
+			InProgress:    isStarted,
+			DeveloperName: developerName,
+			Status:        currentStatus,
+			IsStarted:     isStarted,
+			IsPaused:      isPaused,
+			IsStopped:     isStopped,
+			StartTime:     startTime,
+			UpdateTime:    *updateTime,
+		}
+
+		taskInfos = append(taskInfos, taskInfo)
+
+		logDebug("Task %s (%s) - Duration: %v, IsStarted: %t, IsPaused: %t, IsStopped: %t, Developer: %s, Status: %s",
+			taskUUID, taskName, totalDuration, isStarted, isPaused, isStopped, developerName, currentStatus)
+	}
+
+	if err = rows.Err(); err != nil {
+		logError("Error iterating over task rows: %v", err)
+		return nil, fmt.Errorf("error iterating over task rows: %v", err)
+	}
+
+	logInfo("Successfully retrieved %d tasks for manager %s", len(taskInfos), managerID)
+	return taskInfos, nil
+}
+
+func (r *postgresTaskRepository) TaskInfo(ctx context.Context, taskID uuid.UUID) (_ *TaskInfo, err error) {
+	ctx, span := tracer.Start(ctx, "getTaskInfo", trace.WithAttributes(
+		attribute.String("task.id", taskID.String()),
+	))
+	defer func() {
+		if err != nil {
+			telemetry.RecordError(span, err)
+		}
+		span.End()
+	}()
+
+	logDebug("Getting task info for task ID: %s using optimized procedure", taskID)
+
+	rows, err := r.db.WithContext(ctx).Raw("SELECT * FROM GetSingleTaskDetails(?)", taskID.String()).Rows()
+	if err != nil {
+		logError("Failed to call GetSingleTaskDetails procedure for task %s: %v", taskID, err)
+		return nil, fmt.Errorf("failed to call GetSingleTaskDetails procedure: %v", err)
+	}
+	defer closeRows(rows)
+
+	if !rows.Next() {
+		logWarn("Task %s not found", taskID)
+		return nil, fmt.Errorf("task not found")
+	}
+
+	var taskIDStr, sprintID, teamID, managerID, taskName, currentStatus, developerName string
+	var totalInterval duration.Interval
+	var isStarted, isPaused, isStopped bool
+	var startTime, updateTime *time.Time
+
+	err = rows.Scan(&taskIDStr, &taskName, &totalInterval, &isStarted, &isPaused, &isStopped,
+		&currentStatus, &developerName, &startTime, &updateTime, &sprintID, &teamID, &managerID)
+	if err != nil {
+		logError("Failed to scan task row: %v", err)
+		return nil, fmt.Errorf("failed to scan task row: %v", err)
+	}
+
+	taskUUID, err := uuid.Parse(taskIDStr)
+	if err != nil {
+		logError("Failed to parse task ID as UUID: %v", err)
+		return nil, fmt.Errorf("invalid task ID format: %v", err)
+	}
+
+	totalDuration, err := totalInterval.Duration()
+	if err != nil {
+		logError("Failed to convert duration for task %s: %v", taskID, err)
+		return nil, fmt.Errorf("invalid total duration for task %s: %w", taskID, err)
+	}
+
+	taskInfo := TaskInfo{
+		ID:            taskUUID,
+		Name:          taskName,
+		TotalDuration: totalDuration,
+		InProgress:    isStarted,
+		DeveloperName: developerName,
+		Status:        currentStatus,
+		IsStarted:     isStarted,
+		IsPaused:      isPaused,
+		IsStopped:     isStopped,
+		StartTime:     startTime,
+		UpdateTime:    *updateTime,
+	}
+
+	logInfo("Retrieved task info: %s (%s) - Duration: %v, Status: %s, Developer: %s",
+		taskID, taskName, totalDuration, currentStatus, developerName)
+	return &taskInfo, nil
+}
+
+func (r *postgresTaskRepository) TaskBelongsToManager(ctx context.Context, taskID, managerID uuid.UUID) (bool, error) {
+	logDebug("Checking if task %s belongs to manager %s", taskID, managerID)
+
+	var belongs bool
+	err := r.db.WithContext(ctx).Raw("SELECT CheckTaskBelongsToManager(?, ?)", taskID.String(), managerID.String()).Row().Scan(&belongs)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if task belongs to manager: %v", err)
+	}
+
+	logDebug("Task %s belongs to manager %s: %t", taskID, managerID, belongs)
+	return belongs, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// TokenStore confirms that a JWT is still the current session token for a
+// manager. The API gateway stores it on login and deletes it on logout.
+type TokenStore interface {
+	ValidToken(ctx context.Context, managerID uuid.UUID, token string) bool
+}
+
+type redisTokenStore struct {
+	client *redis.Client
+}
+
+func newRedisTokenStore(client *redis.Client) *redisTokenStore {
+	return &redisTokenStore{client: client}
+}
+
+func (s *redisTokenStore) ValidToken(ctx context.Context, managerID uuid.UUID, token string) bool {
+	redisKey := fmt.Sprintf("manager_token:%s", managerID.String())
+
+	logDebug("Checking token in Redis for manager ID: %s", managerID)
+
+	storedToken, err := s.client.Get(ctx, redisKey).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			logWarn("Token not found in Redis for manager ID: %s", managerID)
+		} else {
+			logError("Redis error while checking token: %v", err)
+		}
+		return false
+	}
+
+	if storedToken == token {
+		logInfo("Token validated successfully from Redis for manager ID: %s", managerID)
+		return true
+	}
+
+	logWarn("Token mismatch in Redis for manager ID: %s", managerID)
+	return false
+}
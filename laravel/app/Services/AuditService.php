@@ -4,6 +4,7 @@
 
 use PhpAmqpLib\Connection\AMQPStreamConnection;
 use PhpAmqpLib\Message\AMQPMessage;
+use PhpAmqpLib\Wire\AMQPTable;
 use Exception;
 use Illuminate\Support\Facades\Log;
 
@@ -49,7 +50,10 @@ public function logAction(string $action, string $entity, string $description):
 
             $message = new AMQPMessage(
                 json_encode($auditMessage),
-                ['content_type' => 'application/json']
+                [
+                    'content_type' => 'application/json',
+                    'application_headers' => new AMQPTable(['traceparent' => $this->traceparent()]),
+                ]
             );
 
             $this->channel->basic_publish($message, 'audit_logs', 'audit.laravelservice');
@@ -60,6 +64,22 @@ public function logAction(string $action, string $entity, string $description):
         }
     }
 
+    /**
+     * W3C traceparent for the published message: the trace of the incoming
+     * request with a new span id, or a new trace outside a request.
+     */
+    private function traceparent(): string
+    {
+        $incoming = app()->runningInConsole() ? null : request()->header('traceparent');
+        if (is_string($incoming)
+            && preg_match('/^00-([0-9a-f]{32})-[0-9a-f]{16}-([0-9a-f]{2})$/', strtolower(trim($incoming)), $m)
+            && $m[1] !== str_repeat('0', 32)) {
+            return "00-{$m[1]}-" . bin2hex(random_bytes(8)) . "-{$m[2]}";
+        }
+
+        return '00-' . bin2hex(random_bytes(16)) . '-' . bin2hex(random_bytes(8)) . '-01';
+    }
+
     public function __destruct()
     {
         try {
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"time"
+	_ "time/tzdata" // the alpine image has no zoneinfo for ?tz=
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-shared/apierror"
+)
+
+const statusCompleted = "Completed"
+
+// BurndownDay is the state of a sprint at the end of one day, or now for the
+// current day. Completed and Remaining are null for days still to come.
+type BurndownDay struct {
+	Date      string  `json:"date"`
+	Scope     int     `json:"scope"`
+	Added     int     `json:"added"`
+	Completed *int    `json:"completed"`
+	Remaining *int    `json:"remaining"`
+	Ideal     float64 `json:"ideal"`
+}
+
+// Burndown holds the series for burndown (Remaining against Ideal) and
+// burnup (Completed against Scope) charts.
+type Burndown struct {
+	SprintID     uuid.UUID     `json:"sprintId"`
+	SprintName   string        `json:"sprintName"`
+	StartDate    string        `json:"startDate"`
+	EndDate      string        `json:"endDate"`
+	Timezone     string        `json:"timezone"`
+	InitialScope int           `json:"initialScope"`
+	Scope        int           `json:"scope"`
+	Days         []BurndownDay `json:"days"`
+}
+
+// burndownEvent is one status change of a task in the sprint.
+type burndownEvent struct {
+	At     time.Time
+	Status string
+}
+
+// burndownMove is a row of TaskSprintChanges: the task moved into or out of
+// the sprint, or was deleted.
+type burndownMove struct {
+	At  time.Time
+	Was bool // in the sprint before the move
+	In  bool // in the sprint after the move
+}
+
+// burndownTask is a task that is or was in the sprint, with its history and
+// its moves in ChangeDate order.
+type burndownTask struct {
+	Events []burndownEvent
+	Moves  []burndownMove
+}
+
+// inSprintBefore reports whether the task was in the sprint just before end.
+// The last move before end decides, and a task created in the sprint has one
+// from its creation. Only tasks that predate TaskSprintChanges, and so were
+// in the sprint before their first move or have none, fall back to their
+// first history row, or to the start without history.
+func (t burndownTask) inSprintBefore(end time.Time) bool {
+	for i := len(t.Moves) - 1; i >= 0; i-- {
+		if t.Moves[i].At.Before(end) {
+			return t.Moves[i].In
+		}
+	}
+	if len(t.Moves) > 0 && !t.Moves[0].Was {
+		return false
+	}
+	return len(t.Events) == 0 || t.Events[0].At.Before(end)
+}
+
+func (t burndownTask) completedBefore(end time.Time) bool {
+	status := ""
+	for _, e := range t.Events {
+		if !e.At.Before(end) {
+			break
+		}
+		status = e.Status
+	}
+	return status == statusCompleted
+}
+
+// buildBurndown computes one entry per calendar day in loc from start to end
+// inclusive. A task counts towards the scope while it is in the sprint, so
+// tasks created or moved in during the sprint show up as Added on that day
+// and tasks moved out or deleted as a negative Added; the scope at the end of
+// the first day is the initial scope the ideal line burns down from.
+func buildBurndown(tasks []burndownTask, start, end time.Time, loc *time.Location, now time.Time) (int, []BurndownDay) {
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	last := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+
+	var days []BurndownDay
+	initial, previousScope := 0, 0
+	for day := first; !day.After(last); day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc) {
+		dayEnd := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+		entry := BurndownDay{Date: day.Format("2006-01-02")}
+
+		completed := 0
+		for _, t := range tasks {
+			if t.inSprintBefore(dayEnd) {
+				entry.Scope++
+				if t.completedBefore(dayEnd) {
+					completed++
+				}
+			}
+		}
+		if len(days) == 0 {
+			initial = entry.Scope
+		} else {
+			entry.Added = entry.Scope - previousScope
+		}
+		previousScope = entry.Scope
+
+		if day.Before(now) {
+			remaining := entry.Scope - completed
+			entry.Completed, entry.Remaining = &completed, &remaining
+		}
+		days = append(days, entry)
+	}
+
+	// The ideal line falls linearly from the initial scope at the end of the
+	// first day to zero at the end of the last.
+	for i := range days {
+		ideal := 0.0
+		if len(days) > 1 {
+			ideal = float64(initial) * float64(len(days)-1-i) / float64(len(days)-1)
+		}
+		days[i].Ideal = math.Round(ideal*100) / 100
+	}
+	return initial, days
+}
+
+// burndownTaskIDs selects every task now in the sprint or moved in or out of
+// it at some point, including deleted ones.
+const burndownTaskIDs = `
+		SELECT "Id" FROM "Tasks" WHERE "SprintId" = @sprint
+		UNION
+		SELECT "TaskId" FROM "TaskSprintChanges"
+		WHERE "OldSprintId" = @sprint OR "NewSprintId" = @sprint`
+
+// loadBurndownTasks reads the history up to before and every sprint move of
+// each task that is or was in the sprint.
+func loadBurndownTasks(tx *gorm.DB, sprintID uuid.UUID, before time.Time) ([]burndownTask, error) {
+	rows, err := tx.Raw(`
+		SELECT t."Id", h."ChangeDate", h."NewStatus"
+		FROM (`+burndownTaskIDs+`) t
+		LEFT JOIN "TaskHistories" h ON h."TaskId" = t."Id" AND h."ChangeDate" < @before
+		ORDER BY t."Id", h."ChangeDate", h."Id"`,
+		sql.Named("sprint", sprintID), sql.Named("before", before)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}(rows)
+
+	var tasks []burndownTask
+	index := map[uuid.UUID]int{}
+	for rows.Next() {
+		var id uuid.UUID
+		var changeDate sql.NullTime
+		var status sql.NullString
+		if err := rows.Scan(&id, &changeDate, &status); err != nil {
+			return nil, err
+		}
+		i, ok := index[id]
+		if !ok {
+			i = len(tasks)
+			index[id] = i
+			tasks = append(tasks, burndownTask{})
+		}
+		if changeDate.Valid {
+			tasks[i].Events = append(tasks[i].Events, burndownEvent{At: changeDate.Time, Status: status.String})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Moves after before still matter: a task moved in later was not in the
+	// sprint before it.
+	moves, err := tx.Raw(`
+		SELECT "TaskId", "ChangeDate",
+			COALESCE("OldSprintId" = @sprint, false),
+			COALESCE("NewSprintId" = @sprint, false)
+		FROM "TaskSprintChanges"
+		WHERE "TaskId" IN (`+burndownTaskIDs+`)
+		ORDER BY "TaskId", "ChangeDate", "Id"`, sql.Named("sprint", sprintID)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}(moves)
+
+	for moves.Next() {
+		var id uuid.UUID
+		var move burndownMove
+		if err := moves.Scan(&id, &move.At, &move.Was, &move.In); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			tasks[i].Moves = append(tasks[i].Moves, move)
+		}
+	}
+	return tasks, moves.Err()
+}
+
+// GET /api/reports/sprints/:id/burndown?tz={IANA zone}
+//
+// Daily burndown and burnup series for a sprint between its start and end
+// dates, with days cut at midnight in tz (UTC by default).
+//
+// The series covers every task that was in the sprint at some point.
+// Creation in the sprint, moves between sprints and deletions come from
+// TaskSprintChanges, so scope rises when a task is created or moved in and
+// falls when it is moved out or deleted. Tasks older than that record count
+// from their first history row.
+func getSprintBurndown(c *gin.Context) {
+	var v apierror.Validation
+	sprintID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		v.Add("id", "not a valid UUID: "+c.Param("id"))
+	}
+	loc := time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			v.Add("tz", "unknown time zone: "+tz)
+		}
+	}
+	if apiErr := v.Err("Invalid request"); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	result, apiErr := loadSprintBurndown(db.WithContext(c.Request.Context()), sprintID, loc)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	writeReport(c, ApiResponse{
+		Message: "Sprint burndown retrieved",
+		Data:    result,
+	})
+}
+
+// loadSprintBurndown loads the sprint and builds its burndown with days cut
+// at midnight in loc. The burndown report and chart both use it.
+func loadSprintBurndown(tx *gorm.DB, sprintID uuid.UUID, loc *time.Location) (Burndown, *apierror.Error) {
+	var sprint struct {
+		Name      string
+		StartDate time.Time
+		EndDate   time.Time
+	}
+	err := tx.Raw(`SELECT "Name", "StartDate", "EndDate" FROM "Sprints" WHERE "Id" = ?`, sprintID).
+		Row().Scan(&sprint.Name, &sprint.StartDate, &sprint.EndDate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Burndown{}, apierror.NotFound("Sprint not found")
+	}
+	if err != nil {
+		return Burndown{}, apierror.Internal("Failed to load sprint", err)
+	}
+
+	// Sprint dates are calendar dates; read them in the requested zone.
+	start := time.Date(sprint.StartDate.Year(), sprint.StartDate.Month(), sprint.StartDate.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(sprint.EndDate.Year(), sprint.EndDate.Month(), sprint.EndDate.Day(), 0, 0, 0, 0, loc)
+	result := Burndown{
+		SprintID:   sprintID,
+		SprintName: sprint.Name,
+		StartDate:  start.Format("2006-01-02"),
+		EndDate:    end.Format("2006-01-02"),
+		Timezone:   loc.String(),
+		Days:       []BurndownDay{},
+	}
+	if end.Before(start) {
+		return result, nil
+	}
+
+	tasks, err := loadBurndownTasks(tx, sprintID, end.AddDate(0, 0, 1))
+	if err != nil {
+		return Burndown{}, apierror.Internal("Failed to load sprint tasks", err)
+	}
+	result.InitialScope, result.Days = buildBurndown(tasks, start, end, loc, time.Now())
+	result.Scope = result.Days[len(result.Days)-1].Scope
+	return result, nil
+}
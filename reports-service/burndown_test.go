@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildBurndown(t *testing.T) {
+	start := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2)
+	at := func(day, hour int) time.Time { return start.Add(time.Duration(day*24+hour) * time.Hour) }
+	events := func(day int, statuses ...string) []burndownEvent {
+		var e []burndownEvent
+		for i, s := range statuses {
+			e = append(e, burndownEvent{At: at(day, 9+i), Status: s})
+		}
+		return e
+	}
+	open := burndownTask{Events: events(0, "Created")}
+
+	tests := []struct {
+		name          string
+		task          burndownTask
+		wantScope     []int
+		wantCompleted []int
+	}{
+		{"present from the start", open, []int{1, 1, 1}, []int{0, 0, 0}},
+		{"no history", burndownTask{}, []int{1, 1, 1}, []int{0, 0, 0}},
+		{"added on day two", burndownTask{Events: events(1, "Created")}, []int{0, 1, 1}, []int{0, 0, 0}},
+		{"completed on day two", burndownTask{Events: append(events(0, "Created", "Started"),
+			burndownEvent{At: at(1, 10), Status: statusCompleted})}, []int{1, 1, 1}, []int{0, 1, 1}},
+		{"reopened on day three", burndownTask{Events: append(events(0, "Created", "Completed"),
+			burndownEvent{At: at(2, 10), Status: "Started"})}, []int{1, 1, 1}, []int{1, 1, 0}},
+		{"created on day two without history", burndownTask{
+			Moves: []burndownMove{{At: at(1, 12), Was: false, In: true}}}, []int{0, 1, 1}, []int{0, 0, 0}},
+		{"created on day two, completed on day three", burndownTask{Events: events(2, "Created", "Started", statusCompleted),
+			Moves: []burndownMove{{At: at(1, 12), In: true}}}, []int{0, 1, 1}, []int{0, 0, 1}},
+		{"moved in on day two", burndownTask{Events: open.Events,
+			Moves: []burndownMove{{At: at(1, 12), Was: false, In: true}}}, []int{0, 1, 1}, []int{0, 0, 0}},
+		{"moved out on day two", burndownTask{Events: open.Events,
+			Moves: []burndownMove{{At: at(1, 12), Was: true, In: false}}}, []int{1, 0, 0}, []int{0, 0, 0}},
+		{"moved out and back", burndownTask{Events: open.Events,
+			Moves: []burndownMove{{At: at(0, 12), Was: true}, {At: at(2, 12), In: true}}}, []int{0, 0, 1}, []int{0, 0, 0}},
+		{"moved in after the sprint", burndownTask{Events: open.Events,
+			Moves: []burndownMove{{At: at(5, 0), In: true}}}, []int{0, 0, 0}, []int{0, 0, 0}},
+		{"deleted on day two", burndownTask{
+			Moves: []burndownMove{{At: at(1, 12), Was: true}}}, []int{1, 0, 0}, []int{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initial, days := buildBurndown([]burndownTask{tt.task}, start, end, time.UTC, at(10, 0))
+			var scope, completed []int
+			for _, d := range days {
+				scope = append(scope, d.Scope)
+				completed = append(completed, *d.Completed)
+			}
+			if !slices.Equal(scope, tt.wantScope) {
+				t.Errorf("scope = %v, want %v", scope, tt.wantScope)
+			}
+			if !slices.Equal(completed, tt.wantCompleted) {
+				t.Errorf("completed = %v, want %v", completed, tt.wantCompleted)
+			}
+			if initial != tt.wantScope[0] {
+				t.Errorf("initial scope = %d, want %d", initial, tt.wantScope[0])
+			}
+			for i := 1; i < len(days); i++ {
+				if want := tt.wantScope[i] - tt.wantScope[i-1]; days[i].Added != want {
+					t.Errorf("day %d added = %d, want %d", i, days[i].Added, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildBurndownFutureDays(t *testing.T) {
+	start := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	tasks := []burndownTask{{}, {}}
+	initial, days := buildBurndown(tasks, start, start.AddDate(0, 0, 4), time.UTC, start.Add(30*time.Hour))
+	if initial != 2 || len(days) != 5 {
+		t.Fatalf("initial = %d, days = %d, want 2 and 5", initial, len(days))
+	}
+	for i, d := range days {
+		if (d.Remaining != nil) != (i < 2) {
+			t.Errorf("day %d remaining = %v", i, d.Remaining)
+		}
+	}
+	if ideal := []float64{days[0].Ideal, days[2].Ideal, days[4].Ideal}; !slices.Equal(ideal, []float64{2, 1, 0}) {
+		t.Errorf("ideal = %v, want [2 1 0]", ideal)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Chart geometry in pixels, the same for SVG user units and PNG pixels.
+const (
+	chartWidth        = 800
+	chartHeight       = 400
+	chartMarginLeft   = 60
+	chartMarginRight  = 20
+	chartMarginTop    = 50
+	chartMarginBottom = 50
+	chartYTicks       = 5
+)
+
+type seriesKind int
+
+const (
+	seriesBars seriesKind = iota
+	seriesLine
+)
+
+// chartSeries is one set of values, one per chart label. NaN values are
+// left out: no bar, and a gap in a line.
+type chartSeries struct {
+	Name   string
+	Kind   seriesKind
+	Values []float64
+	Color  color.RGBA
+	Dashed bool
+}
+
+// chart is a bar and line chart over categorical labels, drawn the same way
+// as SVG and PNG so emailed images match the exported ones.
+type chart struct {
+	Title   string
+	Labels  []string
+	Series  []chartSeries
+	Percent bool
+}
+
+var (
+	chartBlue   = color.RGBA{0x2b, 0x6c, 0xb0, 0xff}
+	chartGreen  = color.RGBA{0x2f, 0x85, 0x5a, 0xff}
+	chartOrange = color.RGBA{0xdd, 0x6b, 0x20, 0xff}
+	chartGray   = color.RGBA{0x71, 0x80, 0x96, 0xff}
+	chartGrid   = color.RGBA{0xe2, 0xe8, 0xf0, 0xff}
+	chartText   = color.RGBA{0x2d, 0x37, 0x48, 0xff}
+)
+
+type textAnchor int
+
+const (
+	anchorStart textAnchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+type point struct{ X, Y float64 }
+
+// canvas is what a chart is drawn on.
+type canvas interface {
+	Rect(x, y, w, h float64, c color.RGBA)
+	// Polyline strokes a line through points.
+	Polyline(points []point, c color.RGBA, width float64, dashed bool)
+	Text(x, y float64, s string, anchor textAnchor, c color.RGBA)
+}
+
+// chartAxisMax rounds top up to 1, 2 or 5 times a power of ten, so the
+// axis ticks fall on round numbers.
+func chartAxisMax(top float64) float64 {
+	if top <= 0 || math.IsNaN(top) {
+		return 1
+	}
+	step := top / chartYTicks
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*magnitude >= step {
+			return m * magnitude * chartYTicks
+		}
+	}
+	return top
+}
+
+func (ch chart) formatValue(v float64) string {
+	if ch.Percent {
+		return strconv.FormatFloat(v*100, 'f', -1, 64) + "%"
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// draw lays the chart out on cv: title and legend above, y axis on the
+// left, one slot per label with the bar series side by side in it and the
+// line series through its middle.
+func (ch chart) draw(cv canvas) {
+	cv.Rect(0, 0, chartWidth, chartHeight, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	cv.Text(chartMarginLeft, 24, ch.Title, anchorStart, chartText)
+
+	// Legend, right aligned on the title line.
+	x := float64(chartWidth - chartMarginRight)
+	for i := len(ch.Series) - 1; i >= 0; i-- {
+		s := ch.Series[i]
+		x -= 7 * float64(len(s.Name))
+		cv.Text(x, 24, s.Name, anchorStart, chartText)
+		x -= 18
+		cv.Rect(x, 15, 12, 10, s.Color)
+		x -= 14
+	}
+
+	plotW := float64(chartWidth - chartMarginLeft - chartMarginRight)
+	plotH := float64(chartHeight - chartMarginTop - chartMarginBottom)
+	bottom := float64(chartHeight - chartMarginBottom)
+
+	top := 0.0
+	for _, s := range ch.Series {
+		for _, v := range s.Values {
+			if !math.IsNaN(v) {
+				top = math.Max(top, v)
+			}
+		}
+	}
+	axisMax := chartAxisMax(top)
+	y := func(v float64) float64 { return bottom - v/axisMax*plotH }
+
+	for i := 0; i <= chartYTicks; i++ {
+		v := axisMax * float64(i) / chartYTicks
+		cv.Rect(chartMarginLeft, y(v), plotW, 1, chartGrid)
+		cv.Text(chartMarginLeft-6, y(v)+4, ch.formatValue(v), anchorEnd, chartText)
+	}
+
+	if len(ch.Labels) == 0 {
+		cv.Text(chartMarginLeft+plotW/2, chartMarginTop+plotH/2, "No data", anchorMiddle, chartGray)
+		return
+	}
+
+	slot := plotW / float64(len(ch.Labels))
+	// Label every slot, or every few when slots are narrower than 28px,
+	// cut to fit at about 7px per character.
+	every := max(int(math.Ceil(28/slot)), 1)
+	maxChars := int(slot*float64(every)/7) - 1
+	for i, label := range ch.Labels {
+		if i%every != 0 {
+			continue
+		}
+		if runes := []rune(label); len(runes) > maxChars {
+			label = string(runes[:max(maxChars-3, 1)]) + "..."
+		}
+		cv.Text(chartMarginLeft+slot*(float64(i)+0.5), bottom+18, label, anchorMiddle, chartText)
+	}
+
+	var bars []chartSeries
+	for _, s := range ch.Series {
+		if s.Kind == seriesBars {
+			bars = append(bars, s)
+		}
+	}
+	if len(bars) > 0 {
+		barW := slot * 0.8 / float64(len(bars))
+		for b, s := range bars {
+			for i, v := range s.Values {
+				if math.IsNaN(v) || v <= 0 {
+					continue
+				}
+				left := chartMarginLeft + slot*float64(i) + slot*0.1 + barW*float64(b)
+				cv.Rect(left, y(v), barW, bottom-y(v), s.Color)
+			}
+		}
+	}
+
+	for _, s := range ch.Series {
+		if s.Kind != seriesLine {
+			continue
+		}
+		var run []point
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				if len(run) > 0 {
+					cv.Polyline(run, s.Color, 2, s.Dashed)
+				}
+				run = nil
+				continue
+			}
+			run = append(run, point{chartMarginLeft + slot*(float64(i)+0.5), y(v)})
+		}
+		if len(run) > 0 {
+			cv.Polyline(run, s.Color, 2, s.Dashed)
+		}
+	}
+
+	cv.Rect(chartMarginLeft, bottom, plotW, 1, chartGray)
+}
+
+// svgCanvas collects SVG elements.
+type svgCanvas struct {
+	body bytes.Buffer
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (s *svgCanvas) Rect(x, y, w, h float64, c color.RGBA) {
+	fmt.Fprintf(&s.body, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, svgColor(c))
+}
+
+func (s *svgCanvas) Polyline(points []point, c color.RGBA, width float64, dashed bool) {
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%.1f,%.1f", p.X, p.Y)
+	}
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="6 4"`
+	}
+	fmt.Fprintf(&s.body, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%g"%s/>`+"\n",
+		strings.Join(coords, " "), svgColor(c), width, dash)
+	if len(points) == 1 {
+		fmt.Fprintf(&s.body, `<circle cx="%.1f" cy="%.1f" r="%g" fill="%s"/>`+"\n", points[0].X, points[0].Y, width+1, svgColor(c))
+	}
+}
+
+func (s *svgCanvas) Text(x, y float64, text string, anchor textAnchor, c color.RGBA) {
+	anchors := [...]string{"start", "middle", "end"}
+	fmt.Fprintf(&s.body, `<text x="%.1f" y="%.1f" text-anchor="%s" fill="%s">%s</text>`+"\n",
+		x, y, anchors[anchor], svgColor(c), xmlEscape(text))
+}
+
+// SVG renders the chart as a standalone SVG document.
+func (ch chart) SVG() []byte {
+	var s svgCanvas
+	ch.draw(&s)
+	var out bytes.Buffer
+	fmt.Fprintf(&out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Helvetica, Arial, sans-serif" font-size="12">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&out, "<title>%s</title>\n", xmlEscape(ch.Title))
+	out.Write(s.body.Bytes())
+	out.WriteString("</svg>\n")
+	return out.Bytes()
+}
+
+// pngCanvas rasterizes without anti-aliasing, with the 7x13 bitmap font.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (p *pngCanvas) Rect(x, y, w, h float64, c color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	if r.Dy() == 0 {
+		r.Max.Y++
+	}
+	draw.Draw(p.img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func (p *pngCanvas) Polyline(points []point, c color.RGBA, width float64, dashed bool) {
+	half := int(width / 2)
+	dot := func(x, y int) {
+		for dx := -half; dx <= half; dx++ {
+			for dy := -half; dy <= half; dy++ {
+				p.img.SetRGBA(x+dx, y+dy, c)
+			}
+		}
+	}
+	if len(points) == 1 {
+		// A marker as large as the SVG circle.
+		r := int(width) + 1
+		x, y := int(points[0].X), int(points[0].Y)
+		draw.Draw(p.img, image.Rect(x-r, y-r, x+r+1, y+r+1), image.NewUniform(c), image.Point{}, draw.Src)
+		return
+	}
+	travelled := 0.0
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		steps := int(math.Ceil(length))
+		for step := 0; step <= steps; step++ {
+			t := float64(step) / math.Max(float64(steps), 1)
+			// Dashes of 6px with gaps of 4px, as in the SVG.
+			if dashed && math.Mod(travelled+t*length, 10) >= 6 {
+				continue
+			}
+			dot(int(math.Round(a.X+t*(b.X-a.X))), int(math.Round(a.Y+t*(b.Y-a.Y))))
+		}
+		travelled += length
+	}
+}
+
+func (p *pngCanvas) Text(x, y float64, text string, anchor textAnchor, c color.RGBA) {
+	d := font.Drawer{Dst: p.img, Src: image.NewUniform(c), Face: basicfont.Face7x13}
+	width := d.MeasureString(text).Round()
+	switch anchor {
+	case anchorMiddle:
+		x -= float64(width) / 2
+	case anchorEnd:
+		x -= float64(width)
+	}
+	d.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+	d.DrawString(text)
+}
+
+// PNG renders the chart as a PNG image. The bitmap font covers ASCII only;
+// other characters show as boxes.
+func (ch chart) PNG() ([]byte, error) {
+	p := pngCanvas{img: image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))}
+	ch.draw(&p)
+	var out bytes.Buffer
+	if err := png.Encode(&out, p.img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
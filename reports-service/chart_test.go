@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testChart() chart {
+	return chart{
+		Title:  "Velocity <test>",
+		Labels: []string{"Sprint 1", "Sprint 2", "A very long sprint name indeed", "Sprint 4"},
+		Series: []chartSeries{
+			{Name: "Completed", Kind: seriesBars, Values: []float64{3, 7, 5, 9}, Color: chartBlue},
+			{Name: "Average", Kind: seriesLine, Values: []float64{3, 5, math.NaN(), 7}, Color: chartOrange, Dashed: true},
+		},
+	}
+}
+
+func TestChartAxisMax(t *testing.T) {
+	for top, want := range map[float64]float64{0: 1, 3: 5, 9: 10, 11: 25, 0.76: 1, 230: 250, 4100: 5000} {
+		if got := chartAxisMax(top); math.Abs(got-want) > 1e-9 {
+			t.Errorf("chartAxisMax(%v) = %v, want %v", top, got, want)
+		}
+	}
+}
+
+func TestChartSVG(t *testing.T) {
+	svg := testChart().SVG()
+	if err := xml.Unmarshal(svg, new(struct{})); err != nil {
+		t.Fatalf("SVG is not well-formed XML: %v", err)
+	}
+	s := string(svg)
+	if !strings.Contains(s, "Velocity &lt;test&gt;") {
+		t.Error("title is missing or not escaped")
+	}
+	// The NaN splits the line in two.
+	if n := strings.Count(s, "<polyline"); n != 2 {
+		t.Errorf("got %d polylines, want 2", n)
+	}
+	if n := strings.Count(s, `fill="#2b6cb0"`); n != 5 {
+		t.Errorf("got %d blue rects, want 4 bars and the legend", n)
+	}
+}
+
+func TestChartPNG(t *testing.T) {
+	body, err := testChart().PNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != chartWidth || b.Dy() != chartHeight {
+		t.Fatalf("size %v, want %dx%d", b, chartWidth, chartHeight)
+	}
+}
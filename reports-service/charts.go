@@ -0,0 +1,240 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-shared/apierror"
+	"go-shared/httpcache"
+)
+
+const (
+	chartSprintCompletion = "sprint-completion"
+	chartVelocity         = "velocity"
+	chartBurndown         = "burndown"
+)
+
+// loadChartSprints returns the rows of GetSprintsReport for the filter in
+// args, ordered by sprint start date so trends read left to right.
+func loadChartSprints(tx *gorm.DB, args []any) ([]SprintReport, error) {
+	rows, err := tx.Raw(`
+		SELECT r.sprint_id, r.sprint_name, r.task_count, r.task_count_completed, r.completed_ratio
+		FROM getsprintsreport($1::UUID, $2::DATE, $3::DATE) r
+		JOIN "Sprints" s ON s."Id" = r.sprint_id
+		ORDER BY s."StartDate", s."Id"`, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}(rows)
+
+	var reports []SprintReport
+	for rows.Next() {
+		var report SprintReport
+		var completedRatio sql.NullFloat64
+		err := rows.Scan(&report.SprintID, &report.SprintName, &report.TaskCount,
+			&report.TaskCountCompleted, &completedRatio)
+		if err != nil {
+			return nil, err
+		}
+		if completedRatio.Valid {
+			report.CompletedRatio = &completedRatio.Float64
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+func sprintCompletionChart(reports []SprintReport) chart {
+	ch := chart{
+		Title: "Sprint completion",
+		Series: []chartSeries{
+			{Name: "Tasks", Kind: seriesBars, Color: chartGray},
+			{Name: "Completed", Kind: seriesBars, Color: chartGreen},
+		},
+	}
+	for _, r := range reports {
+		ch.Labels = append(ch.Labels, r.SprintName)
+		ch.Series[0].Values = append(ch.Series[0].Values, float64(r.TaskCount))
+		ch.Series[1].Values = append(ch.Series[1].Values, float64(r.TaskCountCompleted))
+	}
+	return ch
+}
+
+// velocityChart plots completed tasks per sprint of one team with the
+// rolling average of the velocity report.
+func velocityChart(team TeamVelocity, window int) chart {
+	title := "Velocity"
+	if team.TeamName != "" {
+		title += " - " + team.TeamName
+	}
+	ch := chart{
+		Title: title,
+		Series: []chartSeries{
+			{Name: "Completed", Kind: seriesBars, Color: chartBlue},
+			{Name: "Rolling average (" + strconv.Itoa(window) + ")", Kind: seriesLine, Color: chartOrange},
+		},
+	}
+	for _, p := range team.Sprints {
+		ch.Labels = append(ch.Labels, p.SprintName)
+		ch.Series[0].Values = append(ch.Series[0].Values, float64(p.TaskCountCompleted))
+		ch.Series[1].Values = append(ch.Series[1].Values, p.RollingAvgCompleted)
+	}
+	return ch
+}
+
+// burndownChart plots the remaining tasks of each day against the ideal
+// line and the scope.
+func burndownChart(b Burndown) chart {
+	ch := chart{
+		Title: "Burndown - " + b.SprintName,
+		Series: []chartSeries{
+			{Name: "Scope", Kind: seriesLine, Color: chartGray},
+			{Name: "Ideal", Kind: seriesLine, Color: chartGray, Dashed: true},
+			{Name: "Remaining", Kind: seriesLine, Color: chartBlue},
+		},
+	}
+	for _, day := range b.Days {
+		ch.Labels = append(ch.Labels, day.Date[5:])
+		remaining := math.NaN()
+		if day.Remaining != nil {
+			remaining = float64(*day.Remaining)
+		}
+		ch.Series[0].Values = append(ch.Series[0].Values, float64(day.Scope))
+		ch.Series[1].Values = append(ch.Series[1].Values, day.Ideal)
+		ch.Series[2].Values = append(ch.Series[2].Values, remaining)
+	}
+	return ch
+}
+
+// chartFormat picks svg or png from ?format= or the Accept header.
+func chartFormat(c *gin.Context) (string, bool) {
+	switch format := strings.ToLower(c.Query("format")); format {
+	case "svg", "png":
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+	if strings.Contains(c.GetHeader("Accept"), "image/png") {
+		return "png", true
+	}
+	return "svg", true
+}
+
+// GET /api/reports/charts/:chart?format=svg|png&...
+//
+// Renders a report as an 800x400 chart for emails and PDF exports:
+//
+//   - sprint-completion: tasks and completed tasks per sprint, filtered like
+//     GET /api/reports/sprints (managerId, startDate, endDate) and oldest
+//     first.
+//   - velocity: completed tasks per sprint of the team teamId with their
+//     rolling average over window sprints, as GET /api/reports/velocity
+//     computes them for that team (count, window, includeCurrent).
+//   - burndown: remaining tasks per day of the sprint sprintId against the
+//     ideal line, as GET /api/reports/sprints/:id/burndown computes them
+//     (tz).
+//
+// The chart is SVG unless PNG is asked for with format=png or Accept:
+// image/png.
+func getReportChart(c *gin.Context) {
+	kind := c.Param("chart")
+	format, ok := chartFormat(c)
+
+	var v apierror.Validation
+	if !ok {
+		v.Add("format", "use svg or png")
+	}
+	var args []any
+	var velocityArgs map[string]any
+	var window int
+	var sprintID uuid.UUID
+	loc := time.UTC
+	switch kind {
+	case chartSprintCompletion:
+		var apiErr *apierror.Error
+		if args, apiErr = parseReportFilter(c); apiErr != nil {
+			for _, d := range apiErr.Details {
+				v.Add(d.Field, d.Issue)
+			}
+		}
+	case chartVelocity:
+		// Teams run sprints of their own, so one chart shows one team.
+		velocityArgs, window = parseVelocityQuery(c, &v)
+		if c.Query("teamId") == "" {
+			v.Add("teamId", "a team UUID is required for the velocity chart")
+		}
+	case chartBurndown:
+		var err error
+		if sprintID, err = uuid.Parse(c.Query("sprintId")); err != nil {
+			v.Add("sprintId", "a sprint UUID is required for the burndown chart")
+		}
+		if tz := c.Query("tz"); tz != "" {
+			if loc, err = time.LoadLocation(tz); err != nil {
+				v.Add("tz", "unknown time zone: "+tz)
+			}
+		}
+	default:
+		v.Add("chart", "use "+chartSprintCompletion+", "+chartVelocity+" or "+chartBurndown)
+	}
+	if apiErr := v.Err("Invalid request"); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	tx := db.WithContext(c.Request.Context())
+	var ch chart
+	if kind == chartBurndown {
+		b, apiErr := loadSprintBurndown(tx, sprintID, loc)
+		if apiErr != nil {
+			abortWithError(c, apiErr)
+			return
+		}
+		ch = burndownChart(b)
+	} else if kind == chartVelocity {
+		teams, err := loadVelocity(tx, velocityArgs, window)
+		if err != nil {
+			abortWithError(c, apierror.Internal("Failed to load report", err))
+			return
+		}
+		var team TeamVelocity
+		if len(teams) > 0 {
+			team = teams[0]
+		}
+		ch = velocityChart(team, window)
+	} else {
+		reports, err := loadChartSprints(tx, args)
+		if err != nil {
+			abortWithError(c, apierror.Internal("Failed to load report", err))
+			return
+		}
+		ch = sprintCompletionChart(reports)
+	}
+
+	body, contentType := ch.SVG(), "image/svg+xml"
+	if format == "png" {
+		var err error
+		if body, err = ch.PNG(); err != nil {
+			abortWithError(c, apierror.Internal("Failed to render chart", err))
+			return
+		}
+		contentType = "image/png"
+	}
+	c.Header("Vary", "Accept")
+	if httpcache.NotModified(c.Writer, c.Request, httpcache.ContentETag(body), time.Time{}) {
+		return
+	}
+	c.Data(200, contentType, body)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-shared/apierror"
+)
+
+// flowStatuses are the statuses of "TaskStatuses" in workflow order, the
+// order in which the bands of a cumulative flow diagram are stacked.
+var flowStatuses = []string{"Created", "Assigned", "Started", "Paused", "Stopped", statusCompleted}
+
+const (
+	defaultFlowDays  = 14
+	maxFlowBuckets   = 1000
+	flowBucketDay    = "day"
+	flowBucketHour   = "hour"
+	flowDateLayout   = "2006-01-02"
+	flowStatusAbsent = ""
+)
+
+// FlowBucket holds how many tasks were in each status at the end of one
+// bucket, or now for the bucket in progress. Counts is null for buckets that
+// have not started yet.
+type FlowBucket struct {
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+	Counts map[string]int `json:"counts"`
+	Total  int            `json:"total"`
+}
+
+// CumulativeFlow is the series behind a cumulative flow diagram. Statuses
+// lists the keys of every bucket's Counts in stacking order.
+type CumulativeFlow struct {
+	SprintID *uuid.UUID   `json:"sprintId"`
+	From     time.Time    `json:"from"`
+	To       time.Time    `json:"to"`
+	Bucket   string       `json:"bucket"`
+	Timezone string       `json:"timezone"`
+	Statuses []string     `json:"statuses"`
+	Buckets  []FlowBucket `json:"buckets"`
+}
+
+// flowBucketEnds returns the end of every bucket from from to to, cutting
+// days at midnight and hours on the hour in loc. It gives up and returns
+// false once there are more than limit buckets.
+func flowBucketEnds(from, to time.Time, bucket string, loc *time.Location, limit int) ([]time.Time, bool) {
+	var ends []time.Time
+	start := from.In(loc)
+	for start.Before(to) {
+		if len(ends) == limit {
+			return nil, false
+		}
+		var end time.Time
+		if bucket == flowBucketHour {
+			// Step back to the local hour rather than truncating the
+			// absolute time, which is off in zones with half-hour offsets.
+			sinceHour := time.Duration(start.Minute())*time.Minute +
+				time.Duration(start.Second())*time.Second + time.Duration(start.Nanosecond())
+			end = start.Add(time.Hour - sinceHour)
+		} else {
+			end = time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+		}
+		if end.After(to) {
+			end = to
+		}
+		ends = append(ends, end)
+		start = end
+	}
+	return ends, true
+}
+
+// flowTask accumulates the history of one task, in ChangeDate order, while
+// the rows are read. events holds only the history before the range ends;
+// hasHistory tells whether the task has any at all.
+type flowTask struct {
+	current    string
+	hasHistory bool
+	events     []burndownEvent
+}
+
+// statusAt returns the status of the task at t: the status set by its last
+// change before t, or flowStatusAbsent before its first change. A task
+// without any history is in its current status throughout.
+func (t flowTask) statusAt(at time.Time) string {
+	if !t.hasHistory {
+		return t.current
+	}
+	status := flowStatusAbsent
+	for _, e := range t.events {
+		if !e.At.Before(at) {
+			break
+		}
+		status = e.Status
+	}
+	return status
+}
+
+// addTo counts the task into every bucket that has started; buckets still
+// in progress count the status as of now.
+func (t flowTask) addTo(buckets []FlowBucket, now time.Time) {
+	for i := range buckets {
+		if buckets[i].Counts == nil {
+			continue
+		}
+		at := buckets[i].End
+		if at.After(now) {
+			at = now
+		}
+		if status := t.statusAt(at); status != flowStatusAbsent {
+			buckets[i].Counts[status]++
+			buckets[i].Total++
+		}
+	}
+}
+
+// loadCumulativeFlow reads the history before to of every task, or of the
+// tasks now in sprintID, one task at a time and counts each into buckets.
+func loadCumulativeFlow(tx *gorm.DB, sprintID *uuid.UUID, to time.Time, buckets []FlowBucket, now time.Time) error {
+	rows, err := tx.Raw(`
+		SELECT t."Id", ts."Name",
+			EXISTS (SELECT 1 FROM "TaskHistories" x WHERE x."TaskId" = t."Id"),
+			h."ChangeDate", h."NewStatus"
+		FROM "Tasks" t
+		JOIN "TaskStatuses" ts ON ts."Id" = t."TaskStatusId"
+		LEFT JOIN "TaskHistories" h ON h."TaskId" = t."Id" AND h."ChangeDate" < @to
+		WHERE @sprint::UUID IS NULL OR t."SprintId" = @sprint
+		ORDER BY t."Id", h."ChangeDate", h."Id"`,
+		map[string]any{"to": to, "sprint": sprintID}).Rows()
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}(rows)
+
+	var task flowTask
+	var currentID uuid.UUID
+	started := false
+	for rows.Next() {
+		var id uuid.UUID
+		var current string
+		var hasHistory bool
+		var changeDate sql.NullTime
+		var status sql.NullString
+		if err := rows.Scan(&id, &current, &hasHistory, &changeDate, &status); err != nil {
+			return err
+		}
+		if !started || id != currentID {
+			if started {
+				task.addTo(buckets, now)
+			}
+			task = flowTask{current: current, hasHistory: hasHistory}
+			currentID, started = id, true
+		}
+		if changeDate.Valid {
+			task.events = append(task.events, burndownEvent{At: changeDate.Time, Status: status.String})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if started {
+		task.addTo(buckets, now)
+	}
+	return nil
+}
+
+// parseFlowTime accepts an RFC 3339 time or a date, read as midnight in loc.
+func parseFlowTime(value string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation(flowDateLayout, value, loc); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// GET /api/reports/cumulative-flow?sprintId={uuid}&from={date|RFC 3339}&to={date|RFC 3339}&bucket=day|hour&tz={IANA zone}
+//
+// The number of tasks in each status at the end of every bucket between
+// from and to, reconstructed from the task histories. With sprintId the
+// series covers the tasks now in the sprint and from and to default to its
+// start date and the day after its end date; otherwise it covers every task
+// and the last 14 days. A date as from or to means midnight in tz (UTC by
+// default), which is also where day buckets are cut.
+//
+// A task is counted from its first history row on, so one created after a
+// bucket is left out of it; a task without any history is counted in its
+// current status for the whole range.
+func getCumulativeFlow(c *gin.Context) {
+	var v apierror.Validation
+	var sprintID *uuid.UUID
+	if raw := c.Query("sprintId"); raw != "" {
+		if parsed, err := uuid.Parse(raw); err != nil {
+			v.Add("sprintId", "not a valid UUID: "+raw)
+		} else {
+			sprintID = &parsed
+		}
+	}
+	bucket := c.DefaultQuery("bucket", flowBucketDay)
+	if bucket != flowBucketDay && bucket != flowBucketHour {
+		v.Add("bucket", "must be day or hour")
+	}
+	loc := time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			v.Add("tz", "unknown time zone: "+tz)
+			loc = time.UTC
+		}
+	}
+	var from, to time.Time
+	if raw := c.Query("from"); raw != "" {
+		var ok bool
+		if from, ok = parseFlowTime(raw, loc); !ok {
+			v.Add("from", "use YYYY-MM-DD or an RFC 3339 time")
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		var ok bool
+		if to, ok = parseFlowTime(raw, loc); !ok {
+			v.Add("to", "use YYYY-MM-DD or an RFC 3339 time")
+		}
+	}
+	if apiErr := v.Err("Invalid query parameters"); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	tx := db.WithContext(c.Request.Context())
+	now := time.Now()
+
+	if sprintID != nil {
+		var start, end time.Time
+		err := tx.Raw(`SELECT "StartDate", "EndDate" FROM "Sprints" WHERE "Id" = ?`, *sprintID).
+			Row().Scan(&start, &end)
+		if errors.Is(err, sql.ErrNoRows) {
+			abortWithError(c, apierror.NotFound("Sprint not found"))
+			return
+		}
+		if err != nil {
+			abortWithError(c, apierror.Internal("Failed to load sprint", err))
+			return
+		}
+		if from.IsZero() {
+			from = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		}
+		if to.IsZero() {
+			to = time.Date(end.Year(), end.Month(), end.Day()+1, 0, 0, 0, 0, loc)
+		}
+	}
+	if to.IsZero() {
+		local := now.In(loc)
+		to = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -defaultFlowDays)
+	}
+
+	if !from.Before(to) {
+		v.Add("to", "must be after from")
+	}
+	ends, ok := flowBucketEnds(from, to, bucket, loc, maxFlowBuckets)
+	if !ok {
+		v.Add("bucket", "the range spans more than "+strconv.Itoa(maxFlowBuckets)+" buckets")
+	}
+	if apiErr := v.Err("Invalid query parameters"); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	result := CumulativeFlow{
+		SprintID: sprintID,
+		From:     from,
+		To:       to,
+		Bucket:   bucket,
+		Timezone: loc.String(),
+		Buckets:  make([]FlowBucket, len(ends)),
+	}
+	start := from
+	for i, end := range ends {
+		result.Buckets[i] = FlowBucket{Start: start.In(loc), End: end.In(loc)}
+		if start.Before(now) {
+			result.Buckets[i].Counts = map[string]int{}
+			for _, status := range flowStatuses {
+				result.Buckets[i].Counts[status] = 0
+			}
+		}
+		start = end
+	}
+
+	if err := loadCumulativeFlow(tx, sprintID, to, result.Buckets, now); err != nil {
+		abortWithError(c, apierror.Internal("Failed to load task histories", err))
+		return
+	}
+
+	// Statuses outside the workflow, if any rows carry them, stack last.
+	result.Statuses = slices.Clone(flowStatuses)
+	var extra []string
+	for _, b := range result.Buckets {
+		for status := range b.Counts {
+			if !slices.Contains(result.Statuses, status) && !slices.Contains(extra, status) {
+				extra = append(extra, status)
+			}
+		}
+	}
+	slices.Sort(extra)
+	result.Statuses = append(result.Statuses, extra...)
+
+	writeReport(c, ApiResponse{
+		Message: "Cumulative flow retrieved",
+		Data:    result,
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestFlowBucketEnds(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		bucket   string
+		loc      *time.Location
+		want     []time.Duration // length of each bucket
+	}{
+		{"days into summer time",
+			time.Date(2025, 3, 29, 0, 0, 0, 0, berlin), time.Date(2025, 4, 1, 0, 0, 0, 0, berlin), flowBucketDay, berlin,
+			[]time.Duration{24 * time.Hour, 23 * time.Hour, 24 * time.Hour}},
+		{"days out of summer time",
+			time.Date(2025, 10, 26, 0, 0, 0, 0, berlin), time.Date(2025, 10, 27, 12, 0, 0, 0, berlin), flowBucketDay, berlin,
+			[]time.Duration{25 * time.Hour, 12 * time.Hour}},
+		{"hours over the skipped hour",
+			time.Date(2025, 3, 30, 1, 0, 0, 0, berlin), time.Date(2025, 3, 30, 4, 0, 0, 0, berlin), flowBucketHour, berlin,
+			[]time.Duration{time.Hour, time.Hour}},
+		{"hours over the repeated hour",
+			time.Date(2025, 10, 26, 1, 0, 0, 0, berlin), time.Date(2025, 10, 26, 4, 0, 0, 0, berlin), flowBucketHour, berlin,
+			[]time.Duration{time.Hour, time.Hour, time.Hour, time.Hour}},
+		{"hours in a half-hour zone",
+			time.Date(2025, 5, 1, 9, 15, 0, 0, kolkata), time.Date(2025, 5, 1, 11, 0, 0, 0, kolkata), flowBucketHour, kolkata,
+			[]time.Duration{45 * time.Minute, time.Hour}},
+		{"range ending mid-bucket",
+			time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 5, 1, 6, 0, 0, 0, time.UTC), flowBucketDay, time.UTC,
+			[]time.Duration{6 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ends, ok := flowBucketEnds(tt.from, tt.to, tt.bucket, tt.loc, maxFlowBuckets)
+			if !ok {
+				t.Fatal("rejected a short range")
+			}
+			if len(ends) != len(tt.want) {
+				t.Fatalf("ends = %v, want %d buckets", ends, len(tt.want))
+			}
+			start := tt.from
+			for i, end := range ends {
+				if got := end.Sub(start); got != tt.want[i] {
+					t.Errorf("bucket %d from %v lasts %v, want %v", i, start.In(tt.loc), got, tt.want[i])
+				}
+				if local := end.In(tt.loc); !end.Equal(tt.to) && (local.Minute() != 0 || local.Second() != 0) {
+					t.Errorf("bucket %d ends at %v, not on the hour in %v", i, local, tt.loc)
+				}
+				start = end
+			}
+		})
+	}
+}
+
+func TestFlowBucketEndsLimit(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if ends, ok := flowBucketEnds(from, from.Add(3*time.Hour), flowBucketHour, time.UTC, 3); !ok || len(ends) != 3 {
+		t.Errorf("three buckets with a limit of three: %v, %v", ends, ok)
+	}
+	if _, ok := flowBucketEnds(from, from.AddDate(100, 0, 0), flowBucketHour, time.UTC, 3); ok {
+		t.Error("accepted more buckets than the limit")
+	}
+}
+
+func TestFlowTaskStatusAt(t *testing.T) {
+	edge := time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC)
+	task := flowTask{current: statusCompleted, hasHistory: true, events: []burndownEvent{
+		{At: edge.Add(-time.Hour), Status: "Created"},
+		{At: edge, Status: "Started"},
+		{At: edge.Add(time.Hour), Status: statusCompleted},
+	}}
+
+	tests := []struct {
+		name string
+		task flowTask
+		at   time.Time
+		want string
+	}{
+		{"before the first change", task, edge.Add(-2 * time.Hour), flowStatusAbsent},
+		{"at the first change", task, edge.Add(-time.Hour), flowStatusAbsent},
+		// A change at the bucket end belongs to the next bucket.
+		{"at a bucket edge", task, edge, "Created"},
+		{"just after the edge", task, edge.Add(time.Nanosecond), "Started"},
+		{"after the last change", task, edge.Add(2 * time.Hour), statusCompleted},
+		{"without history", flowTask{current: "Paused"}, edge, "Paused"},
+		// Only history before the range is loaded, so a task created after
+		// it has none loaded but must not show up in its current status.
+		{"created after the range", flowTask{current: statusCompleted, hasHistory: true}, edge, flowStatusAbsent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.task.statusAt(tt.at); got != tt.want {
+				t.Errorf("statusAt(%v) = %q, want %q", tt.at, got, tt.want)
+			}
+		})
+	}
+}
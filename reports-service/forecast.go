@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"go-shared/apierror"
+)
+
+const (
+	defaultForecastHistoryWeeks = 12
+	maxForecastHistoryWeeks     = 104
+	defaultForecastTrials       = 10000
+	maxForecastTrials           = 100000
+	// forecastHorizonWeeks bounds a trial; one that has not finished by
+	// then counts as not finishing at all.
+	forecastHorizonWeeks = 520
+)
+
+var forecastPercentiles = []int{50, 70, 85, 95}
+
+// ForecastTeam is a team working on the project with the tasks it
+// completed in each of the history weeks, oldest first.
+type ForecastTeam struct {
+	TeamID           uuid.UUID `json:"teamId"`
+	TeamName         string    `json:"teamName"`
+	WeeklyThroughput []int     `json:"weeklyThroughput"`
+}
+
+// ForecastPercentile says that Percentile percent of the trials finished
+// the remaining tasks within Weeks weeks, that is by Date. Both are null
+// when fewer trials finished within the horizon.
+type ForecastPercentile struct {
+	Percentile int     `json:"percentile"`
+	Weeks      *int    `json:"weeks"`
+	Date       *string `json:"date"`
+}
+
+// ProjectForecast is the forecast of a project, dated from ForecastDate.
+type ProjectForecast struct {
+	ProjectID      uuid.UUID            `json:"projectId"`
+	ProjectName    string               `json:"projectName"`
+	ProjectEndDate *string              `json:"projectEndDate"`
+	RemainingTasks int                  `json:"remainingTasks"`
+	ForecastDate   string               `json:"forecastDate"`
+	HistoryWeeks   int                  `json:"historyWeeks"`
+	Trials         int                  `json:"trials"`
+	Seed           uint64               `json:"seed"`
+	Teams          []ForecastTeam       `json:"teams"`
+	Percentiles    []ForecastPercentile `json:"percentiles"`
+	// ProbabilityByEndDate is the share of trials finishing by
+	// ProjectEndDate, null when the project has no end date.
+	ProbabilityByEndDate *float64 `json:"probabilityByEndDate"`
+}
+
+// simulateForecast runs trials of the remaining work and returns, sorted,
+// the number of weeks each trial took, or -1 for trials still unfinished
+// after forecastHorizonWeeks. Every simulated week each team completes as
+// many tasks as in a week drawn at random from its history.
+func simulateForecast(rng *rand.Rand, remaining int, throughput [][]int, trials int) []int {
+	var teams [][]int
+	for _, weeks := range throughput {
+		if len(weeks) > 0 {
+			teams = append(teams, weeks)
+		}
+	}
+
+	results := make([]int, trials)
+	for i := range results {
+		left, week := remaining, 0
+		for left > 0 && week < forecastHorizonWeeks {
+			week++
+			for _, weeks := range teams {
+				left -= weeks[rng.IntN(len(weeks))]
+			}
+		}
+		if left > 0 {
+			week = -1
+		}
+		results[i] = week
+	}
+
+	// Unfinished trials sort last.
+	slices.SortFunc(results, func(a, b int) int {
+		if a < 0 || b < 0 {
+			return b - a
+		}
+		return a - b
+	})
+	return results
+}
+
+// percentileWeeks returns the weeks within which p percent of the sorted
+// results finished, or -1 when fewer than p percent finished.
+func percentileWeeks(results []int, p int) int {
+	if len(results) == 0 {
+		return -1
+	}
+	index := (len(results)*p+99)/100 - 1
+	return results[max(index, 0)]
+}
+
+// GET /api/reports/projects/:id/forecast?historyWeeks={1-104}&trials={1-100000}&seed={n}
+//
+// A Monte Carlo forecast of when the project's remaining tasks will be
+// completed. Tasks and completions are counted as in GetProjectsReport: the
+// tasks of the project's sprints, a task being done once it has a
+// Completed history row. The teams are those with a sprint in the project;
+// their throughput is the number of tasks of any of their sprints first
+// completed in each of the last historyWeeks weeks, assuming a team keeps
+// working at that pace and all of it goes to this project.
+//
+// Pass the seed of a previous response to reproduce it.
+func getProjectForecast(c *gin.Context) {
+	var v apierror.Validation
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		v.Add("id", "not a valid UUID: "+c.Param("id"))
+	}
+	historyWeeks := defaultForecastHistoryWeeks
+	if raw := c.Query("historyWeeks"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxForecastHistoryWeeks {
+			v.Add("historyWeeks", "must be between 1 and "+strconv.Itoa(maxForecastHistoryWeeks))
+		} else {
+			historyWeeks = parsed
+		}
+	}
+	trials := defaultForecastTrials
+	if raw := c.Query("trials"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxForecastTrials {
+			v.Add("trials", "must be between 1 and "+strconv.Itoa(maxForecastTrials))
+		} else {
+			trials = parsed
+		}
+	}
+	seed := rand.Uint64()
+	if raw := c.Query("seed"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			v.Add("seed", "must be a non-negative integer")
+		} else {
+			seed = parsed
+		}
+	}
+	if apiErr := v.Err("Invalid request"); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	tx := db.WithContext(c.Request.Context())
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	result := ProjectForecast{
+		ProjectID:    projectID,
+		ForecastDate: today.Format("2006-01-02"),
+		HistoryWeeks: historyWeeks,
+		Trials:       trials,
+		Seed:         seed,
+		Teams:        []ForecastTeam{},
+	}
+
+	var endDate sql.NullTime
+	err = tx.Raw(`
+		SELECT p."Name", p."EndDate",
+			(SELECT COUNT(*)
+				FROM "Tasks" t
+				JOIN "Sprints" s ON t."SprintId" = s."Id"
+				WHERE s."ProjectId" = p."Id"
+				  AND NOT EXISTS (SELECT 1 FROM "TaskHistories" th
+					WHERE th."TaskId" = t."Id" AND th."NewStatus" = 'Completed'))
+		FROM "Projects" p
+		WHERE p."Id" = ?`, projectID).
+		Row().Scan(&result.ProjectName, &endDate, &result.RemainingTasks)
+	if errors.Is(err, sql.ErrNoRows) {
+		abortWithError(c, apierror.NotFound("Project not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, apierror.Internal("Failed to load project", err))
+		return
+	}
+	if endDate.Valid {
+		formatted := endDate.Time.Format("2006-01-02")
+		result.ProjectEndDate = &formatted
+	}
+
+	rows, err := tx.Raw(`
+		WITH teams AS (
+			SELECT DISTINCT tm."Id", tm."Name"
+			FROM "Teams" tm
+			JOIN "Sprints" s ON s."TeamId" = tm."Id"
+			WHERE s."ProjectId" = @project
+		), done AS (
+			SELECT s."TeamId" AS team_id, MIN(th."ChangeDate") AS done_at
+			FROM "TaskHistories" th
+			JOIN "Tasks" t ON th."TaskId" = t."Id"
+			JOIN "Sprints" s ON t."SprintId" = s."Id"
+			WHERE th."NewStatus" = 'Completed' AND s."TeamId" IN (SELECT "Id" FROM teams)
+			GROUP BY t."Id", s."TeamId"
+		)
+		SELECT tm."Id", tm."Name",
+			FLOOR(EXTRACT(EPOCH FROM (@now::TIMESTAMPTZ - d.done_at)) / 604800)::INT AS weeks_ago
+		FROM teams tm
+		LEFT JOIN done d ON d.team_id = tm."Id"
+			AND d.done_at <= @now AND d.done_at > @now::TIMESTAMPTZ - MAKE_INTERVAL(weeks => @weeks)
+		ORDER BY tm."Name", tm."Id"`,
+		map[string]any{"project": projectID, "now": now, "weeks": historyWeeks}).Rows()
+	if err != nil {
+		abortWithError(c, apierror.Internal("Failed to load team throughput", err))
+		return
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var teamID uuid.UUID
+		var teamName string
+		var weeksAgo sql.NullInt64
+		if err := rows.Scan(&teamID, &teamName, &weeksAgo); err != nil {
+			abortWithError(c, apierror.Internal("Failed to read team throughput", err))
+			return
+		}
+		if len(result.Teams) == 0 || result.Teams[len(result.Teams)-1].TeamID != teamID {
+			result.Teams = append(result.Teams, ForecastTeam{
+				TeamID:           teamID,
+				TeamName:         teamName,
+				WeeklyThroughput: make([]int, historyWeeks),
+			})
+		}
+		if weeksAgo.Valid && weeksAgo.Int64 >= 0 && weeksAgo.Int64 < int64(historyWeeks) {
+			team := &result.Teams[len(result.Teams)-1]
+			team.WeeklyThroughput[historyWeeks-1-int(weeksAgo.Int64)]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		abortWithError(c, apierror.Internal("Failed to load team throughput", err))
+		return
+	}
+
+	throughput := make([][]int, len(result.Teams))
+	for i, team := range result.Teams {
+		throughput[i] = team.WeeklyThroughput
+	}
+	rng := rand.New(rand.NewPCG(seed, seed))
+	results := simulateForecast(rng, result.RemainingTasks, throughput, trials)
+
+	for _, p := range forecastPercentiles {
+		entry := ForecastPercentile{Percentile: p}
+		if weeks := percentileWeeks(results, p); weeks >= 0 {
+			date := today.AddDate(0, 0, 7*weeks).Format("2006-01-02")
+			entry.Weeks, entry.Date = &weeks, &date
+		}
+		result.Percentiles = append(result.Percentiles, entry)
+	}
+
+	if endDate.Valid {
+		// Weeks left until the end date; a trial taking that many weeks or
+		// fewer finishes on time.
+		end := time.Date(endDate.Time.Year(), endDate.Time.Month(), endDate.Time.Day(), 0, 0, 0, 0, time.UTC)
+		weeksLeft := int(end.Sub(today).Hours() / 24 / 7)
+		onTime := 0
+		for _, weeks := range results {
+			if weeks >= 0 && (weeks <= weeksLeft || weeks == 0) {
+				onTime++
+			}
+		}
+		probability := round2(float64(onTime) / float64(len(results)))
+		result.ProbabilityByEndDate = &probability
+	}
+
+	writeReport(c, ApiResponse{
+		Message: "Project forecast retrieved",
+		Data:    result,
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestSimulateForecastIsReproducible(t *testing.T) {
+	throughput := [][]int{{0, 3, 5, 1, 2}, {4, 0, 2}}
+
+	first := simulateForecast(rand.New(rand.NewPCG(42, 42)), 40, throughput, 500)
+	second := simulateForecast(rand.New(rand.NewPCG(42, 42)), 40, throughput, 500)
+	if !slices.Equal(first, second) {
+		t.Fatal("same seed gave different results")
+	}
+	if !slices.IsSorted(first) {
+		t.Fatal("results are not sorted")
+	}
+	// At most 5+4 tasks a week, at least nothing.
+	if first[0] < 5 || first[len(first)-1] > forecastHorizonWeeks {
+		t.Fatalf("weeks out of range: %d..%d", first[0], first[len(first)-1])
+	}
+}
+
+func TestSimulateForecastConstantThroughput(t *testing.T) {
+	results := simulateForecast(rand.New(rand.NewPCG(1, 1)), 10, [][]int{{3, 3}, {2}}, 10)
+	for _, weeks := range results {
+		if weeks != 2 {
+			t.Fatalf("weeks = %d, want 2", weeks)
+		}
+	}
+	if got := percentileWeeks(results, 85); got != 2 {
+		t.Fatalf("85th percentile = %d, want 2", got)
+	}
+}
+
+func TestSimulateForecastWithoutThroughput(t *testing.T) {
+	results := simulateForecast(rand.New(rand.NewPCG(1, 1)), 3, [][]int{{0, 0}, {}}, 5)
+	if got := percentileWeeks(results, 50); got != -1 {
+		t.Fatalf("50th percentile = %d, want -1", got)
+	}
+
+	results = simulateForecast(rand.New(rand.NewPCG(1, 1)), 0, nil, 5)
+	if got := percentileWeeks(results, 95); got != 0 {
+		t.Fatalf("95th percentile with nothing left = %d, want 0", got)
+	}
+}
+
+func TestPercentileWeeks(t *testing.T) {
+	results := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for p, want := range map[int]int{50: 5, 85: 9, 95: 10, 1: 1} {
+		if got := percentileWeeks(results, p); got != want {
+			t.Errorf("percentileWeeks(%d) = %d, want %d", p, got, want)
+		}
+	}
+	if got := percentileWeeks([]int{1, 2, -1, -1}, 70); got != -1 {
+		t.Errorf("percentile past the finished trials = %d, want -1", got)
+	}
+}
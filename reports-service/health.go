@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"go-shared/health"
+)
+
+func livenessHandler(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, ApiResponse{
+			Message: "Service alive",
+			Data:    checker.Liveness(),
+		})
+	}
+}
+
+func readinessHandler(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checker.Readiness(c.Request.Context())
+
+		switch report.Status {
+		case health.StatusReady:
+			c.JSON(200, ApiResponse{Message: "Service ready", Data: report})
+		case health.StatusShuttingDown:
+			c.JSON(503, ApiResponse{Message: "Service shutting down", Data: report})
+		default:
+			c.JSON(503, ApiResponse{Message: "Service unavailable", Data: report})
+		}
+	}
+}
@@ -1,26 +1,45 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	_ "github.com/jackc/pgx/v5/stdlib"
 	amqp "github.com/rabbitmq/amqp091-go"
-	"gorm.io/driver/postgres"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+
+	"go-shared/apierror"
+	"go-shared/config"
+	"go-shared/database"
+	"go-shared/duration"
+	"go-shared/health"
+	"go-shared/httpcache"
+	"go-shared/rabbitmq"
+	"go-shared/telemetry"
 )
 
 var db *gorm.DB
+var tracer = otel.Tracer("reports-service")
 var rabbitConn *amqp.Connection
 var rabbitChannel *amqp.Channel
+var auditConsumerDone = make(chan struct{})
+
+const auditConsumerTag = "reports-service-audit-consumer"
 
 type ApiResponse struct {
 	Message string `json:"message"`
@@ -51,13 +70,6 @@ type AuditMessage struct {
 	Description string `json:"description"`
 }
 
-type RabbitMQConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-}
-
 func parseUUIDArray(arrayStr string) ([]uuid.UUID, error) {
 	if arrayStr == "" || arrayStr == "{}" {
 		return []uuid.UUID{}, nil
@@ -111,14 +123,46 @@ func parseTextArray(arrayStr string) []string {
 	return result
 }
 
+// TaskTime is the total time spent on a report's tasks. TotalTaskTime keeps
+// the raw Postgres interval text older clients parse; clients that request
+// version 2 also get it in seconds and as ISO-8601.
+type TaskTime struct {
+	TotalTaskTime        string   `json:"totalTaskTime"`
+	TotalTaskTimeSeconds *float64 `json:"totalTaskTimeSeconds,omitempty"`
+	TotalTaskTimeISO     string   `json:"totalTaskTimeIso,omitempty"`
+}
+
+func (t *TaskTime) set(raw sql.NullString, version int) error {
+	if !raw.Valid {
+		raw.String = "00:00:00"
+	}
+	t.TotalTaskTime = raw.String
+	if version < duration.Version2 {
+		return nil
+	}
+
+	var interval duration.Interval
+	if err := interval.Scan(raw.String); err != nil {
+		return err
+	}
+	d, err := interval.Duration()
+	if err != nil {
+		return err
+	}
+	seconds := duration.Seconds(d)
+	t.TotalTaskTimeSeconds = &seconds
+	t.TotalTaskTimeISO = duration.ISO8601(d)
+	return nil
+}
+
 // SprintReport model for GetSprintsReport procedure
 type SprintReport struct {
 	SprintID           uuid.UUID `json:"sprintId"`
 	SprintName         string    `json:"sprintName"`
 	TaskCount          int       `json:"taskCount"`
 	TaskCountCompleted int       `json:"taskCountCompleted"`
-	TotalTaskTime      string    `json:"totalTaskTime"`
-	CompletedRatio     *float64  `json:"completedRatio"`
+	TaskTime
+	CompletedRatio *float64 `json:"completedRatio"`
 }
 
 // TeamReport model for GetTeamReport procedure
@@ -128,54 +172,26 @@ type TeamReport struct {
 	SprintsNames       []string    `json:"sprintsNames"`
 	TaskCount          int         `json:"taskCount"`
 	TaskCountCompleted int         `json:"taskCountCompleted"`
-	TotalTaskTime      string      `json:"totalTaskTime"`
+	TaskTime
 }
 
 // ProjectReport model for GetProjectsReport procedure
 type ProjectReport struct {
-	ProjectID          uuid.UUID  `json:"projectId"`
-	ProjectName        string     `json:"projectName"`
-	CompanyName        string     `json:"companyName"`
-	SprintCount        int        `json:"sprintCount"`
-	TaskCount          int        `json:"taskCount"`
-	TaskCountCompleted int        `json:"taskCountCompleted"`
-	TotalTaskTime      string     `json:"totalTaskTime"`
-	ProjectStartDate   *time.Time `json:"projectStartDate"`
-	ProjectEndDate     *time.Time `json:"projectEndDate"`
-	CompletedRatio     *float64   `json:"completedRatio"`
-}
-
-func checkDatabaseConnection() bool {
-	sqlDB, err := db.DB()
-	if err != nil {
-		return false
-	}
-	return sqlDB.Ping() == nil
-}
-
-func checkRabbitMQConnection() bool {
-	if rabbitConn == nil {
-		return false
-	}
-	return !rabbitConn.IsClosed()
-}
-
-func getRabbitMQConfig() RabbitMQConfig {
-	return RabbitMQConfig{
-		Host:     getEnv("RABBITMQ_HOST", "rabbitmq"),
-		Port:     getEnv("RABBITMQ_PORT", "5672"),
-		User:     getEnv("RABBITMQ_USER", "user"),
-		Password: getEnv("RABBITMQ_PASS", "password"),
-	}
+	ProjectID          uuid.UUID `json:"projectId"`
+	ProjectName        string    `json:"projectName"`
+	CompanyName        string    `json:"companyName"`
+	SprintCount        int       `json:"sprintCount"`
+	TaskCount          int       `json:"taskCount"`
+	TaskCountCompleted int       `json:"taskCountCompleted"`
+	TaskTime
+	ProjectStartDate *time.Time `json:"projectStartDate"`
+	ProjectEndDate   *time.Time `json:"projectEndDate"`
+	CompletedRatio   *float64   `json:"completedRatio"`
 }
 
-func initRabbitMQ() {
-	config := getRabbitMQConfig()
-	connectionString := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		config.User, config.Password, config.Host, config.Port)
-
+func initRabbitMQ(ctx context.Context, cfg rabbitmq.Config) {
 	var err error
-	rabbitConn, err = amqp.Dial(connectionString)
+	rabbitConn, err = rabbitmq.Dial(ctx, cfg)
 	if err != nil {
 		log.Fatal("Failed to connect to RabbitMQ:", err)
 	}
@@ -221,16 +237,25 @@ func initRabbitMQ() {
 		log.Fatal("Failed to bind audit queue:", err)
 	}
 
+	// Audit messages are acknowledged after they are stored, so anything left
+	// unacknowledged at shutdown is redelivered instead of lost.
+	err = rabbitChannel.Qos(10, 0, false)
+	if err != nil {
+		log.Fatal("Failed to set RabbitMQ QoS:", err)
+	}
+
 	log.Println("RabbitMQ initialized successfully")
 
 	go consumeAuditMessages()
 }
 
 func consumeAuditMessages() {
+	defer close(auditConsumerDone)
+
 	msgs, err := rabbitChannel.Consume(
 		"audit_queue",
-		"",
-		true,
+		auditConsumerTag,
+		false,
 		false,
 		false,
 		false,
@@ -243,193 +268,249 @@ func consumeAuditMessages() {
 	log.Println("Started consuming audit messages...")
 
 	for msg := range msgs {
-		var auditMsg AuditMessage
-		log.Printf("Received audit message: %s", string(msg.Body))
-		if err := json.Unmarshal(msg.Body, &auditMsg); err != nil {
-			log.Printf("Error unmarshaling audit message: %v", err)
-			continue
+		saveAuditMessage(msg)
+		if err := msg.Ack(false); err != nil {
+			log.Printf("Error acknowledging audit message: %v", err)
 		}
+	}
 
-		timestamp, err := time.Parse(time.RFC3339, auditMsg.Timestamp)
-		if err != nil {
-			log.Printf("Error parsing timestamp: %v, using current time", err)
-			timestamp = time.Now()
-		}
+	log.Println("Audit consumer stopped")
+}
 
-		auditLog := AuditLog{
-			Timestamp:   timestamp,
-			Service:     auditMsg.Service,
-			Action:      auditMsg.Action,
-			Entity:      auditMsg.Entity,
-			Description: auditMsg.Description,
-		}
+// shutdownRabbitMQ cancels the audit consumer, waits for messages already
+// delivered to be saved and then closes the channel and connection.
+func shutdownRabbitMQ(ctx context.Context) {
+	if err := rabbitChannel.Cancel(auditConsumerTag, false); err != nil {
+		log.Printf("Error cancelling audit consumer: %v", err)
+	}
 
-		if err := db.Create(&auditLog).Error; err != nil {
-			log.Printf("Error saving audit log: %v", err)
-		} else {
-			log.Printf("Saved audit log: %s - %s - %s", auditLog.Service, auditLog.Action, auditLog.Entity)
-		}
+	select {
+	case <-auditConsumerDone:
+		log.Println("In-flight audit messages saved")
+	case <-ctx.Done():
+		log.Printf("Timed out waiting for in-flight audit messages: %v", ctx.Err())
+	}
+
+	if err := rabbitChannel.Close(); err != nil {
+		log.Printf("Error closing RabbitMQ channel: %v", err)
+	}
+	if err := rabbitConn.Close(); err != nil {
+		log.Printf("Error closing RabbitMQ connection: %v", err)
 	}
 }
 
-func healthCheck(c *gin.Context) {
-	dbOK := checkDatabaseConnection()
-	rabbitOK := checkRabbitMQConnection()
-
-	if !dbOK || !rabbitOK {
-		c.JSON(503, ApiResponse{
-			Message: "Service unavailable",
-			Data: gin.H{
-				"status": "unhealthy",
-				"database": map[string]string{
-					"status": func() string {
-						if dbOK {
-							return "connected"
-						}
-						return "disconnected"
-					}(),
-				},
-				"rabbitmq": map[string]string{
-					"status": func() string {
-						if rabbitOK {
-							return "connected"
-						}
-						return "disconnected"
-					}(),
-				},
-			},
-		})
+func saveAuditMessage(msg amqp.Delivery) {
+	ctx := rabbitmq.ExtractTraceContext(context.Background(), msg.Headers)
+	ctx, span := tracer.Start(ctx, "audit_logs process", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", "audit_logs"),
+			attribute.String("messaging.rabbitmq.destination.routing_key", msg.RoutingKey),
+		))
+	defer span.End()
+
+	var auditMsg AuditMessage
+	log.Printf("Received audit message: %s", string(msg.Body))
+	if err := json.Unmarshal(msg.Body, &auditMsg); err != nil {
+		log.Printf("Error unmarshaling audit message: %v", err)
+		telemetry.RecordError(span, err)
 		return
 	}
 
-	c.JSON(200, ApiResponse{
-		Message: "Service healthy",
-		Data: gin.H{
-			"status":   "healthy",
-			"database": map[string]string{"status": "connected"},
-			"rabbitmq": map[string]string{"status": "connected"},
-		},
-	})
+	timestamp, err := time.Parse(time.RFC3339, auditMsg.Timestamp)
+	if err != nil {
+		log.Printf("Error parsing timestamp: %v, using current time", err)
+		timestamp = time.Now()
+	}
+
+	auditLog := AuditLog{
+		Timestamp:   timestamp,
+		Service:     auditMsg.Service,
+		Action:      auditMsg.Action,
+		Entity:      auditMsg.Entity,
+		Description: auditMsg.Description,
+	}
+
+	if err := db.WithContext(ctx).Create(&auditLog).Error; err != nil {
+		log.Printf("Error saving audit log: %v", err)
+		telemetry.RecordError(span, err)
+	} else {
+		log.Printf("Saved audit log: %s - %s - %s", auditLog.Service, auditLog.Action, auditLog.Entity)
+	}
 }
 
 func main() {
-	initDatabase()
-	initRabbitMQ()
+	loader := config.NewLoader()
+	dbConfig := database.LoadConfig(loader)
+	rabbitConfig := rabbitmq.LoadConfig(loader)
+	telemetryConfig := telemetry.LoadConfig(loader, "reports-service")
+	port := loader.String("PORT", "8080")
+	shutdownTimeout := loader.Duration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	checker := health.FromConfig(loader)
 
-	r := gin.Default()
+	loader.Report(log.Printf)
+	if err := loader.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
 
-	r.GET("/health", healthCheck)
+	startupCtx := context.Background()
+
+	shutdownTracing, err := telemetry.Init(startupCtx, telemetryConfig)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+
+	db, err = database.Open(startupCtx, dbConfig, nil)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	initRabbitMQ(startupCtx, rabbitConfig)
+
+	checker.
+		AddWithDetails("postgres", database.HealthCheck(db), database.Stats(db)).
+		Add("rabbitmq", rabbitmq.HealthCheck(rabbitConn, rabbitChannel))
+
+	r := gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(recoverPanic))
+	r.Use(otelgin.Middleware(telemetryConfig.ServiceName))
+
+	r.GET("/livez", livenessHandler(checker))
+	r.GET("/readyz", readinessHandler(checker))
+	r.GET("/health", readinessHandler(checker))
 	api := r.Group("/api")
 	{
 		api.GET("/reports/sprints", getSprintsReport)
+		api.GET("/reports/sprints/:id/burndown", getSprintBurndown)
+		api.GET("/reports/velocity", getVelocityReport)
+		api.GET("/reports/cumulative-flow", getCumulativeFlow)
 		api.GET("/reports/teams", getTeamsReport)
 		api.GET("/reports/projects", getProjectsReport)
+		api.GET("/reports/projects/:id/forecast", getProjectForecast)
+		api.GET("/reports/charts/:chart", getReportChart)
 		api.GET("/reports/audit-logs", getAuditLogs)
 	}
 
-	port := getEnv("PORT", "8080")
-	log.Printf("Reports service starting on port %s", port)
-	err := r.Run(":" + port)
-	if err != nil {
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Reports service starting on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Printf("Shutdown signal received, draining (timeout %s)", shutdownTimeout)
+	case err := <-serverErr:
 		log.Fatal("Failed to start server:", err)
-		return
 	}
-}
+	stop()
+	checker.MarkShuttingDown()
 
-func initDatabase() {
-	host := getEnv("DB_HOST", "db")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "password")
-	dbname := getEnv("DB_DATABASE", "project")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		host, user, password, dbname, port)
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error shutting down HTTP server:", err)
+	}
 
-	var err error
+	shutdownRabbitMQ(shutdownCtx)
 
-	db, err = gorm.Open(postgres.New(postgres.Config{
-		DriverName: "pgx",
-		DSN:        dsn,
-	}), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	if err := database.Close(db); err != nil {
+		log.Println("Error closing database pool:", err)
+	}
 
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Println("Error shutting down tracer provider:", err)
 	}
 
-	log.Println("Connected to database successfully")
+	log.Println("Reports service stopped")
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+// abortWithError sends the shared error envelope with the error's status.
+func abortWithError(c *gin.Context, err *apierror.Error) {
+	c.AbortWithStatusJSON(err.Status, err.Envelope())
 }
 
-// GET /api/reports/sprints?managerId={uuid}&startDate={date}&endDate={date}
-func getSprintsReport(c *gin.Context) {
-	managerID := c.Query("managerId")
-	startDate := c.Query("startDate")
-	endDate := c.Query("endDate")
+// writeReport sends a report with an ETag hashed from its body. The reports
+// are computed by stored procedures over several tables without a common
+// modification time, so only the result tells whether anything changed; a
+// matching If-None-Match still costs the query but not the transfer. The
+// response depends on the version negotiated through Accept.
+func writeReport(c *gin.Context, response ApiResponse) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		abortWithError(c, apierror.Internal("Failed to encode report", err))
+		return
+	}
+	c.Header("Vary", "Accept")
+	if httpcache.NotModified(c.Writer, c.Request, httpcache.ContentETag(body), time.Time{}) {
+		return
+	}
+	c.Data(200, "application/json; charset=utf-8", body)
+}
 
-	var args []interface{}
-	var managerUUID *uuid.UUID
-	var startDateParsed *time.Time
-	var endDateParsed *time.Time
+// parseReportFilter validates the managerId, startDate and endDate
+// parameters shared by the report endpoints and returns them as the
+// arguments of the report procedures, nil where a parameter is absent.
+func parseReportFilter(c *gin.Context) ([]any, *apierror.Error) {
+	var v apierror.Validation
+	args := []any{nil, nil, nil}
 
-	if managerID != "" {
+	if managerID := c.Query("managerId"); managerID != "" {
 		if parsed, err := uuid.Parse(managerID); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid manager ID format"})
-			return
+			v.Add("managerId", "not a valid UUID: "+managerID)
 		} else {
-			managerUUID = &parsed
+			args[0] = parsed
 		}
 	}
 
-	if startDate != "" {
+	var start, end time.Time
+	if startDate := c.Query("startDate"); startDate != "" {
 		if parsed, err := time.Parse("2006-01-02", startDate); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid start date format. Use YYYY-MM-DD"})
-			return
+			v.Add("startDate", "use YYYY-MM-DD")
 		} else {
-			startDateParsed = &parsed
+			start = parsed
+			args[1] = parsed
 		}
 	}
-
-	if endDate != "" {
+	if endDate := c.Query("endDate"); endDate != "" {
 		if parsed, err := time.Parse("2006-01-02", endDate); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid end date format. Use YYYY-MM-DD"})
-			return
+			v.Add("endDate", "use YYYY-MM-DD")
 		} else {
-			endDateParsed = &parsed
+			end = parsed
+			args[2] = parsed
 		}
 	}
-
-	if managerUUID != nil {
-		args = append(args, *managerUUID)
-	} else {
-		args = append(args, nil)
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		v.Add("endDate", "must not be before startDate")
 	}
 
-	if startDateParsed != nil {
-		args = append(args, *startDateParsed)
-	} else {
-		args = append(args, nil)
-	}
+	return args, v.Err("Invalid query parameters")
+}
 
-	if endDateParsed != nil {
-		args = append(args, *endDateParsed)
-	} else {
-		args = append(args, nil)
+// GET /api/reports/sprints?managerId={uuid}&startDate={date}&endDate={date}&format=json|csv|xlsx|pdf
+func getSprintsReport(c *gin.Context) {
+	version := duration.RequestVersion(c.Request)
+	format, args, apiErr := parseReportRequest(c)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
 	}
 
 	query := `SELECT * FROM getsprintsreport($1::UUID, $2::DATE, $3::DATE)`
 
-	rows, err := db.Raw(query, args...).Rows()
+	rows, err := db.WithContext(c.Request.Context()).Raw(query, args...).Rows()
 	if err != nil {
-		c.JSON(500, ApiResponse{Message: "Database error: " + err.Error()})
+		abortWithError(c, apierror.Internal("Failed to load report", err))
 		return
 	}
 	defer func(rows *sql.Rows) {
@@ -439,6 +520,13 @@ func getSprintsReport(c *gin.Context) {
 		}
 	}(rows)
 
+	var export *reportExport
+	if format != formatJSON {
+		export = startReportExport(c, format, "sprints_report", "Sprints report", sprintExportColumns)
+		// Exports give hours, which need the parsed durations.
+		version = duration.Version2
+	}
+
 	var reports []SprintReport
 	for rows.Next() {
 		var report SprintReport
@@ -454,90 +542,55 @@ func getSprintsReport(c *gin.Context) {
 			&completedRatio,
 		)
 		if err != nil {
-			c.JSON(500, ApiResponse{Message: "Scan error: " + err.Error()})
+			reportError(c, export, apierror.Internal("Failed to read report row", err))
 			return
 		}
 
-		if totalTaskTime.Valid {
-			report.TotalTaskTime = totalTaskTime.String
-		} else {
-			report.TotalTaskTime = "00:00:00"
+		if err := report.TaskTime.set(totalTaskTime, version); err != nil {
+			reportError(c, export, apierror.Internal("Failed to read total task time", err))
+			return
 		}
 
 		if completedRatio.Valid {
 			report.CompletedRatio = &completedRatio.Float64
 		}
 
+		if export != nil {
+			export.Row(report.exportRow())
+			continue
+		}
 		reports = append(reports, report)
 	}
+	if err := rows.Err(); err != nil {
+		reportError(c, export, apierror.Internal("Failed to load report", err))
+		return
+	}
 
-	c.JSON(200, ApiResponse{
+	if export != nil {
+		export.Close()
+		return
+	}
+
+	writeReport(c, ApiResponse{
 		Message: "Sprints report retrieved",
 		Data:    reports,
 	})
 }
 
-// GET /api/reports/teams?managerId={uuid}&startDate={date}&endDate={date}
+// GET /api/reports/teams?managerId={uuid}&startDate={date}&endDate={date}&format=json|csv|xlsx|pdf
 func getTeamsReport(c *gin.Context) {
-	managerID := c.Query("managerId")
-	startDate := c.Query("startDate")
-	endDate := c.Query("endDate")
-
-	var args []interface{}
-	var managerUUID *uuid.UUID
-	var startDateParsed *time.Time
-	var endDateParsed *time.Time
-
-	if managerID != "" {
-		if parsed, err := uuid.Parse(managerID); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid manager ID format"})
-			return
-		} else {
-			managerUUID = &parsed
-		}
-	}
-
-	if startDate != "" {
-		if parsed, err := time.Parse("2006-01-02", startDate); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid start date format. Use YYYY-MM-DD"})
-			return
-		} else {
-			startDateParsed = &parsed
-		}
-	}
-
-	if endDate != "" {
-		if parsed, err := time.Parse("2006-01-02", endDate); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid end date format. Use YYYY-MM-DD"})
-			return
-		} else {
-			endDateParsed = &parsed
-		}
-	}
-
-	if managerUUID != nil {
-		args = append(args, *managerUUID)
-	} else {
-		args = append(args, nil)
-	}
-
-	if startDateParsed != nil {
-		args = append(args, *startDateParsed)
-	} else {
-		args = append(args, nil)
-	}
-
-	if endDateParsed != nil {
-		args = append(args, *endDateParsed)
-	} else {
-		args = append(args, nil)
+	version := duration.RequestVersion(c.Request)
+	format, args, apiErr := parseReportRequest(c)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
 	}
 
 	query := `SELECT * FROM getteamsreport($1::UUID, $2::DATE, $3::DATE)`
 
-	rows, err := db.Raw(query, args...).Rows()
+	rows, err := db.WithContext(c.Request.Context()).Raw(query, args...).Rows()
 	if err != nil {
-		c.JSON(500, ApiResponse{Message: "Database error: " + err.Error()})
+		abortWithError(c, apierror.Internal("Failed to load report", err))
 		return
 	}
 	defer func(rows *sql.Rows) {
@@ -547,6 +600,13 @@ func getTeamsReport(c *gin.Context) {
 		}
 	}(rows)
 
+	var export *reportExport
+	if format != formatJSON {
+		export = startReportExport(c, format, "teams_report", "Teams report", teamExportColumns)
+		// Exports give hours, which need the parsed durations.
+		version = duration.Version2
+	}
+
 	var reports []TeamReport
 	for rows.Next() {
 		var report TeamReport
@@ -563,14 +623,14 @@ func getTeamsReport(c *gin.Context) {
 			&totalTaskTime,
 		)
 		if err != nil {
-			c.JSON(500, ApiResponse{Message: "Scan error: " + err.Error()})
+			reportError(c, export, apierror.Internal("Failed to read report row", err))
 			return
 		}
 
 		if developerIdsStr.Valid {
 			uuids, err := parseUUIDArray(developerIdsStr.String)
 			if err != nil {
-				c.JSON(500, ApiResponse{Message: "Error parsing developer IDs: " + err.Error()})
+				reportError(c, export, apierror.Internal("Failed to read developer IDs", err))
 				return
 			}
 			report.DeveloperIds = uuids
@@ -584,82 +644,47 @@ func getTeamsReport(c *gin.Context) {
 			report.SprintsNames = []string{}
 		}
 
-		if totalTaskTime.Valid {
-			report.TotalTaskTime = totalTaskTime.String
-		} else {
-			report.TotalTaskTime = "00:00:00"
+		if err := report.TaskTime.set(totalTaskTime, version); err != nil {
+			reportError(c, export, apierror.Internal("Failed to read total task time", err))
+			return
 		}
 
+		if export != nil {
+			export.Row(report.exportRow())
+			continue
+		}
 		reports = append(reports, report)
 	}
+	if err := rows.Err(); err != nil {
+		reportError(c, export, apierror.Internal("Failed to load report", err))
+		return
+	}
 
-	c.JSON(200, ApiResponse{
+	if export != nil {
+		export.Close()
+		return
+	}
+
+	writeReport(c, ApiResponse{
 		Message: "Teams report retrieved",
 		Data:    reports,
 	})
 }
 
-// GET /api/reports/teams?managerId={uuid}&startDate={date}&endDate={date}
+// GET /api/reports/projects?managerId={uuid}&startDate={date}&endDate={date}&format=json|csv|xlsx|pdf
 func getProjectsReport(c *gin.Context) {
-	managerID := c.Query("managerId")
-	startDate := c.Query("startDate")
-	endDate := c.Query("endDate")
-
-	var args []interface{}
-	var managerUUID *uuid.UUID
-	var startDateParsed *time.Time
-	var endDateParsed *time.Time
-
-	if managerID != "" {
-		if parsed, err := uuid.Parse(managerID); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid manager ID format"})
-			return
-		} else {
-			managerUUID = &parsed
-		}
-	}
-
-	if startDate != "" {
-		if parsed, err := time.Parse("2006-01-02", startDate); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid start date format. Use YYYY-MM-DD"})
-			return
-		} else {
-			startDateParsed = &parsed
-		}
-	}
-
-	if endDate != "" {
-		if parsed, err := time.Parse("2006-01-02", endDate); err != nil {
-			c.JSON(400, ApiResponse{Message: "Invalid end date format. Use YYYY-MM-DD"})
-			return
-		} else {
-			endDateParsed = &parsed
-		}
-	}
-
-	if managerUUID != nil {
-		args = append(args, *managerUUID)
-	} else {
-		args = append(args, nil)
-	}
-
-	if startDateParsed != nil {
-		args = append(args, *startDateParsed)
-	} else {
-		args = append(args, nil)
-	}
-
-	if endDateParsed != nil {
-		args = append(args, *endDateParsed)
-	} else {
-		args = append(args, nil)
+	version := duration.RequestVersion(c.Request)
+	format, args, apiErr := parseReportRequest(c)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
 	}
 
 	query := `SELECT * FROM getprojectsreport($1::UUID, $2::DATE, $3::DATE)`
 
-	rows, err := db.Raw(query, args...).Rows()
+	rows, err := db.WithContext(c.Request.Context()).Raw(query, args...).Rows()
 	if err != nil {
-		c.JSON(500, ApiResponse{Message: "Database error: " + err.Error()})
+		abortWithError(c, apierror.Internal("Failed to load report", err))
 		return
 	}
 	defer func(rows *sql.Rows) {
@@ -669,6 +694,13 @@ func getProjectsReport(c *gin.Context) {
 		}
 	}(rows)
 
+	var export *reportExport
+	if format != formatJSON {
+		export = startReportExport(c, format, "projects_report", "Projects report", projectExportColumns)
+		// Exports give hours, which need the parsed durations.
+		version = duration.Version2
+	}
+
 	var reports []ProjectReport
 	for rows.Next() {
 		var report ProjectReport
@@ -688,30 +720,47 @@ func getProjectsReport(c *gin.Context) {
 			&completedRatio,
 		)
 		if err != nil {
-			c.JSON(500, ApiResponse{Message: "Scan error: " + err.Error()})
+			reportError(c, export, apierror.Internal("Failed to read report row", err))
 			return
 		}
 
-		if totalTaskTime.Valid {
-			report.TotalTaskTime = totalTaskTime.String
-		} else {
-			report.TotalTaskTime = "00:00:00"
+		if err := report.TaskTime.set(totalTaskTime, version); err != nil {
+			reportError(c, export, apierror.Internal("Failed to read total task time", err))
+			return
 		}
 
 		if completedRatio.Valid {
 			report.CompletedRatio = &completedRatio.Float64
 		}
 
+		if export != nil {
+			export.Row(report.exportRow())
+			continue
+		}
 		reports = append(reports, report)
 	}
+	if err := rows.Err(); err != nil {
+		reportError(c, export, apierror.Internal("Failed to load report", err))
+		return
+	}
 
-	c.JSON(200, ApiResponse{
+	if export != nil {
+		export.Close()
+		return
+	}
+
+	writeReport(c, ApiResponse{
 		Message: "Projects report retrieved",
 		Data:    reports,
 	})
 }
 
 // GET /api/reports/audit-logs?limit={number}&offset={number}
+//
+// Audit logs are only appended and archived, so the row count and the
+// highest Id identify the table's state. They give the ETag, and the newest
+// Timestamp is sent as Last-Modified; a matching conditional request gets a
+// 304 before the page is read.
 func getAuditLogs(c *gin.Context) {
 	limit := 10
 	offset := 0
@@ -729,15 +778,31 @@ func getAuditLogs(c *gin.Context) {
 	}
 
 	var auditLogs []AuditLog
-	var totalCount int64
+	var version struct {
+		Count  int64
+		MaxID  int64
+		Newest *time.Time
+	}
 
-	if err := db.Model(&AuditLog{}).Count(&totalCount).Error; err != nil {
-		c.JSON(500, ApiResponse{Message: "Database error: " + err.Error()})
+	err := db.WithContext(c.Request.Context()).Model(&AuditLog{}).
+		Select(`COUNT(*) AS count, COALESCE(MAX("Id"), 0) AS max_id, MAX("Timestamp") AS newest`).
+		Scan(&version).Error
+	if err != nil {
+		abortWithError(c, apierror.Internal("Failed to load report", err))
+		return
+	}
+	var lastModified time.Time
+	if version.Newest != nil {
+		lastModified = *version.Newest
+	}
+	etag := httpcache.ETag("auditLogs", limit, offset, version.Count, version.MaxID)
+	if httpcache.NotModified(c.Writer, c.Request, etag, lastModified) {
 		return
 	}
+	totalCount := version.Count
 
-	if err := db.Order("\"Timestamp\" DESC").Limit(limit).Offset(offset).Find(&auditLogs).Error; err != nil {
-		c.JSON(500, ApiResponse{Message: "Database error: " + err.Error()})
+	if err := db.WithContext(c.Request.Context()).Order("\"Timestamp\" DESC").Limit(limit).Offset(offset).Find(&auditLogs).Error; err != nil {
+		abortWithError(c, apierror.Internal("Failed to load report", err))
 		return
 	}
 
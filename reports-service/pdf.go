@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Page geometry of pdfWriter in points: A4 landscape with 36pt margins.
+const (
+	pdfPageWidth  = 842
+	pdfPageHeight = 595
+	pdfMargin     = 36
+	pdfFontSize   = 9
+	pdfLineHeight = 14
+	pdfCellPad    = 4
+)
+
+// Objects written before the pages; page objects follow in pairs of content
+// stream and page dictionary.
+const (
+	pdfCatalogObject = 1 + iota
+	pdfPagesObject
+	pdfFontObject
+	pdfBoldFontObject
+	pdfFirstPageObject
+)
+
+// pdfHelveticaWidths are the advance widths of Helvetica for the characters
+// from ' ' to '~', in thousandths of the font size.
+var pdfHelveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// pdfTextWidth returns the width of s in points, with bold text estimated
+// as 7% wider.
+func pdfTextWidth(s string, bold bool) float64 {
+	total := 0
+	for _, r := range s {
+		if r >= ' ' && r <= '~' {
+			total += pdfHelveticaWidths[r-' ']
+		} else {
+			total += 556
+		}
+	}
+	width := float64(total) * pdfFontSize / 1000
+	if bold {
+		width *= 1.07
+	}
+	return width
+}
+
+// pdfFit shortens s with an ellipsis until it fits in width points.
+func pdfFit(s string, width float64, bold bool) string {
+	if pdfTextWidth(s, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"...", bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// pdfString encodes s as a literal string in WinAnsiEncoding, which matches
+// Latin-1 for the characters used here; others become '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= ' ' && r <= '~', r >= 0xA0 && r <= 0xFF:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfWriter lays a report out as a table over as many pages as needed,
+// repeating the title and column headers on each. Every page is written as
+// soon as it is full, so only the current page is held in memory; the page
+// tree and cross-reference table, which need every page, come last.
+type pdfWriter struct {
+	w       *bufio.Writer
+	offset  int
+	offsets map[int]int
+	pages   []int
+
+	title   string
+	columns []exportColumn
+	widths  []float64
+	page    bytes.Buffer
+	y       float64
+	rows    int
+}
+
+func newPDFWriter(w io.Writer, title string, columns []exportColumn) (*pdfWriter, error) {
+	p := &pdfWriter{
+		w:       bufio.NewWriter(w),
+		offsets: map[int]int{},
+		title:   title + " - " + time.Now().UTC().Format("2006-01-02 15:04 UTC"),
+		columns: columns,
+	}
+
+	// Text columns get twice the share of the page that numbers get.
+	shares, total := make([]float64, len(columns)), 0.0
+	for i, column := range columns {
+		shares[i] = 1
+		if column.Kind == exportText {
+			shares[i] = 2
+		}
+		total += shares[i]
+	}
+	p.widths = make([]float64, len(columns))
+	for i := range columns {
+		p.widths[i] = (pdfPageWidth - 2*pdfMargin) * shares[i] / total
+	}
+
+	p.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	p.object(pdfFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	p.object(pdfBoldFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	p.startPage()
+	return p, p.w.Flush()
+}
+
+func (p *pdfWriter) write(s string) {
+	n, _ := p.w.WriteString(s)
+	p.offset += n
+}
+
+func (p *pdfWriter) object(id int, body string) {
+	p.offsets[id] = p.offset
+	p.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", id, body))
+}
+
+func (p *pdfWriter) text(x float64, s string, bold bool) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.page, "BT /%s %d Tf %.2f %.2f Td %s Tj ET\n", font, pdfFontSize, x, p.y, pdfString(s))
+}
+
+func (p *pdfWriter) startPage() {
+	p.page.Reset()
+	p.y = pdfPageHeight - pdfMargin - pdfFontSize
+	p.text(pdfMargin, p.title, true)
+	p.y -= 2 * pdfLineHeight
+
+	headers := make([]any, len(p.columns))
+	for i, column := range p.columns {
+		headers[i] = column.Header
+	}
+	p.cells(headers, true)
+	// A rule under the headers.
+	fmt.Fprintf(&p.page, "0.5 w %d %.2f m %d %.2f l S\n",
+		pdfMargin, p.y+pdfLineHeight-3, pdfPageWidth-pdfMargin, p.y+pdfLineHeight-3)
+}
+
+// cells writes one table row at the current line, numbers right aligned.
+func (p *pdfWriter) cells(values []any, bold bool) {
+	x := float64(pdfMargin)
+	for i, value := range values {
+		width := p.widths[i] - 2*pdfCellPad
+		s := pdfFit(exportString(value, p.columns[i].Kind), width, bold)
+		left := x + pdfCellPad
+		if !bold && p.columns[i].Kind != exportText {
+			left += width - pdfTextWidth(s, bold)
+		}
+		p.text(left, s, bold)
+		x += p.widths[i]
+	}
+	p.y -= pdfLineHeight
+}
+
+// endPage writes the current page's content stream and page dictionary.
+func (p *pdfWriter) endPage() {
+	number := len(p.pages) + 1
+	fmt.Fprintf(&p.page, "BT /F1 %d Tf %d %d Td %s Tj ET\n",
+		pdfFontSize, pdfPageWidth-pdfMargin-40, pdfMargin/2, pdfString(fmt.Sprintf("Page %d", number)))
+
+	content := pdfFirstPageObject + 2*len(p.pages)
+	p.object(content, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.page.Len(), p.page.String()))
+	p.object(content+1, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, content, pdfFontObject, pdfBoldFontObject))
+	p.pages = append(p.pages, content+1)
+}
+
+func (p *pdfWriter) WriteRow(values []any) error {
+	if p.y < pdfMargin+pdfLineHeight {
+		p.endPage()
+		p.startPage()
+	}
+	p.cells(values, false)
+	p.rows++
+	return nil
+}
+
+func (p *pdfWriter) Flush() error {
+	return p.w.Flush()
+}
+
+func (p *pdfWriter) Close() error {
+	if p.rows == 0 {
+		p.text(pdfMargin, "No rows match the filter.", false)
+	}
+	p.endPage()
+
+	kids := make([]string, len(p.pages))
+	for i, id := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	p.object(pdfPagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	p.object(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject))
+
+	size := pdfFirstPageObject + 2*len(p.pages)
+	xref := p.offset
+	p.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", size))
+	for id := 1; id < size; id++ {
+		p.write(fmt.Sprintf("%010d 00000 n \n", p.offsets[id]))
+	}
+	p.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, pdfCatalogObject, xref))
+	return p.w.Flush()
+}
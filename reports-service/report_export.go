@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-shared/apierror"
+	"go-shared/csvcell"
+)
+
+// Report formats besides JSON, with the media types that select them.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatXLSX = "xlsx"
+	formatPDF  = "pdf"
+
+	mediaTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var exportMediaTypes = map[string]string{
+	formatCSV:  "text/csv; charset=utf-8",
+	formatXLSX: mediaTypeXLSX,
+	formatPDF:  "application/pdf",
+}
+
+// exportFlushEvery is how many rows are written between flushes.
+const exportFlushEvery = 500
+
+// exportKind is the type of an exported column, which decides how its
+// values are formatted.
+type exportKind int
+
+const (
+	exportText exportKind = iota
+	exportInt
+	// exportHours values are float64 hours.
+	exportHours
+	// exportPercent values are ratios, *float64 or float64, shown as
+	// percentages.
+	exportPercent
+	// exportDate values are *time.Time or time.Time.
+	exportDate
+)
+
+type exportColumn struct {
+	Header string
+	Kind   exportKind
+}
+
+// tableWriter writes a report as rows of values matching its columns.
+type tableWriter interface {
+	WriteRow(values []any) error
+	// Flush sends what has been buffered so far to the client.
+	Flush() error
+	// Close writes whatever the format needs after the last row.
+	Close() error
+}
+
+// reportFormat picks the output format from ?format= or the Accept header,
+// JSON unless one of the export types is asked for.
+func reportFormat(c *gin.Context) (string, bool) {
+	switch format := strings.ToLower(c.Query("format")); format {
+	case formatJSON, formatCSV, formatXLSX, formatPDF:
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+	accept := c.GetHeader("Accept")
+	for _, format := range []string{formatCSV, formatXLSX, formatPDF} {
+		mediaType, _, _ := strings.Cut(exportMediaTypes[format], ";")
+		if strings.Contains(accept, mediaType) {
+			return format, true
+		}
+	}
+	return formatJSON, true
+}
+
+// parseReportRequest validates the format and the shared report filter.
+func parseReportRequest(c *gin.Context) (string, []any, *apierror.Error) {
+	format, ok := reportFormat(c)
+	args, apiErr := parseReportFilter(c)
+	if !ok {
+		details := []apierror.Detail{apierror.Field("format", "use json, csv, xlsx or pdf")}
+		if apiErr != nil {
+			details = append(details, apiErr.Details...)
+		}
+		apiErr = apierror.InvalidArgument("Invalid query parameters", details...)
+	}
+	return format, args, apiErr
+}
+
+// exportValue dereferences pointers, giving nil for nil ones.
+func exportValue(value any) any {
+	switch v := value.(type) {
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
+
+// exportString formats value for the text formats: hours with two
+// decimals, ratios as percentages and dates as YYYY-MM-DD.
+func exportString(value any, kind exportKind) string {
+	switch v := exportValue(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		if kind == exportPercent {
+			return strconv.FormatFloat(v*100, 'f', 2, 64) + "%"
+		}
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+	return fmt.Sprint(value)
+}
+
+// csvTableWriter writes ratios as plain percentages without the sign, so
+// spreadsheets read them as numbers; the header says the unit. Text cells
+// that would be read as a formula are prefixed with an apostrophe.
+type csvTableWriter struct {
+	w       *csv.Writer
+	columns []exportColumn
+}
+
+func newCSVTableWriter(w io.Writer, columns []exportColumn) (*csvTableWriter, error) {
+	t := &csvTableWriter{w: csv.NewWriter(w), columns: columns}
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Header
+		if column.Kind == exportPercent {
+			headers[i] += " (%)"
+		}
+	}
+	return t, t.w.Write(headers)
+}
+
+func (t *csvTableWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		cell := exportString(value, t.columns[i].Kind)
+		switch {
+		case t.columns[i].Kind == exportPercent:
+			cell = strings.TrimSuffix(cell, "%")
+		case t.columns[i].Kind == exportText:
+			cell = csvcell.Text(cell)
+		}
+		record[i] = cell
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTableWriter) Flush() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+func (t *csvTableWriter) Close() error {
+	return t.Flush()
+}
+
+// reportExport streams a report in an export format. Once it has started
+// the status is already 200, so a later failure is logged and the connection
+// dropped, leaving the client with a body that is visibly cut short.
+type reportExport struct {
+	c       *gin.Context
+	name    string
+	table   tableWriter
+	written int
+}
+
+// startReportExport sends the download headers and the column headers.
+func startReportExport(c *gin.Context, format, name, title string, columns []exportColumn) *reportExport {
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Type", exportMediaTypes[format])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Vary", "Accept")
+	c.Status(200)
+
+	var table tableWriter
+	var err error
+	switch format {
+	case formatCSV:
+		table, err = newCSVTableWriter(c.Writer, columns)
+	case formatXLSX:
+		table, err = newXLSXWriter(c.Writer, title, columns)
+	case formatPDF:
+		table, err = newPDFWriter(c.Writer, title, columns)
+	}
+	e := &reportExport{c: c, name: title}
+	if err != nil {
+		e.abort(err)
+	}
+	e.table = table
+	return e
+}
+
+// Row writes one report row.
+func (e *reportExport) Row(values []any) {
+	if err := e.table.WriteRow(values); err != nil {
+		e.abort(err)
+	}
+	e.written++
+	if e.written%exportFlushEvery == 0 {
+		if err := e.flush(); err != nil {
+			// Usually the client went away.
+			e.abort(err)
+		}
+	}
+}
+
+func (e *reportExport) flush() error {
+	if err := e.table.Flush(); err != nil {
+		return err
+	}
+	e.c.Writer.Flush()
+	return nil
+}
+
+// Fail ends the export early.
+func (e *reportExport) Fail(apiErr *apierror.Error) {
+	e.abort(apiErr)
+}
+
+func (e *reportExport) Close() {
+	if err := e.table.Close(); err != nil {
+		e.abort(err)
+	}
+	e.c.Writer.Flush()
+}
+
+// abort logs err, sends what has been written so far and drops the
+// connection, so that the client does not take the body for a whole report.
+// It does not return.
+func (e *reportExport) abort(err error) {
+	log.Printf("%s export aborted after %d rows: %v", e.name, e.written, err)
+	if e.table != nil {
+		_ = e.table.Flush()
+	}
+	e.c.Writer.Flush()
+	panic(http.ErrAbortHandler)
+}
+
+// recoverPanic answers a panicking request with 500 like gin's default
+// recovery, but lets http.ErrAbortHandler through to net/http, which then
+// closes the connection instead of ending the response normally.
+func recoverPanic(c *gin.Context, err any) {
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+// reportError sends apiErr, or ends export early when one has started.
+func reportError(c *gin.Context, export *reportExport, apiErr *apierror.Error) {
+	if export != nil {
+		export.Fail(apiErr)
+		return
+	}
+	abortWithError(c, apiErr)
+}
+
+// taskHours returns the total task time of a report in hours. It needs the
+// seconds set only for version 2, which exports always ask for.
+func taskHours(t TaskTime) float64 {
+	if t.TotalTaskTimeSeconds == nil {
+		return 0
+	}
+	return *t.TotalTaskTimeSeconds / 3600
+}
+
+var sprintExportColumns = []exportColumn{
+	{"Sprint ID", exportText},
+	{"Sprint", exportText},
+	{"Tasks", exportInt},
+	{"Completed tasks", exportInt},
+	{"Hours", exportHours},
+	{"Completed", exportPercent},
+}
+
+func (r SprintReport) exportRow() []any {
+	return []any{
+		r.SprintID.String(), r.SprintName, r.TaskCount, r.TaskCountCompleted,
+		taskHours(r.TaskTime), r.CompletedRatio,
+	}
+}
+
+var teamExportColumns = []exportColumn{
+	{"Developers", exportInt},
+	{"Developer IDs", exportText},
+	{"Sprints", exportText},
+	{"Tasks", exportInt},
+	{"Completed tasks", exportInt},
+	{"Hours", exportHours},
+}
+
+func (r TeamReport) exportRow() []any {
+	ids := make([]string, len(r.DeveloperIds))
+	for i, id := range r.DeveloperIds {
+		ids[i] = id.String()
+	}
+	return []any{
+		r.DeveloperCount, strings.Join(ids, "; "), strings.Join(r.SprintsNames, "; "),
+		r.TaskCount, r.TaskCountCompleted, taskHours(r.TaskTime),
+	}
+}
+
+var projectExportColumns = []exportColumn{
+	{"Project ID", exportText},
+	{"Project", exportText},
+	{"Company", exportText},
+	{"Sprints", exportInt},
+	{"Tasks", exportInt},
+	{"Completed tasks", exportInt},
+	{"Hours", exportHours},
+	{"Start date", exportDate},
+	{"End date", exportDate},
+	{"Completed", exportPercent},
+}
+
+func (r ProjectReport) exportRow() []any {
+	return []any{
+		r.ProjectID.String(), r.ProjectName, r.CompanyName, r.SprintCount, r.TaskCount,
+		r.TaskCountCompleted, taskHours(r.TaskTime), r.ProjectStartDate, r.ProjectEndDate,
+		r.CompletedRatio,
+	}
+}
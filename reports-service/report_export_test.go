@@ -0,0 +1,201 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-shared/apierror"
+)
+
+var exportTestColumns = []exportColumn{
+	{"Name", exportText},
+	{"Tasks", exportInt},
+	{"Hours", exportHours},
+	{"Completed", exportPercent},
+	{"End date", exportDate},
+}
+
+func exportTestRow(i int) []any {
+	ratio := 0.755
+	end := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	return []any{fmt.Sprintf("Sprint (%d) & <more>", i), i, 12.5, &ratio, &end}
+}
+
+func writeExportTable(t *testing.T, table tableWriter, rows int) {
+	t.Helper()
+	for i := range rows {
+		if err := table.WriteRow(exportTestRow(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVTableWriter(t *testing.T) {
+	var buf bytes.Buffer
+	table, err := newCSVTableWriter(&buf, exportTestColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeExportTable(t, table, 1)
+	want := "Name,Tasks,Hours,Completed (%),End date\nSprint (0) & <more>,0,12.50,75.50,2025-03-31\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	table, err := newXLSXWriter(&buf, "Sprints report", exportTestColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeExportTable(t, table, 3)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			body, _ := io.ReadAll(r)
+			sheet = string(body)
+		}
+	}
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">Name</t></is></c>`,
+		`<t xml:space="preserve">Sprint (2) &amp; &lt;more&gt;</t>`,
+		`<c r="D4" s="4"><v>0.755</v></c>`,
+		`<c r="E2" s="5"><v>45747</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet lacks %s", want)
+		}
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestPDFWriterCrossReferences(t *testing.T) {
+	var buf bytes.Buffer
+	table, err := newPDFWriter(&buf, "Sprints report", exportTestColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeExportTable(t, table, 100)
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("missing PDF header or trailer")
+	}
+	if !strings.Contains(pdf, `(Sprint \(99\) & <more>)`) {
+		t.Error("last row is missing or not escaped")
+	}
+	if pages := strings.Count(pdf, "/Type /Page "); pages < 2 {
+		t.Errorf("got %d pages, want the rows to overflow onto a second", pages)
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)
+	offset, _ := strconv.Atoi(startxref[1])
+	if !strings.HasPrefix(pdf[offset:], "xref\n") {
+		t.Fatal("startxref does not point at the xref table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[offset:], -1)
+	for i, entry := range entries {
+		at, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(pdf[at:], want) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[at:at+10])
+		}
+	}
+}
+
+func TestCSVTableWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	columns := []exportColumn{{"Name", exportText}, {"Tasks", exportInt}, {"Completed", exportPercent}}
+	table, err := newCSVTableWriter(&buf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ratio := -0.25
+	for _, name := range []string{"=HYPERLINK(\"x\")", "+1", "-1", "@SUM(A1)", "plain", ""} {
+		if err := table.WriteRow([]any{name, -3, &ratio}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "Name,Tasks,Completed (%)\n" +
+		"\"'=HYPERLINK(\"\"x\"\")\",-3,-25.00\n'+1,-3,-25.00\n'-1,-3,-25.00\n'@SUM(A1),-3,-25.00\n" +
+		"plain,-3,-25.00\n,-3,-25.00\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReportExportFailDropsConnection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, recoverPanic))
+	r.GET("/export", func(c *gin.Context) {
+		export := startReportExport(c, formatCSV, "test", "Test report", exportTestColumns)
+		for i := range exportFlushEvery + 1 {
+			export.Row(exportTestRow(i))
+		}
+		reportError(c, export, apierror.Internal("Failed to load report", errors.New("connection lost")))
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		t.Fatal("body ended normally after the export failed")
+	}
+	// The rows written before the failure still reach the client.
+	if lines := strings.Count(string(body), "\n"); lines < exportFlushEvery+1 {
+		t.Errorf("got %d lines before the connection dropped, want at least %d", lines, exportFlushEvery+1)
+	}
+}
+
+func TestRecoverPanicAnswers500(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, recoverPanic))
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+}
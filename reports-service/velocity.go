@@ -0,0 +1,284 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"go-shared/apierror"
+)
+
+const (
+	defaultVelocitySprints = 6
+	maxVelocitySprints     = 52
+	defaultVelocityWindow  = 3
+)
+
+// VelocityPoint is one sprint of a team's velocity series. The rolling
+// values cover this sprint and up to window-1 sprints before it.
+type VelocityPoint struct {
+	SprintID               uuid.UUID `json:"sprintId"`
+	SprintName             string    `json:"sprintName"`
+	StartDate              string    `json:"startDate"`
+	EndDate                string    `json:"endDate"`
+	InProgress             bool      `json:"inProgress"`
+	TaskCount              int       `json:"taskCount"`
+	TaskCountCompleted     int       `json:"taskCountCompleted"`
+	CompletedRatio         *float64  `json:"completedRatio"`
+	HoursLogged            float64   `json:"hoursLogged"`
+	RollingAvgCompleted    float64   `json:"rollingAvgCompleted"`
+	RollingStdDevCompleted *float64  `json:"rollingStdDevCompleted"`
+	RollingAvgHours        float64   `json:"rollingAvgHours"`
+}
+
+// VelocitySummary describes the whole series of a team. Standard deviations
+// are sample deviations and null with fewer than two sprints.
+type VelocitySummary struct {
+	SprintCount       int      `json:"sprintCount"`
+	AvgCompleted      float64  `json:"avgCompleted"`
+	StdDevCompleted   *float64 `json:"stdDevCompleted"`
+	AvgHours          float64  `json:"avgHours"`
+	StdDevHours       *float64 `json:"stdDevHours"`
+	AvgCompletedRatio *float64 `json:"avgCompletedRatio"`
+	TrendCompleted    *float64 `json:"trendCompleted"`
+}
+
+// TeamVelocity is the velocity report of one team, oldest sprint first.
+type TeamVelocity struct {
+	TeamID   uuid.UUID       `json:"teamId"`
+	TeamName string          `json:"teamName"`
+	Sprints  []VelocityPoint `json:"sprints"`
+	Summary  VelocitySummary `json:"summary"`
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stdDev returns the sample standard deviation, or nil for fewer than two
+// values.
+func stdDev(values []float64) *float64 {
+	if len(values) < 2 {
+		return nil
+	}
+	m := mean(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	sd := round2(math.Sqrt(sum / float64(len(values)-1)))
+	return &sd
+}
+
+// slope returns the least squares slope of values against their index, the
+// change in completed tasks per sprint, or nil for fewer than two values.
+func slope(values []float64) *float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return nil
+	}
+	meanX, meanY := (n-1)/2, mean(values)
+	var num, den float64
+	for i, v := range values {
+		dx := float64(i) - meanX
+		num += dx * (v - meanY)
+		den += dx * dx
+	}
+	s := round2(num / den)
+	return &s
+}
+
+// fillVelocity computes the rolling values of points, which are in sprint
+// order, and the team summary.
+func fillVelocity(points []VelocityPoint, window int) VelocitySummary {
+	completed := make([]float64, len(points))
+	hours := make([]float64, len(points))
+	var ratios []float64
+	for i, p := range points {
+		completed[i] = float64(p.TaskCountCompleted)
+		hours[i] = p.HoursLogged
+		if p.CompletedRatio != nil {
+			ratios = append(ratios, *p.CompletedRatio)
+		}
+	}
+
+	for i := range points {
+		from := max(0, i-window+1)
+		points[i].RollingAvgCompleted = round2(mean(completed[from : i+1]))
+		points[i].RollingStdDevCompleted = stdDev(completed[from : i+1])
+		points[i].RollingAvgHours = round2(mean(hours[from : i+1]))
+	}
+
+	summary := VelocitySummary{
+		SprintCount:     len(points),
+		AvgCompleted:    round2(mean(completed)),
+		StdDevCompleted: stdDev(completed),
+		AvgHours:        round2(mean(hours)),
+		StdDevHours:     stdDev(hours),
+		TrendCompleted:  slope(completed),
+	}
+	if len(ratios) > 0 {
+		avg := round2(mean(ratios))
+		summary.AvgCompletedRatio = &avg
+	}
+	return summary
+}
+
+// parseVelocityQuery reads teamId, managerId, count, window and
+// includeCurrent into the arguments of loadVelocity, adding problems to v.
+func parseVelocityQuery(c *gin.Context, v *apierror.Validation) (map[string]any, int) {
+	args := map[string]any{
+		"team":    nil,
+		"manager": nil,
+		"current": c.Query("includeCurrent") == "true",
+	}
+	if teamID := c.Query("teamId"); teamID != "" {
+		if parsed, err := uuid.Parse(teamID); err != nil {
+			v.Add("teamId", "not a valid UUID: "+teamID)
+		} else {
+			args["team"] = parsed
+		}
+	}
+	if managerID := c.Query("managerId"); managerID != "" {
+		if parsed, err := uuid.Parse(managerID); err != nil {
+			v.Add("managerId", "not a valid UUID: "+managerID)
+		} else {
+			args["manager"] = parsed
+		}
+	}
+	count := defaultVelocitySprints
+	if raw := c.Query("count"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxVelocitySprints {
+			v.Add("count", "must be between 1 and "+strconv.Itoa(maxVelocitySprints))
+		} else {
+			count = parsed
+		}
+	}
+	args["count"] = count
+	window := defaultVelocityWindow
+	if raw := c.Query("window"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxVelocitySprints {
+			v.Add("window", "must be between 1 and "+strconv.Itoa(maxVelocitySprints))
+		} else {
+			window = parsed
+		}
+	}
+	return args, window
+}
+
+// loadVelocity returns the velocity of every team matching args, with the
+// rolling values over window sprints filled in.
+func loadVelocity(tx *gorm.DB, args map[string]any, window int) ([]TeamVelocity, error) {
+	query := `
+		WITH ranked AS (
+			SELECT s."Id", s."Name", s."StartDate", s."EndDate", tm."Id" AS team_id, tm."Name" AS team_name,
+				ROW_NUMBER() OVER (PARTITION BY tm."Id" ORDER BY s."StartDate" DESC, s."Id") AS rn
+			FROM "Sprints" s
+			JOIN "Teams" tm ON tm."Id" = s."TeamId"
+			WHERE (@team::UUID IS NULL OR tm."Id" = @team)
+			  AND (@manager::UUID IS NULL OR tm."ManagerId" = @manager)
+			  AND (s."EndDate" < CURRENT_DATE OR (@current AND s."StartDate" <= CURRENT_DATE))
+		)
+		SELECT r.team_id, r.team_name, r."Id", r."Name", r."StartDate", r."EndDate",
+			r."EndDate" >= CURRENT_DATE AS in_progress,
+			(SELECT COUNT(*) FROM "Tasks" t WHERE t."SprintId" = r."Id") AS task_count,
+			(SELECT COUNT(DISTINCT t."Id")
+				FROM "TaskHistories" th
+				JOIN "Tasks" t ON th."TaskId" = t."Id"
+				WHERE t."SprintId" = r."Id" AND th."NewStatus" = 'Completed') AS task_count_completed,
+			(SELECT COALESCE(EXTRACT(EPOCH FROM SUM(d.total_duration)), 0)
+				FROM "Tasks" t, LATERAL GetSingleTaskDetails(t."Id") d
+				WHERE t."SprintId" = r."Id") AS seconds_logged
+		FROM ranked r
+		WHERE r.rn <= @count
+		ORDER BY r.team_name, r.team_id, r."StartDate", r."Id"`
+
+	rows, err := tx.Raw(query, args).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err := rows.Close(); err != nil {
+			log.Println("Error closing rows:", err)
+		}
+	}(rows)
+
+	teams := []TeamVelocity{}
+	for rows.Next() {
+		var teamID uuid.UUID
+		var teamName string
+		var p VelocityPoint
+		var start, end time.Time
+		var seconds float64
+		err := rows.Scan(&teamID, &teamName, &p.SprintID, &p.SprintName, &start, &end,
+			&p.InProgress, &p.TaskCount, &p.TaskCountCompleted, &seconds)
+		if err != nil {
+			return nil, err
+		}
+		p.StartDate, p.EndDate = start.Format("2006-01-02"), end.Format("2006-01-02")
+		p.HoursLogged = round2(seconds / 3600)
+		if p.TaskCount > 0 {
+			ratio := float64(p.TaskCountCompleted) / float64(p.TaskCount)
+			p.CompletedRatio = &ratio
+		}
+
+		if len(teams) == 0 || teams[len(teams)-1].TeamID != teamID {
+			teams = append(teams, TeamVelocity{TeamID: teamID, TeamName: teamName})
+		}
+		team := &teams[len(teams)-1]
+		team.Sprints = append(team.Sprints, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range teams {
+		teams[i].Summary = fillVelocity(teams[i].Sprints, window)
+	}
+	return teams, nil
+}
+
+// GET /api/reports/velocity?teamId={uuid}&managerId={uuid}&count={1-52}&window={n}&includeCurrent=true
+//
+// The last count sprints of every team, or of teamId or the team managed by
+// managerId, oldest first. Tasks completed, task count and hours logged are
+// counted the way GetSprintsReport counts them. Only finished sprints are
+// included unless includeCurrent=true, which adds the running one.
+func getVelocityReport(c *gin.Context) {
+	var v apierror.Validation
+	args, window := parseVelocityQuery(c, &v)
+	if apiErr := v.Err("Invalid query parameters"); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	teams, err := loadVelocity(db.WithContext(c.Request.Context()), args, window)
+	if err != nil {
+		abortWithError(c, apierror.Internal("Failed to load report", err))
+		return
+	}
+
+	writeReport(c, ApiResponse{
+		Message: "Velocity report retrieved",
+		Data:    teams,
+	})
+}
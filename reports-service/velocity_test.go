@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func equalFloatPtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestFillVelocityRollingWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		completed  []int
+		window     int
+		wantAvg    []float64
+		wantStdDev []*float64
+	}{
+		{"single sprint", []int{4}, 3, []float64{4}, []*float64{nil}},
+		{"fewer sprints than the window", []int{2, 4}, 3, []float64{2, 3}, []*float64{nil, floatPtr(1.41)}},
+		{"window filled", []int{2, 4, 6, 8}, 3, []float64{2, 3, 4, 6}, []*float64{nil, floatPtr(1.41), floatPtr(2), floatPtr(2)}},
+		{"window of one", []int{1, 5}, 1, []float64{1, 5}, []*float64{nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := make([]VelocityPoint, len(tt.completed))
+			for i, c := range tt.completed {
+				points[i] = VelocityPoint{TaskCountCompleted: c, HoursLogged: float64(2 * c)}
+			}
+			summary := fillVelocity(points, tt.window)
+			if summary.SprintCount != len(points) {
+				t.Errorf("sprint count = %d, want %d", summary.SprintCount, len(points))
+			}
+			for i, p := range points {
+				if p.RollingAvgCompleted != tt.wantAvg[i] {
+					t.Errorf("sprint %d rolling average = %v, want %v", i, p.RollingAvgCompleted, tt.wantAvg[i])
+				}
+				if p.RollingAvgHours != 2*tt.wantAvg[i] {
+					t.Errorf("sprint %d rolling hours = %v, want %v", i, p.RollingAvgHours, 2*tt.wantAvg[i])
+				}
+				if !equalFloatPtr(p.RollingStdDevCompleted, tt.wantStdDev[i]) {
+					t.Errorf("sprint %d rolling deviation = %v, want %v", i, p.RollingStdDevCompleted, tt.wantStdDev[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFillVelocitySummary(t *testing.T) {
+	points := []VelocityPoint{
+		{TaskCountCompleted: 2, CompletedRatio: floatPtr(0.5)},
+		{TaskCountCompleted: 4},
+		{TaskCountCompleted: 6, CompletedRatio: floatPtr(1)},
+	}
+	summary := fillVelocity(points, 5)
+	if summary.AvgCompleted != 4 || !equalFloatPtr(summary.StdDevCompleted, floatPtr(2)) {
+		t.Errorf("completed = %v ± %v, want 4 ± 2", summary.AvgCompleted, summary.StdDevCompleted)
+	}
+	if !equalFloatPtr(summary.TrendCompleted, floatPtr(2)) {
+		t.Errorf("trend = %v, want 2", summary.TrendCompleted)
+	}
+	if !equalFloatPtr(summary.AvgCompletedRatio, floatPtr(0.75)) {
+		t.Errorf("completed ratio = %v, want 0.75 over the sprints that have one", summary.AvgCompletedRatio)
+	}
+
+	if summary := fillVelocity(nil, 3); summary.StdDevCompleted != nil || summary.TrendCompleted != nil {
+		t.Errorf("empty series summary = %+v", summary)
+	}
+}
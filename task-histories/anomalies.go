@@ -0,0 +1,346 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "math"
+    "slices"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+
+    "go-shared/apierror"
+    "go-shared/config"
+)
+
+// Anomaly kinds.
+const (
+    AnomalyLongRunning     = "long_running"
+    AnomalyMissingStop     = "missing_stop"
+    AnomalyOutOfOrder      = "out_of_order"
+    AnomalyDuplicateStatus = "duplicate_status"
+)
+
+var anomalyKinds = []string{AnomalyLongRunning, AnomalyMissingStop, AnomalyOutOfOrder, AnomalyDuplicateStatus}
+
+const anomalyQueue = "task_anomalies"
+
+// anomalyRefreshAge is how old the latest scan must be before refresh=true
+// scans again, so callers cannot keep the database busy with full scans.
+const anomalyRefreshAge = time.Minute
+
+// Anomaly is one suspicious spot in a task's history. HistoryID is the row
+// the anomaly was found at.
+type Anomaly struct {
+    TaskID     uuid.UUID `json:"task_id"`
+    Kind       string    `json:"kind"`
+    HistoryID  uuid.UUID `json:"history_id"`
+    ChangeDate time.Time `json:"change_date"`
+    Message    string    `json:"message"`
+}
+
+func (a Anomaly) key() string {
+    return a.Kind + "|" + a.HistoryID.String()
+}
+
+// arose returns when a became an anomaly: when its row was recorded, or for
+// a long running task when it passed maxStarted.
+func (a Anomaly) arose(maxStarted time.Duration) time.Time {
+    if a.Kind == AnomalyLongRunning {
+        return a.ChangeDate.Add(maxStarted)
+    }
+    return a.ChangeDate
+}
+
+type anomalyConfig struct {
+    // Interval between scans; zero disables the background job.
+    Interval time.Duration
+    // MaxStarted is how long a task may stay Started before it is reported.
+    MaxStarted time.Duration
+    // Alerts publishes newly found anomalies to the task_anomalies queue.
+    Alerts bool
+}
+
+func loadAnomalyConfig(l *config.Loader) anomalyConfig {
+    cfg := anomalyConfig{
+        Interval:   l.Duration("ANOMALY_SCAN_INTERVAL", 15*time.Minute),
+        MaxStarted: l.Duration("ANOMALY_MAX_STARTED", 12*time.Hour),
+        Alerts:     l.Bool("ANOMALY_ALERTS_ENABLED", false),
+    }
+    if cfg.Interval < 0 {
+        l.Invalid("ANOMALY_SCAN_INTERVAL", "must not be negative")
+    }
+    if cfg.MaxStarted <= 0 {
+        l.Invalid("ANOMALY_MAX_STARTED", "must be positive")
+    }
+    return cfg
+}
+
+// detectAnomalies checks one task's history in ChangeDate order.
+//
+// Each row's OldStatus should be the previous row's NewStatus; when it is
+// not, the ChangeDates put the rows in the wrong order (or a row is
+// missing). A Started row followed by anything but Paused, Stopped or
+// Completed is a missing stop, and a task whose last row is Started for
+// longer than maxStarted has probably been forgotten.
+func detectAnomalies(rows []TaskHistory, now time.Time, maxStarted time.Duration) []Anomaly {
+    var found []Anomaly
+    add := func(row TaskHistory, kind, format string, args ...any) {
+        found = append(found, Anomaly{
+            TaskID:     row.TaskId,
+            Kind:       kind,
+            HistoryID:  row.ID,
+            ChangeDate: row.ChangeDate,
+            Message:    fmt.Sprintf(format, args...),
+        })
+    }
+
+    for i := 1; i < len(rows); i++ {
+        prev, row := rows[i-1], rows[i]
+
+        if row.OldStatus != nil && *row.OldStatus != "" && *row.OldStatus != prev.NewStatus {
+            add(row, AnomalyOutOfOrder, "%s row at %s has old status %s but follows %s at %s",
+                row.NewStatus, row.ChangeDate.Format(time.RFC3339), *row.OldStatus,
+                prev.NewStatus, prev.ChangeDate.Format(time.RFC3339))
+        }
+
+        switch {
+        case row.NewStatus == prev.NewStatus:
+            add(row, AnomalyDuplicateStatus, "%s recorded twice in a row (%s and %s)",
+                row.NewStatus, prev.ChangeDate.Format(time.RFC3339), row.ChangeDate.Format(time.RFC3339))
+        case prev.NewStatus == StatusStarted &&
+            row.NewStatus != StatusPaused && row.NewStatus != StatusStopped && row.NewStatus != StatusCompleted:
+            add(row, AnomalyMissingStop, "moved from Started to %s without a Paused, Stopped or Completed event",
+                row.NewStatus)
+        }
+    }
+
+    if n := len(rows); n > 0 && rows[n-1].NewStatus == StatusStarted {
+        last := rows[n-1]
+        if open := now.Sub(last.ChangeDate); open > maxStarted {
+            add(last, AnomalyLongRunning, "Started for %s, longer than %s",
+                open.Truncate(time.Minute), maxStarted)
+        }
+    }
+    return found
+}
+
+// anomalyScan is the result of one scan.
+type anomalyScan struct {
+    ScannedAt time.Time `json:"scanned_at"`
+    Anomalies []Anomaly `json:"anomalies"`
+}
+
+// anomalyDetector scans every task's history on an interval and keeps the
+// latest result for GET /api/taskHistories/anomalies.
+type anomalyDetector struct {
+    db        *gorm.DB
+    cfg       anomalyConfig
+    publisher *taskEventPublisher
+
+    scanMu sync.Mutex // serializes scans
+    mu     sync.RWMutex
+    last   *anomalyScan
+    seen   map[string]bool
+}
+
+func newAnomalyDetector(db *gorm.DB, cfg anomalyConfig, publisher *taskEventPublisher) *anomalyDetector {
+    return &anomalyDetector{db: db, cfg: cfg, publisher: publisher}
+}
+
+// Run scans until ctx is cancelled. It returns immediately when the job is
+// disabled.
+func (d *anomalyDetector) Run(ctx context.Context) {
+    if d.cfg.Interval == 0 {
+        log.Println("Anomaly scan disabled")
+        return
+    }
+    log.Printf("Anomaly scan every %s, reporting tasks Started for more than %s", d.cfg.Interval, d.cfg.MaxStarted)
+
+    ticker := time.NewTicker(d.cfg.Interval)
+    defer ticker.Stop()
+    for {
+        if _, err := d.Scan(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("Anomaly scan failed: %v", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// Scan reads all histories, stores the result and, when alerts are enabled,
+// publishes anomalies that were not present in the previous scan.
+func (d *anomalyDetector) Scan(ctx context.Context) (*anomalyScan, error) {
+    d.scanMu.Lock()
+    defer d.scanMu.Unlock()
+    return d.scan(ctx)
+}
+
+// ScanIfOlder scans unless the latest scan is younger than maxAge, which it
+// returns instead.
+func (d *anomalyDetector) ScanIfOlder(ctx context.Context, maxAge time.Duration) (*anomalyScan, error) {
+    d.scanMu.Lock()
+    defer d.scanMu.Unlock()
+    if last := d.Latest(); last != nil && time.Since(last.ScannedAt) < maxAge {
+        return last, nil
+    }
+    return d.scan(ctx)
+}
+
+// scan does the work of Scan; callers hold scanMu.
+func (d *anomalyDetector) scan(ctx context.Context) (*anomalyScan, error) {
+    now := time.Now()
+    anomalies := []Anomaly{}
+    var task []TaskHistory
+    flush := func() {
+        anomalies = append(anomalies, detectAnomalies(task, now, d.cfg.MaxStarted)...)
+        task = task[:0]
+    }
+
+    // Rows are streamed so memory grows with the longest history, not with
+    // the whole table.
+    rows, err := d.db.WithContext(ctx).Model(&TaskHistory{}).
+        Order(`"TaskId"`).Order(`"ChangeDate"`).Order(`"Id"`).
+        Rows()
+    if err != nil {
+        return nil, err
+    }
+    defer func() {
+        if err := rows.Close(); err != nil {
+            log.Println("Error closing rows:", err)
+        }
+    }()
+    for rows.Next() {
+        var row TaskHistory
+        if err := d.db.ScanRows(rows, &row); err != nil {
+            return nil, err
+        }
+        if len(task) > 0 && task[0].TaskId != row.TaskId {
+            flush()
+        }
+        task = append(task, row)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    flush()
+
+    sort.Slice(anomalies, func(i, j int) bool {
+        return anomalies[i].ChangeDate.After(anomalies[j].ChangeDate)
+    })
+    scan := &anomalyScan{ScannedAt: now, Anomalies: anomalies}
+
+    d.mu.Lock()
+    previous := d.seen
+    d.last = scan
+    d.seen = make(map[string]bool, len(anomalies))
+    for _, a := range anomalies {
+        d.seen[a.key()] = true
+    }
+    d.mu.Unlock()
+
+    if d.cfg.Alerts {
+        if unsent := d.alert(ctx, anomalies, previous, now); len(unsent) > 0 {
+            // Forget what could not be published so the next scan retries it.
+            d.mu.Lock()
+            for _, key := range unsent {
+                delete(d.seen, key)
+            }
+            d.mu.Unlock()
+        }
+    }
+    return scan, nil
+}
+
+// alert publishes anomalies that are not in previous and returns the keys of
+// those it could not publish. On the first scan after startup previous is
+// nil; earlier runs have published the anomalies that arose before the last
+// interval, so only the newer ones are published.
+func (d *anomalyDetector) alert(ctx context.Context, anomalies []Anomaly, previous map[string]bool, now time.Time) []string {
+    var unsent []string
+    published := 0
+    for _, a := range anomalies {
+        if previous[a.key()] {
+            continue
+        }
+        if previous == nil && a.arose(d.cfg.MaxStarted).Before(now.Add(-d.cfg.Interval)) {
+            continue
+        }
+        if len(unsent) == 0 {
+            if err := d.publisher.PublishJSON(ctx, anomalyQueue, a); err != nil {
+                log.Printf("Failed to publish anomaly alerts to %s: %v", anomalyQueue, err)
+            } else {
+                published++
+                continue
+            }
+        }
+        unsent = append(unsent, a.key())
+    }
+    if published > 0 {
+        log.Printf("Published %d new task anomalies to %s", published, anomalyQueue)
+    }
+    return unsent
+}
+
+// Latest returns the last scan, or nil before the first one finished.
+func (d *anomalyDetector) Latest() *anomalyScan {
+    d.mu.RLock()
+    defer d.mu.RUnlock()
+    return d.last
+}
+
+var detector *anomalyDetector
+
+// GET /api/taskHistories/anomalies?kind={kind,...}&taskId={uuid,...}&refresh=true
+//
+// Returns the result of the latest background scan. A request before the
+// first scan finished scans synchronously, as does refresh=true once the
+// latest scan is a minute old.
+func getAnomalies(c *gin.Context) {
+    var v apierror.Validation
+    kinds := map[string]bool{}
+    for _, k := range queryList(c, "kind") {
+        if !slices.Contains(anomalyKinds, k) {
+            v.Add("kind", "unknown anomaly kind: "+k)
+        }
+        kinds[k] = true
+    }
+    taskIDs := map[uuid.UUID]bool{}
+    for _, id := range parseUUIDList(c, &v, "taskId") {
+        taskIDs[id] = true
+    }
+    if apiErr := v.Err("Invalid query parameters"); apiErr != nil {
+        abortWithError(c, apiErr)
+        return
+    }
+
+    maxAge := time.Duration(math.MaxInt64)
+    if c.Query("refresh") == "true" {
+        maxAge = anomalyRefreshAge
+    }
+    scan, err := detector.ScanIfOlder(c.Request.Context(), maxAge)
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to scan task histories", err))
+        return
+    }
+
+    result := anomalyScan{ScannedAt: scan.ScannedAt, Anomalies: []Anomaly{}}
+    for _, a := range scan.Anomalies {
+        if (len(kinds) == 0 || kinds[a.Kind]) && (len(taskIDs) == 0 || taskIDs[a.TaskID]) {
+            result.Anomalies = append(result.Anomalies, a)
+        }
+    }
+
+    c.JSON(200, ApiResponse{
+        Message: "Task history anomalies retrieved successfully",
+        Data:    result,
+    })
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/minio/minio-go/v7"
+    "github.com/minio/minio-go/v7/pkg/credentials"
+
+    "go-shared/config"
+)
+
+var errArchiveNotFound = errors.New("archive object not found")
+
+// archiveStore keeps archive files and the manifest. Keys are slash
+// separated paths such as "task_histories/2024/01/<id>.ndjson.gz".
+type archiveStore interface {
+    Put(ctx context.Context, key string, body []byte, contentType string) error
+    // Get returns errArchiveNotFound when key does not exist.
+    Get(ctx context.Context, key string) ([]byte, error)
+    String() string
+}
+
+type archiveStoreConfig struct {
+    // Kind is "local" or "minio".
+    Kind string
+    Dir  string
+
+    Endpoint  string
+    AccessKey string
+    SecretKey string
+    Bucket    string
+    UseSSL    bool
+}
+
+func loadArchiveStoreConfig(l *config.Loader) archiveStoreConfig {
+    cfg := archiveStoreConfig{Kind: l.String("ARCHIVE_STORE", "local")}
+    switch cfg.Kind {
+    case "local":
+        cfg.Dir = l.String("ARCHIVE_DIR", "archive")
+    case "minio":
+        cfg.Endpoint = l.String("MINIO_ENDPOINT", "minio:9000")
+        cfg.AccessKey = l.Required("MINIO_ACCESS_KEY_ID")
+        cfg.SecretKey = l.RequiredSecret("MINIO_SECRET_ACCESS_KEY")
+        cfg.Bucket = l.String("ARCHIVE_BUCKET", "archives")
+        cfg.UseSSL = l.Bool("MINIO_USE_SSL", false)
+    default:
+        l.Invalid("ARCHIVE_STORE", "must be local or minio, got %q", cfg.Kind)
+    }
+    return cfg
+}
+
+func openArchiveStore(ctx context.Context, cfg archiveStoreConfig) (archiveStore, error) {
+    if cfg.Kind == "minio" {
+        return newMinioArchiveStore(ctx, cfg)
+    }
+    if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+        return nil, err
+    }
+    return localArchiveStore{dir: cfg.Dir}, nil
+}
+
+// localArchiveStore writes archives below a directory, e.g. a mounted volume.
+type localArchiveStore struct {
+    dir string
+}
+
+func (s localArchiveStore) path(key string) (string, error) {
+    clean := filepath.Clean(filepath.FromSlash(key))
+    if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+        return "", fmt.Errorf("invalid archive key %q", key)
+    }
+    return filepath.Join(s.dir, clean), nil
+}
+
+// Put writes to a temporary file and renames it, so a crash never leaves a
+// truncated archive or manifest behind.
+func (s localArchiveStore) Put(_ context.Context, key string, body []byte, _ string) error {
+    path, err := s.path(key)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(body); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Sync(); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+func (s localArchiveStore) Get(_ context.Context, key string) ([]byte, error) {
+    path, err := s.path(key)
+    if err != nil {
+        return nil, err
+    }
+    body, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, errArchiveNotFound
+    }
+    return body, err
+}
+
+func (s localArchiveStore) String() string {
+    return "local directory " + s.dir
+}
+
+// minioArchiveStore keeps archives in a bucket of MinIO or any other
+// S3-compatible storage.
+type minioArchiveStore struct {
+    client *minio.Client
+    bucket string
+}
+
+func newMinioArchiveStore(ctx context.Context, cfg archiveStoreConfig) (*minioArchiveStore, error) {
+    client, err := minio.New(cfg.Endpoint, &minio.Options{
+        Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+        Secure: cfg.UseSSL,
+    })
+    if err != nil {
+        return nil, err
+    }
+    exists, err := client.BucketExists(ctx, cfg.Bucket)
+    if err != nil {
+        return nil, fmt.Errorf("checking bucket %s: %w", cfg.Bucket, err)
+    }
+    if !exists {
+        // Created without a policy, so unlike the uploads bucket it is private.
+        if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{}); err != nil {
+            return nil, fmt.Errorf("creating bucket %s: %w", cfg.Bucket, err)
+        }
+    }
+    return &minioArchiveStore{client: client, bucket: cfg.Bucket}, nil
+}
+
+func (s *minioArchiveStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+    _, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(body), int64(len(body)),
+        minio.PutObjectOptions{ContentType: contentType})
+    return err
+}
+
+func (s *minioArchiveStore) Get(ctx context.Context, key string) ([]byte, error) {
+    obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+    if err != nil {
+        return nil, err
+    }
+    defer obj.Close()
+    body, err := io.ReadAll(obj)
+    if err != nil {
+        if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+            return nil, errArchiveNotFound
+        }
+        return nil, err
+    }
+    return body, nil
+}
+
+func (s *minioArchiveStore) String() string {
+    return "bucket " + s.bucket
+}
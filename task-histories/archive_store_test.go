@@ -0,0 +1,37 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "testing"
+)
+
+func TestLocalArchiveStore(t *testing.T) {
+    ctx := context.Background()
+    store := localArchiveStore{dir: t.TempDir()}
+
+    if _, err := store.Get(ctx, manifestKey); !errors.Is(err, errArchiveNotFound) {
+        t.Fatalf("Get of a missing key = %v, want errArchiveNotFound", err)
+    }
+
+    key := "task_histories/2024/01/archive.ndjson.gz"
+    if err := store.Put(ctx, key, []byte("first"), "application/gzip"); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Put(ctx, key, []byte("second"), "application/gzip"); err != nil {
+        t.Fatal(err)
+    }
+    body, err := store.Get(ctx, key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(body) != "second" {
+        t.Errorf("Get = %q, want the last write", body)
+    }
+
+    for _, bad := range []string{"../outside", "/etc/passwd", "a/../../outside"} {
+        if err := store.Put(ctx, bad, nil, ""); err == nil {
+            t.Errorf("Put(%q) escaped the archive directory", bad)
+        }
+    }
+}
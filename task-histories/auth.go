@@ -0,0 +1,91 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "slices"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "github.com/golang-jwt/jwt/v5"
+
+    "go-shared/apierror"
+    "go-shared/config"
+)
+
+// roleClaim is where the AuthService puts the user's role.
+const roleClaim = "http://schemas.microsoft.com/ws/2008/06/identity/claims/role"
+
+// jwtConfig validates the tokens the AuthService issues, for the endpoints
+// that clients reach without going through the gateway.
+type jwtConfig struct {
+    Secret   string
+    Issuer   string
+    Audience string
+}
+
+func loadJWTConfig(l *config.Loader) jwtConfig {
+    return jwtConfig{
+        Secret:   l.RequiredSecret("JWT_TOKEN"),
+        Issuer:   l.Required("JWT_ISSUER"),
+        Audience: l.Required("JWT_AUDIENCE"),
+    }
+}
+
+// roles returns the roles of a valid token.
+func (cfg jwtConfig) roles(tokenString string) ([]string, error) {
+    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+        return []byte(cfg.Secret), nil
+    },
+        jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+        jwt.WithIssuer(cfg.Issuer),
+        jwt.WithAudience(cfg.Audience),
+    )
+    if err != nil {
+        return nil, err
+    }
+    claims, ok := token.Claims.(jwt.MapClaims)
+    if !ok {
+        return nil, errors.New("unexpected claims")
+    }
+    // A user with several roles has them as an array.
+    switch role := claims[roleClaim].(type) {
+    case string:
+        return []string{role}, nil
+    case []any:
+        var roles []string
+        for _, r := range role {
+            if s, ok := r.(string); ok {
+                roles = append(roles, s)
+            }
+        }
+        return roles, nil
+    }
+    return nil, fmt.Errorf("no %s claim", roleClaim)
+}
+
+// requireRole lets a request through when it carries a valid token of one
+// of roles, as a bearer Authorization header or, for EventSource which
+// cannot set headers, an access_token parameter.
+func requireRole(cfg jwtConfig, roles ...string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+        if !ok {
+            tokenString = c.Query("access_token")
+        }
+        if tokenString == "" {
+            abortWithError(c, apierror.Unauthenticated("A bearer token is required"))
+            return
+        }
+        have, err := cfg.roles(tokenString)
+        if err != nil {
+            abortWithError(c, apierror.Unauthenticated("Invalid token"))
+            return
+        }
+        if !slices.ContainsFunc(have, func(r string) bool { return slices.Contains(roles, r) }) {
+            abortWithError(c, apierror.Forbidden("Requires the "+strings.Join(roles, " or ")+" role"))
+            return
+        }
+        c.Next()
+    }
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := jwtConfig{Secret: "secret", Issuer: "auth", Audience: "api"}
+	sign := func(secret string, claims jwt.MapClaims) string {
+		claims["iss"], claims["aud"] = "auth", "api"
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	r := gin.New()
+	r.GET("/stream", requireRole(cfg, streamRoles...), func(c *gin.Context) { c.Status(200) })
+
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   int
+	}{
+		{"no token", "", "", 401},
+		{"manager header", "Bearer " + sign("secret", jwt.MapClaims{roleClaim: "manager"}), "", 200},
+		{"admin query", "", sign("secret", jwt.MapClaims{roleClaim: []any{"developer", "admin"}}), 200},
+		{"auditor header", "Bearer " + sign("secret", jwt.MapClaims{roleClaim: "auditor"}), "", 200},
+		{"developer", "Bearer " + sign("secret", jwt.MapClaims{roleClaim: "developer"}), "", 403},
+		{"wrong secret", "Bearer " + sign("other", jwt.MapClaims{roleClaim: "manager"}), "", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stream?access_token="+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "go-shared/apierror"
+)
+
+// exportFlushEvery is how many rows are written between flushes.
+const exportFlushEvery = 1000
+
+// exportFormat picks the output format from ?format= or the Accept header.
+func exportFormat(c *gin.Context) (string, bool) {
+    switch format := strings.ToLower(c.Query("format")); format {
+    case "ndjson", "csv":
+        return format, true
+    case "":
+    default:
+        return "", false
+    }
+    if strings.Contains(c.GetHeader("Accept"), "text/csv") {
+        return "csv", true
+    }
+    return "ndjson", true
+}
+
+// GET /api/taskHistories/export?format=ndjson|csv&taskId=&teamId=&oldStatus=&newStatus=&from=&to=&sort=asc|desc&cursor=
+//
+// Streams every matching row with the same filters as GET /api/taskHistories.
+// Rows are read from a database cursor and written as they arrive, so memory
+// does not grow with the table. The output can be fed back to
+// POST /api/taskHistories/import. limit is ignored.
+//
+// Once the first row is written the status is already 200; a later failure
+// ends the body early and is only logged.
+func exportHistories(c *gin.Context) {
+    format, ok := exportFormat(c)
+    q, apiErr := parseHistoryQuery(c)
+    if !ok {
+        details := []apierror.Detail{{Field: "format", Issue: "use ndjson or csv"}}
+        if apiErr != nil {
+            details = append(details, apiErr.Details...)
+        }
+        apiErr = apierror.InvalidArgument("Invalid query parameters", details...)
+    }
+    if apiErr != nil {
+        abortWithError(c, apiErr)
+        return
+    }
+
+    tx := q.order(q.filter(db.WithContext(c.Request.Context()).Model(&TaskHistory{})))
+    if q.After != nil {
+        tx = q.After.after(tx, q.Descending)
+    }
+    rows, err := tx.Rows()
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to export task histories", err))
+        return
+    }
+    defer func() {
+        if err := rows.Close(); err != nil {
+            log.Println("Error closing rows:", err)
+        }
+    }()
+
+    filename := "task_histories_" + time.Now().UTC().Format("20060102T150405Z")
+    if format == "csv" {
+        c.Header("Content-Type", "text/csv; charset=utf-8")
+        c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+    } else {
+        c.Header("Content-Type", "application/x-ndjson")
+        c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ndjson"`, filename))
+    }
+    c.Status(200)
+
+    var write func(TaskHistory) error
+    var flush func() error
+    if format == "csv" {
+        w := csv.NewWriter(c.Writer)
+        if err := w.Write(importColumns); err != nil {
+            c.Error(err)
+            return
+        }
+        write = func(h TaskHistory) error {
+            oldStatus := ""
+            if h.OldStatus != nil {
+                oldStatus = *h.OldStatus
+            }
+            return w.Write([]string{
+                h.ID.String(),
+                h.TaskId.String(),
+                h.ChangeDate.UTC().Format(time.RFC3339Nano),
+                oldStatus,
+                h.NewStatus,
+            })
+        }
+        flush = func() error {
+            w.Flush()
+            c.Writer.Flush()
+            return w.Error()
+        }
+    } else {
+        enc := json.NewEncoder(c.Writer)
+        write = func(h TaskHistory) error {
+            return enc.Encode(h)
+        }
+        flush = func() error {
+            c.Writer.Flush()
+            return nil
+        }
+    }
+
+    written := 0
+    for rows.Next() {
+        var h TaskHistory
+        if err := db.ScanRows(rows, &h); err != nil {
+            log.Printf("History export failed after %d rows: %v", written, err)
+            return
+        }
+        if err := write(h); err != nil {
+            // Usually the client went away.
+            log.Printf("History export aborted after %d rows: %v", written, err)
+            return
+        }
+        written++
+        if written%exportFlushEvery == 0 {
+            if err := flush(); err != nil {
+                log.Printf("History export aborted after %d rows: %v", written, err)
+                return
+            }
+        }
+    }
+    if err := rows.Err(); err != nil {
+        log.Printf("History export failed after %d rows: %v", written, err)
+    }
+    if err := flush(); err != nil {
+        log.Printf("History export aborted after %d rows: %v", written, err)
+    }
+}
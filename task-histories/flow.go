@@ -0,0 +1,382 @@
+package main
+
+import (
+    "math"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+
+    "go-shared/apierror"
+)
+
+// TaskFlow holds the flow metrics of one task. Lead time runs from the first
+// history row (normally Created) to completion, cycle time from the first
+// Started row to completion; both are nil for tasks that are not completed.
+// TimeInStatus attributes the time between consecutive rows to the earlier
+// row's status, up to completion or, for open tasks, up to now.
+type TaskFlow struct {
+    TaskID        uuid.UUID          `json:"task_id"`
+    TaskName      string             `json:"task_name"`
+    SprintID      *uuid.UUID         `json:"sprint_id"`
+    SprintName    *string            `json:"sprint_name"`
+    TeamID        *uuid.UUID         `json:"team_id"`
+    TeamName      *string            `json:"team_name"`
+    DeveloperID   *uuid.UUID         `json:"developer_id"`
+    DeveloperName *string            `json:"developer_name"`
+    Status        string             `json:"status"`
+    CreatedAt     time.Time          `json:"created_at"`
+    FirstStart    *time.Time         `json:"first_start"`
+    CompletedAt   *time.Time         `json:"completed_at"`
+    LeadTime      *float64           `json:"lead_time_seconds"`
+    CycleTime     *float64           `json:"cycle_time_seconds"`
+    TimeInStatus  map[string]float64 `json:"time_in_status_seconds"`
+}
+
+// FlowStats summarizes a set of durations in seconds.
+type FlowStats struct {
+    Count int     `json:"count"`
+    Mean  float64 `json:"mean_seconds"`
+    P50   float64 `json:"p50_seconds"`
+    P85   float64 `json:"p85_seconds"`
+    P95   float64 `json:"p95_seconds"`
+}
+
+// FlowGroup aggregates the tasks of one sprint, team or developer, or of the
+// whole result for the overall group.
+type FlowGroup struct {
+    ID             *uuid.UUID           `json:"id,omitempty"`
+    Name           *string              `json:"name,omitempty"`
+    TaskCount      int                  `json:"task_count"`
+    CompletedCount int                  `json:"completed_count"`
+    LeadTime       FlowStats            `json:"lead_time"`
+    CycleTime      FlowStats            `json:"cycle_time"`
+    TimeInStatus   map[string]FlowStats `json:"time_in_status"`
+}
+
+// FlowReport is the response of GET /api/taskHistories/flow.
+type FlowReport struct {
+    GroupBy string      `json:"group_by,omitempty"`
+    Overall FlowGroup   `json:"overall"`
+    Groups  []FlowGroup `json:"groups,omitempty"`
+    Tasks   []TaskFlow  `json:"tasks,omitempty"`
+}
+
+// computeTaskFlow fills the metrics of task from its history in ChangeDate
+// order. rows must not be empty.
+func computeTaskFlow(task TaskFlow, rows []TaskHistory, now time.Time) TaskFlow {
+    timeline := buildTimeline(task.TaskID, rows, now)
+    task.Status = timeline.Status
+    task.CreatedAt = rows[0].ChangeDate
+    task.FirstStart = timeline.FirstStart
+    task.CompletedAt = timeline.CompletedAt
+
+    end := now
+    if task.CompletedAt != nil {
+        end = *task.CompletedAt
+        lead := task.CompletedAt.Sub(task.CreatedAt).Seconds()
+        task.LeadTime = &lead
+        if task.FirstStart != nil {
+            cycle := task.CompletedAt.Sub(*task.FirstStart).Seconds()
+            task.CycleTime = &cycle
+        }
+    }
+
+    task.TimeInStatus = map[string]float64{}
+    for i, row := range rows {
+        next := end
+        if i+1 < len(rows) && rows[i+1].ChangeDate.Before(end) {
+            next = rows[i+1].ChangeDate
+        }
+        if row.ChangeDate.Before(next) {
+            task.TimeInStatus[row.NewStatus] += next.Sub(row.ChangeDate).Seconds()
+        }
+    }
+    return task
+}
+
+// percentile returns the p-th percentile (0-100) of sorted values, using
+// linear interpolation between closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+    if len(sorted) == 0 {
+        return 0
+    }
+    rank := p / 100 * float64(len(sorted)-1)
+    lower := int(math.Floor(rank))
+    upper := int(math.Ceil(rank))
+    return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func flowStats(values []float64) FlowStats {
+    if len(values) == 0 {
+        return FlowStats{}
+    }
+    sorted := append([]float64(nil), values...)
+    sort.Float64s(sorted)
+    sum := 0.0
+    for _, v := range sorted {
+        sum += v
+    }
+    return FlowStats{
+        Count: len(sorted),
+        Mean:  sum / float64(len(sorted)),
+        P50:   percentile(sorted, 50),
+        P85:   percentile(sorted, 85),
+        P95:   percentile(sorted, 95),
+    }
+}
+
+func aggregateFlow(tasks []TaskFlow) FlowGroup {
+    var lead, cycle []float64
+    inStatus := map[string][]float64{}
+    g := FlowGroup{TaskCount: len(tasks)}
+    for _, t := range tasks {
+        if t.CompletedAt != nil {
+            g.CompletedCount++
+        }
+        if t.LeadTime != nil {
+            lead = append(lead, *t.LeadTime)
+        }
+        if t.CycleTime != nil {
+            cycle = append(cycle, *t.CycleTime)
+        }
+        for status, seconds := range t.TimeInStatus {
+            inStatus[status] = append(inStatus[status], seconds)
+        }
+    }
+    g.LeadTime = flowStats(lead)
+    g.CycleTime = flowStats(cycle)
+    g.TimeInStatus = make(map[string]FlowStats, len(inStatus))
+    for status, values := range inStatus {
+        g.TimeInStatus[status] = flowStats(values)
+    }
+    return g
+}
+
+// groupFlow aggregates tasks per sprint, team or developer. Tasks without
+// the grouping attribute form a group with a nil ID.
+func groupFlow(tasks []TaskFlow, groupBy string) []FlowGroup {
+    type group struct {
+        id    *uuid.UUID
+        name  *string
+        tasks []TaskFlow
+    }
+    var order []uuid.UUID
+    groups := map[uuid.UUID]*group{}
+    for _, t := range tasks {
+        var id *uuid.UUID
+        var name *string
+        switch groupBy {
+        case "sprint":
+            id, name = t.SprintID, t.SprintName
+        case "team":
+            id, name = t.TeamID, t.TeamName
+        case "developer":
+            id, name = t.DeveloperID, t.DeveloperName
+        }
+        key := uuid.Nil
+        if id != nil {
+            key = *id
+        }
+        g, ok := groups[key]
+        if !ok {
+            g = &group{id: id, name: name}
+            groups[key] = g
+            order = append(order, key)
+        }
+        g.tasks = append(g.tasks, t)
+    }
+
+    result := make([]FlowGroup, 0, len(order))
+    for _, key := range order {
+        g := groups[key]
+        fg := aggregateFlow(g.tasks)
+        fg.ID, fg.Name = g.id, g.name
+        result = append(result, fg)
+    }
+    sort.SliceStable(result, func(i, j int) bool {
+        a, b := result[i].Name, result[j].Name
+        if a == nil || b == nil {
+            return b == nil && a != nil
+        }
+        return *a < *b
+    })
+    return result
+}
+
+type flowQuery struct {
+    SprintIDs    []uuid.UUID
+    TeamIDs      []uuid.UUID
+    DeveloperIDs []uuid.UUID
+    From, To     *time.Time
+    GroupBy      string
+    IncludeOpen  bool
+    IncludeTasks bool
+}
+
+func parseFlowQuery(c *gin.Context) (flowQuery, *apierror.Error) {
+    var v apierror.Validation
+    q := flowQuery{
+        SprintIDs:    parseUUIDList(c, &v, "sprintId"),
+        TeamIDs:      parseUUIDList(c, &v, "teamId"),
+        DeveloperIDs: parseUUIDList(c, &v, "developerId"),
+        IncludeOpen:  c.Query("includeOpen") == "true",
+        IncludeTasks: c.DefaultQuery("includeTasks", "true") == "true",
+    }
+
+    if from := c.Query("from"); from != "" {
+        t, err := parseTimeParam(from, false)
+        if err != nil {
+            v.Add("from", "use RFC 3339 or YYYY-MM-DD")
+        }
+        q.From = t
+    }
+    if to := c.Query("to"); to != "" {
+        t, err := parseTimeParam(to, true)
+        if err != nil {
+            v.Add("to", "use RFC 3339 or YYYY-MM-DD")
+        }
+        q.To = t
+    }
+    if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+        v.Add("from", "must be before to")
+    }
+
+    switch groupBy := strings.ToLower(c.Query("groupBy")); groupBy {
+    case "", "sprint", "team", "developer":
+        q.GroupBy = groupBy
+    default:
+        v.Add("groupBy", "use sprint, team or developer")
+    }
+    return q, v.Err("Invalid query parameters")
+}
+
+// loadFlowTasks returns the metrics of the matching tasks: those completed
+// within the range and, with IncludeOpen, those not completed yet.
+func loadFlowTasks(tx *gorm.DB, q flowQuery, now time.Time) ([]TaskFlow, error) {
+    type row struct {
+        ID            uuid.UUID
+        TaskID        uuid.UUID
+        ChangeDate    time.Time
+        NewStatus     string
+        TaskName      string
+        SprintID      *uuid.UUID
+        SprintName    *string
+        TeamID        *uuid.UUID
+        TeamName      *string
+        DeveloperID   *uuid.UUID
+        DeveloperName *string
+    }
+
+    query := tx.Table(`"TaskHistories" AS th`).
+        Select(`th."Id" AS id, th."TaskId" AS task_id, th."ChangeDate" AS change_date, th."NewStatus" AS new_status,
+            t."Name" AS task_name, t."SprintId" AS sprint_id, s."Name" AS sprint_name,
+            s."TeamId" AS team_id, tm."Name" AS team_name,
+            t."DeveloperId" AS developer_id, u."Username" AS developer_name`).
+        Joins(`JOIN "Tasks" t ON t."Id" = th."TaskId"`).
+        Joins(`LEFT JOIN "Sprints" s ON s."Id" = t."SprintId"`).
+        Joins(`LEFT JOIN "Teams" tm ON tm."Id" = s."TeamId"`).
+        Joins(`LEFT JOIN "Users" u ON u."Id" = t."DeveloperId"`)
+    if len(q.SprintIDs) > 0 {
+        query = query.Where(`t."SprintId" IN ?`, q.SprintIDs)
+    }
+    if len(q.TeamIDs) > 0 {
+        query = query.Where(`s."TeamId" IN ?`, q.TeamIDs)
+    }
+    if len(q.DeveloperIDs) > 0 {
+        query = query.Where(`t."DeveloperId" IN ?`, q.DeveloperIDs)
+    }
+    if !q.IncludeOpen {
+        completed := tx.Table(`"TaskHistories"`).Select(`"TaskId"`).Where(`"NewStatus" = ?`, StatusCompleted)
+        if q.From != nil {
+            completed = completed.Where(`"ChangeDate" >= ?`, *q.From)
+        }
+        if q.To != nil {
+            completed = completed.Where(`"ChangeDate" < ?`, *q.To)
+        }
+        query = query.Where(`th."TaskId" IN (?)`, completed)
+    }
+
+    var rows []row
+    if err := query.Order(`th."TaskId"`).Order(`th."ChangeDate"`).Order(`th."Id"`).Scan(&rows).Error; err != nil {
+        return nil, err
+    }
+
+    var tasks []TaskFlow
+    var current TaskFlow
+    var history []TaskHistory
+    flush := func() {
+        if len(history) == 0 {
+            return
+        }
+        task := computeTaskFlow(current, history, now)
+        history = history[:0]
+
+        // The SQL filter only narrows the tasks down; a task completed in
+        // range and then reopened, or completed again later, is decided on
+        // its final completion here.
+        if task.CompletedAt == nil {
+            if q.IncludeOpen {
+                tasks = append(tasks, task)
+            }
+            return
+        }
+        if (q.From == nil || !task.CompletedAt.Before(*q.From)) && (q.To == nil || task.CompletedAt.Before(*q.To)) {
+            tasks = append(tasks, task)
+        }
+    }
+    for _, r := range rows {
+        if len(history) == 0 || current.TaskID != r.TaskID {
+            flush()
+            current = TaskFlow{
+                TaskID:        r.TaskID,
+                TaskName:      r.TaskName,
+                SprintID:      r.SprintID,
+                SprintName:    r.SprintName,
+                TeamID:        r.TeamID,
+                TeamName:      r.TeamName,
+                DeveloperID:   r.DeveloperID,
+                DeveloperName: r.DeveloperName,
+            }
+        }
+        history = append(history, TaskHistory{ID: r.ID, TaskId: r.TaskID, ChangeDate: r.ChangeDate, NewStatus: r.NewStatus})
+    }
+    flush()
+    return tasks, nil
+}
+
+// GET /api/taskHistories/flow?sprintId=&teamId=&developerId=&from=&to=&groupBy=sprint|team|developer&includeOpen=false&includeTasks=true
+//
+// Lead time, cycle time and time in status per task, with mean, p50, p85
+// and p95 overall and per group. from and to select tasks by completion
+// date; includeOpen=true adds tasks that are not completed, which count
+// towards time in status only.
+func getFlowMetrics(c *gin.Context) {
+    q, apiErr := parseFlowQuery(c)
+    if apiErr != nil {
+        abortWithError(c, apiErr)
+        return
+    }
+
+    tasks, err := loadFlowTasks(db.WithContext(c.Request.Context()), q, time.Now())
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to retrieve task histories", err))
+        return
+    }
+
+    report := FlowReport{GroupBy: q.GroupBy, Overall: aggregateFlow(tasks)}
+    if q.GroupBy != "" {
+        report.Groups = groupFlow(tasks, q.GroupBy)
+    }
+    if q.IncludeTasks {
+        report.Tasks = tasks
+    }
+
+    c.JSON(200, ApiResponse{
+        Message: "Flow metrics retrieved successfully",
+        Data:    report,
+    })
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+    "math"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+func TestPercentile(t *testing.T) {
+    values := []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+    tests := []struct {
+        p    float64
+        want float64
+    }{
+        {0, 10},
+        {50, 55},
+        {85, 86.5},
+        {95, 95.5},
+        {100, 100},
+    }
+    for _, tt := range tests {
+        if got := percentile(values, tt.p); math.Abs(got-tt.want) > 1e-9 {
+            t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+        }
+    }
+    if got := percentile([]float64{42}, 95); got != 42 {
+        t.Errorf("percentile of one value = %v, want 42", got)
+    }
+}
+
+func TestComputeTaskFlow(t *testing.T) {
+    taskID := uuid.New()
+    rows := history(taskID,
+        0, StatusCreated, 60, StatusAssigned,
+        120, StatusStarted, 180, StatusPaused,
+        240, StatusStarted, 300, StatusCompleted)
+
+    got := computeTaskFlow(TaskFlow{TaskID: taskID}, rows, at(1000))
+
+    if got.LeadTime == nil || *got.LeadTime != 300*60 {
+        t.Errorf("LeadTime = %v, want %v", got.LeadTime, 300*60)
+    }
+    if got.CycleTime == nil || *got.CycleTime != 180*60 {
+        t.Errorf("CycleTime = %v, want %v", got.CycleTime, 180*60)
+    }
+    want := map[string]float64{
+        StatusCreated:  3600,
+        StatusAssigned: 3600,
+        StatusStarted:  7200,
+        StatusPaused:   3600,
+    }
+    if len(got.TimeInStatus) != len(want) {
+        t.Fatalf("TimeInStatus = %v, want %v", got.TimeInStatus, want)
+    }
+    for status, seconds := range want {
+        if got.TimeInStatus[status] != seconds {
+            t.Errorf("TimeInStatus[%s] = %v, want %v", status, got.TimeInStatus[status], seconds)
+        }
+    }
+
+    open := computeTaskFlow(TaskFlow{TaskID: taskID}, rows[:3], at(150))
+    if open.LeadTime != nil || open.CycleTime != nil {
+        t.Errorf("open task has lead %v, cycle %v", open.LeadTime, open.CycleTime)
+    }
+    if open.TimeInStatus[StatusStarted] != float64(30*time.Minute/time.Second) {
+        t.Errorf("open TimeInStatus[Started] = %v, want 1800", open.TimeInStatus[StatusStarted])
+    }
+}
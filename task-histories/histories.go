@@ -0,0 +1,312 @@
+package main
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+
+    "go-shared/apierror"
+    "go-shared/httpcache"
+)
+
+const (
+    defaultHistoryPageSize = 50
+    maxHistoryPageSize     = 500
+)
+
+// PageMeta is returned in ApiResponse.Meta by list endpoints. NextCursor is
+// null on the last page.
+type PageMeta struct {
+    NextCursor *string `json:"nextCursor"`
+    TotalCount int64   `json:"totalCount"`
+    Limit      int     `json:"limit,omitempty"`
+}
+
+// historyCursor is the keyset position of the last row of a page. Rows are
+// ordered by ChangeDate and then Id, so the pair is unique and stable.
+type historyCursor struct {
+    ChangeDate time.Time
+    ID         uuid.UUID
+}
+
+func (c historyCursor) encode() string {
+    raw := c.ChangeDate.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+    return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(s string) (*historyCursor, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil {
+        return nil, err
+    }
+    datePart, idPart, ok := strings.Cut(string(raw), "|")
+    if !ok {
+        return nil, errors.New("missing separator")
+    }
+    changeDate, err := time.Parse(time.RFC3339Nano, datePart)
+    if err != nil {
+        return nil, err
+    }
+    id, err := uuid.Parse(idPart)
+    if err != nil {
+        return nil, err
+    }
+    return &historyCursor{ChangeDate: changeDate, ID: id}, nil
+}
+
+// after keeps the rows that come after c in the given order.
+func (c historyCursor) after(tx *gorm.DB, descending bool) *gorm.DB {
+    op := ">"
+    if descending {
+        op = "<"
+    }
+    return tx.Where(`("ChangeDate", "Id") `+op+` (?, ?)`, c.ChangeDate, c.ID)
+}
+
+// historyQuery holds the filters accepted by GET /api/taskHistories.
+type historyQuery struct {
+    TaskIDs     []uuid.UUID
+    TeamIDs     []uuid.UUID
+    OldStatuses []string
+    NewStatuses []string
+    From        *time.Time
+    To          *time.Time
+    Descending  bool
+
+    // Limit is zero when the caller asked for neither limit nor cursor; the
+    // whole result is returned then, as before pagination existed.
+    Limit int
+    After *historyCursor
+}
+
+// queryList collects a repeatable, comma separated query parameter:
+// ?taskId=a&taskId=b and ?taskId=a,b are the same.
+func queryList(c *gin.Context, key string) []string {
+    var values []string
+    for _, raw := range c.QueryArray(key) {
+        for _, v := range strings.Split(raw, ",") {
+            if v = strings.TrimSpace(v); v != "" {
+                values = append(values, v)
+            }
+        }
+    }
+    return values
+}
+
+// parseTimeParam accepts RFC 3339 timestamps and plain dates. A plain date
+// used as the end of a range covers that whole day.
+func parseTimeParam(value string, endOfRange bool) (*time.Time, error) {
+    if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+        return &t, nil
+    }
+    t, err := time.Parse("2006-01-02", value)
+    if err != nil {
+        return nil, err
+    }
+    if endOfRange {
+        t = t.AddDate(0, 0, 1)
+    }
+    return &t, nil
+}
+
+// parseHistoryFilters reads the task, team and status filters shared by the
+// list and stream endpoints.
+func parseHistoryFilters(c *gin.Context, v *apierror.Validation) historyQuery {
+    q := historyQuery{
+        OldStatuses: queryList(c, "oldStatus"),
+        NewStatuses: queryList(c, "newStatus"),
+    }
+    q.TaskIDs = append(parseUUIDList(c, v, "taskId"), parseUUIDList(c, v, "taskIds")...)
+    q.TeamIDs = parseUUIDList(c, v, "teamId")
+    return q
+}
+
+// parseHistoryQuery reports every invalid parameter at once.
+func parseHistoryQuery(c *gin.Context) (historyQuery, *apierror.Error) {
+    var v apierror.Validation
+    q := parseHistoryFilters(c, &v)
+
+    if from := c.Query("from"); from != "" {
+        t, err := parseTimeParam(from, false)
+        if err != nil {
+            v.Add("from", "use RFC 3339 or YYYY-MM-DD")
+        }
+        q.From = t
+    }
+    if to := c.Query("to"); to != "" {
+        t, err := parseTimeParam(to, true)
+        if err != nil {
+            v.Add("to", "use RFC 3339 or YYYY-MM-DD")
+        }
+        q.To = t
+    }
+    if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+        v.Add("from", "must be before to")
+    }
+
+    switch strings.ToLower(c.DefaultQuery("sort", "asc")) {
+    case "asc":
+    case "desc":
+        q.Descending = true
+    default:
+        v.Add("sort", "use asc or desc")
+    }
+
+    limitStr, hasLimit := c.GetQuery("limit")
+    cursor, hasCursor := c.GetQuery("cursor")
+    if hasLimit || hasCursor {
+        q.Limit = defaultHistoryPageSize
+    }
+    if hasLimit {
+        limit, err := strconv.Atoi(limitStr)
+        if err != nil || limit < 1 || limit > maxHistoryPageSize {
+            v.Add("limit", fmt.Sprintf("use a number between 1 and %d", maxHistoryPageSize))
+        } else {
+            q.Limit = limit
+        }
+    }
+    if hasCursor {
+        after, err := decodeHistoryCursor(cursor)
+        if err != nil {
+            v.Add("cursor", "not a cursor returned by this endpoint")
+        }
+        q.After = after
+    }
+    return q, v.Err("Invalid query parameters")
+}
+
+// statusFilter matches column against values, where "null" stands for a
+// missing status (OldStatus of a task's first history row), stored as NULL
+// or as an empty string.
+func statusFilter(tx *gorm.DB, column string, values []string) *gorm.DB {
+    if len(values) == 0 {
+        return tx
+    }
+    var named []string
+    matchNull := false
+    for _, v := range values {
+        if strings.EqualFold(v, "null") {
+            matchNull = true
+        } else {
+            named = append(named, v)
+        }
+    }
+    switch {
+    case matchNull && len(named) > 0:
+        return tx.Where(`("`+column+`" IN ? OR "`+column+`" IS NULL OR "`+column+`" = '')`, named)
+    case matchNull:
+        return tx.Where(`("` + column + `" IS NULL OR "` + column + `" = '')`)
+    default:
+        return tx.Where(`"`+column+`" IN ?`, named)
+    }
+}
+
+// filter applies everything except the cursor, so it can also be used for
+// totalCount.
+func (q historyQuery) filter(tx *gorm.DB) *gorm.DB {
+    if len(q.TaskIDs) > 0 {
+        tx = tx.Where(`"TaskId" IN ?`, q.TaskIDs)
+    }
+    if len(q.TeamIDs) > 0 {
+        tx = tx.Where(`"TaskId" IN (SELECT t."Id" FROM "Tasks" t JOIN "Sprints" s ON s."Id" = t."SprintId" WHERE s."TeamId" IN ?)`, q.TeamIDs)
+    }
+    tx = statusFilter(tx, "OldStatus", q.OldStatuses)
+    tx = statusFilter(tx, "NewStatus", q.NewStatuses)
+    if q.From != nil {
+        tx = tx.Where(`"ChangeDate" >= ?`, *q.From)
+    }
+    if q.To != nil {
+        tx = tx.Where(`"ChangeDate" < ?`, *q.To)
+    }
+    return tx
+}
+
+func (q historyQuery) order(tx *gorm.DB) *gorm.DB {
+    if q.Descending {
+        return tx.Order(`"ChangeDate" DESC`).Order(`"Id" DESC`)
+    }
+    return tx.Order(`"ChangeDate" ASC`).Order(`"Id" ASC`)
+}
+
+// GET /api/taskHistories?taskId={uuid,...}&teamId={uuid,...}&oldStatus=&newStatus=&from=&to=&sort=asc|desc&limit=&cursor=
+//
+// Without limit or cursor every matching row is returned, which is what the
+// API gateway relies on. With either, at most limit rows are returned and
+// Meta.NextCursor continues after the last one.
+//
+// The ETag is derived from the query, the number of matching rows and their
+// newest ChangeDate, which is also sent as Last-Modified. Both are known
+// before the rows are read, so a conditional request that matches is
+// answered with 304 without loading them. Histories are never updated, only
+// inserted and archived, and either changes the count. Rows imported with an
+// old ChangeDate do not move Last-Modified, so clients should prefer
+// If-None-Match.
+func getHistories(c *gin.Context) {
+    q, apiErr := parseHistoryQuery(c)
+    if apiErr != nil {
+        abortWithError(c, apiErr)
+        return
+    }
+
+    histories := func() *gorm.DB {
+        return db.WithContext(c.Request.Context()).Model(&TaskHistory{})
+    }
+    meta := PageMeta{Limit: q.Limit}
+
+    var version struct {
+        Count  int64
+        Newest *time.Time
+    }
+    err := q.filter(histories()).
+        Select(`COUNT(*) AS count, MAX("ChangeDate") AS newest`).
+        Scan(&version).Error
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to retrieve task histories", err))
+        return
+    }
+    var lastModified time.Time
+    if version.Newest != nil {
+        lastModified = *version.Newest
+    }
+    etag := httpcache.ETag("taskHistories", c.Request.URL.RawQuery, version.Count, lastModified)
+    if httpcache.NotModified(c.Writer, c.Request, etag, lastModified) {
+        return
+    }
+    meta.TotalCount = version.Count
+
+    page := q.order(q.filter(histories()))
+    if q.After != nil {
+        page = q.After.after(page, q.Descending)
+    }
+    if q.Limit > 0 {
+        page = page.Limit(q.Limit + 1)
+    }
+
+    rows := []TaskHistory{}
+    if err := page.Find(&rows).Error; err != nil {
+        abortWithError(c, apierror.Internal("Failed to retrieve task histories", err))
+        return
+    }
+
+    if q.Limit == 0 {
+        meta.TotalCount = int64(len(rows))
+    } else if len(rows) > q.Limit {
+        rows = rows[:q.Limit]
+        last := rows[len(rows)-1]
+        next := historyCursor{ChangeDate: last.ChangeDate, ID: last.ID}.encode()
+        meta.NextCursor = &next
+    }
+
+    c.JSON(200, ApiResponse{
+        Message: "Task histories retrieved successfully",
+        Data:    rows,
+        Meta:    meta,
+    })
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "go-shared/apierror"
+)
+
+const (
+    importBatchSize     = 500
+    maxImportErrors     = 1000
+    maxImportLineLength = 1 << 20
+)
+
+// importRecord is one line of an import, in either format. The field names
+// match the JSON of TaskHistory so an export can be imported again.
+type importRecord struct {
+    ID         string  `json:"id"`
+    TaskID     string  `json:"task_id"`
+    ChangeDate string  `json:"change_date"`
+    OldStatus  *string `json:"old_status"`
+    NewStatus  string  `json:"new_status"`
+}
+
+// ImportLineError reports why one line was not imported.
+type ImportLineError struct {
+    Line    int    `json:"line"`
+    TaskID  string `json:"task_id,omitempty"`
+    Message string `json:"message"`
+}
+
+// ImportResult is the response of POST /api/taskHistories/import.
+type ImportResult struct {
+    DryRun   bool              `json:"dry_run"`
+    Lines    int               `json:"lines"`
+    Imported int               `json:"imported"`
+    Skipped  int               `json:"skipped"`
+    Failed   int               `json:"failed"`
+    Errors   []ImportLineError `json:"errors"`
+    // ErrorsTruncated is set when more lines failed than are listed.
+    ErrorsTruncated bool `json:"errors_truncated,omitempty"`
+}
+
+func (r *ImportResult) fail(line int, taskID, format string, args ...any) {
+    r.Failed++
+    if len(r.Errors) == maxImportErrors {
+        r.ErrorsTruncated = true
+        return
+    }
+    r.Errors = append(r.Errors, ImportLineError{Line: line, TaskID: taskID, Message: fmt.Sprintf(format, args...)})
+}
+
+// recordReader yields records with their line numbers until io.EOF. A
+// malformed line is returned as a *lineError and reading continues.
+type recordReader interface {
+    Next() (importRecord, int, error)
+}
+
+type lineError struct {
+    line int
+    err  error
+}
+
+func (e *lineError) Error() string {
+    return e.err.Error()
+}
+
+type ndjsonReader struct {
+    r    *bufio.Reader
+    buf  []byte
+    line int
+}
+
+// readLine returns the next line, or reports it as too long without
+// keeping more than maxImportLineLength bytes of it.
+func (n *ndjsonReader) readLine() ([]byte, bool, error) {
+    n.buf = n.buf[:0]
+    tooLong := false
+    for {
+        chunk, err := n.r.ReadSlice('\n')
+        if !tooLong {
+            if len(n.buf)+len(chunk) > maxImportLineLength {
+                tooLong, n.buf = true, n.buf[:0]
+            } else {
+                n.buf = append(n.buf, chunk...)
+            }
+        }
+        if err == bufio.ErrBufferFull {
+            continue
+        }
+        return n.buf, tooLong, err
+    }
+}
+
+func (n *ndjsonReader) Next() (importRecord, int, error) {
+    for {
+        raw, tooLong, err := n.readLine()
+        if len(raw) == 0 && !tooLong && err != nil {
+            return importRecord{}, n.line, err
+        }
+        n.line++
+        if tooLong {
+            return importRecord{}, n.line, &lineError{n.line, fmt.Errorf("line longer than %d bytes", maxImportLineLength)}
+        }
+        raw = bytes.TrimSpace(raw)
+        if len(raw) == 0 {
+            continue
+        }
+        var rec importRecord
+        if err := json.Unmarshal(raw, &rec); err != nil {
+            return importRecord{}, n.line, &lineError{n.line, fmt.Errorf("invalid JSON: %v", err)}
+        }
+        return rec, n.line, nil
+    }
+}
+
+type csvRecordReader struct {
+    r       *csv.Reader
+    columns map[string]int
+}
+
+var importColumns = []string{"id", "task_id", "change_date", "old_status", "new_status"}
+
+func newCSVRecordReader(body io.Reader) (*csvRecordReader, error) {
+    r := csv.NewReader(body)
+    r.FieldsPerRecord = -1
+    r.ReuseRecord = true
+    header, err := r.Read()
+    if err != nil {
+        return nil, fmt.Errorf("missing header row: %v", err)
+    }
+    columns := map[string]int{}
+    for i, name := range header {
+        columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+    }
+    for _, required := range []string{"task_id", "change_date", "new_status"} {
+        if _, ok := columns[required]; !ok {
+            return nil, fmt.Errorf("header has no %s column", required)
+        }
+    }
+    return &csvRecordReader{r: r, columns: columns}, nil
+}
+
+func (c *csvRecordReader) Next() (importRecord, int, error) {
+    fields, err := c.r.Read()
+    line, _ := c.r.FieldPos(0)
+    if err != nil {
+        var parseErr *csv.ParseError
+        if errors.As(err, &parseErr) {
+            return importRecord{}, parseErr.Line, &lineError{parseErr.Line, err}
+        }
+        return importRecord{}, line, err
+    }
+    get := func(name string) string {
+        if i, ok := c.columns[name]; ok && i < len(fields) {
+            return strings.TrimSpace(fields[i])
+        }
+        return ""
+    }
+    rec := importRecord{
+        ID:         get("id"),
+        TaskID:     get("task_id"),
+        ChangeDate: get("change_date"),
+        NewStatus:  get("new_status"),
+    }
+    if old := get("old_status"); old != "" {
+        rec.OldStatus = &old
+    }
+    return rec, line, nil
+}
+
+// importTaskState is where a task stands while its lines are imported.
+type importTaskState struct {
+    exists     bool
+    status     string
+    changeDate time.Time
+}
+
+// check returns why a row cannot follow state, or "" when it can.
+func (state *importTaskState) check(changeDate time.Time, oldStatus *string, newStatus string) string {
+    if state.status != "" && !changeDate.After(state.changeDate) {
+        return fmt.Sprintf("change_date %s is not after the task's previous change at %s",
+            changeDate.Format(time.RFC3339), state.changeDate.Format(time.RFC3339))
+    }
+    if oldStatus != nil && *oldStatus != "" && !strings.EqualFold(*oldStatus, state.status) {
+        return fmt.Sprintf("old_status %s does not match the task's current status %s",
+            *oldStatus, statusOrNone(state.status))
+    }
+    if !canTransition(state.status, newStatus) {
+        return fmt.Sprintf("cannot move from %s to %s; allowed: %s",
+            statusOrNone(state.status), newStatus, allowedList(state.status))
+    }
+    return ""
+}
+
+// advance moves state to row and returns row with OldStatus set to where
+// the task was, an empty string for its first row like the seeded history.
+func (state *importTaskState) advance(row TaskHistory) TaskHistory {
+    old := state.status
+    row.OldStatus = &old
+    state.status, state.changeDate = row.NewStatus, row.ChangeDate
+    return row
+}
+
+// importRow is a validated line waiting in the batch.
+type importRow struct {
+    line int
+    // oldStatus is the old_status of the line, checked again on replay.
+    oldStatus *string
+    row       TaskHistory
+}
+
+// errImportConflict rolls back a batch in which some rows were already
+// stored.
+var errImportConflict = errors.New("batch contains stored rows")
+
+// importer validates records against the state machine and, unless dry
+// running, inserts them in batches, each in its own transaction. A task's
+// state moves on as its lines are queued. When a batch meets rows that are
+// already stored it is rolled back and replayed row by row, so that only
+// rows actually inserted move their task.
+type importer struct {
+    db     *gorm.DB
+    dryRun bool
+    result ImportResult
+    tasks  map[uuid.UUID]*importTaskState
+    batch  []importRow
+    // before holds the states of the tasks in batch from before it.
+    before map[uuid.UUID]importTaskState
+}
+
+func (im *importer) taskState(ctx context.Context, taskID uuid.UUID) (*importTaskState, error) {
+    if state, ok := im.tasks[taskID]; ok {
+        return state, nil
+    }
+    state := &importTaskState{}
+    if err := im.db.WithContext(ctx).Raw(`SELECT EXISTS (SELECT 1 FROM "Tasks" WHERE "Id" = ?)`, taskID).Scan(&state.exists).Error; err != nil {
+        return nil, err
+    }
+    if state.exists {
+        var last TaskHistory
+        res := im.db.WithContext(ctx).Where(`"TaskId" = ?`, taskID).
+            Order(`"ChangeDate" DESC`).Order(`"Id" DESC`).
+            Limit(1).Find(&last)
+        if res.Error != nil {
+            return nil, res.Error
+        }
+        if res.RowsAffected > 0 {
+            state.status, state.changeDate = last.NewStatus, last.ChangeDate
+        }
+    }
+    im.tasks[taskID] = state
+    return state, nil
+}
+
+// run reads every record, importing or only validating them.
+func (im *importer) run(ctx context.Context, reader recordReader) error {
+    for {
+        rec, line, err := reader.Next()
+        var badLine *lineError
+        switch {
+        case errors.Is(err, io.EOF):
+            return im.flush(ctx)
+        case errors.As(err, &badLine):
+            im.result.Lines++
+            im.result.fail(badLine.line, "", "%v", badLine.err)
+            continue
+        case err != nil:
+            return err
+        }
+        im.result.Lines++
+        if err := im.add(ctx, rec, line); err != nil {
+            return err
+        }
+    }
+}
+
+// add validates one record. It returns an error only for database failures;
+// invalid records are counted in the result.
+func (im *importer) add(ctx context.Context, rec importRecord, line int) error {
+    taskID, err := uuid.Parse(rec.TaskID)
+    if err != nil {
+        im.result.fail(line, rec.TaskID, "task_id is not a valid UUID")
+        return nil
+    }
+    id := uuid.New()
+    if rec.ID != "" {
+        if id, err = uuid.Parse(rec.ID); err != nil {
+            im.result.fail(line, rec.TaskID, "id is not a valid UUID")
+            return nil
+        }
+    }
+    changeDate, err := time.Parse(time.RFC3339Nano, rec.ChangeDate)
+    if err != nil {
+        im.result.fail(line, rec.TaskID, "change_date must be an RFC 3339 timestamp")
+        return nil
+    }
+    newStatus, ok := canonicalStatus(rec.NewStatus)
+    if !ok {
+        im.result.fail(line, rec.TaskID, "new_status must be one of %s", strings.Join(knownStatuses, ", "))
+        return nil
+    }
+
+    state, err := im.taskState(ctx, taskID)
+    if err != nil {
+        return err
+    }
+    if !state.exists {
+        im.result.fail(line, rec.TaskID, "task does not exist")
+        return nil
+    }
+    if state.status != "" && !changeDate.After(state.changeDate) && rec.ID != "" {
+        // Re-importing an export meets rows that are already stored.
+        var exists bool
+        if err := im.db.WithContext(ctx).Raw(`SELECT EXISTS (SELECT 1 FROM "TaskHistories" WHERE "Id" = ?)`, id).Scan(&exists).Error; err != nil {
+            return err
+        }
+        if exists {
+            im.result.Skipped++
+            return nil
+        }
+    }
+    if msg := state.check(changeDate, rec.OldStatus, newStatus); msg != "" {
+        im.result.fail(line, rec.TaskID, "%s", msg)
+        return nil
+    }
+
+    if im.dryRun {
+        state.advance(TaskHistory{ChangeDate: changeDate, NewStatus: newStatus})
+        im.result.Imported++
+        return nil
+    }
+    if _, ok := im.before[taskID]; !ok {
+        im.before[taskID] = *state
+    }
+    row := state.advance(TaskHistory{ID: id, TaskId: taskID, ChangeDate: changeDate, NewStatus: newStatus})
+    im.batch = append(im.batch, importRow{line: line, oldStatus: rec.OldStatus, row: row})
+    if len(im.batch) >= importBatchSize {
+        return im.flush(ctx)
+    }
+    return nil
+}
+
+// flush inserts the pending batch in one transaction together with the new
+// status of its tasks.
+func (im *importer) flush(ctx context.Context) error {
+    if len(im.batch) == 0 {
+        return nil
+    }
+    rows := make([]TaskHistory, len(im.batch))
+    for i, r := range im.batch {
+        rows[i] = r.row
+    }
+    err := im.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+        if res.Error != nil {
+            return res.Error
+        }
+        if int(res.RowsAffected) < len(rows) {
+            return errImportConflict
+        }
+        return im.setTaskStatuses(tx)
+    })
+    switch {
+    case errors.Is(err, errImportConflict):
+        err = im.replay(ctx)
+    case err == nil:
+        im.result.Imported += len(rows)
+    }
+    im.batch = im.batch[:0]
+    clear(im.before)
+    return err
+}
+
+// replay inserts the batch row by row. Rows whose id is already stored are
+// skipped without moving their task, and the rows after them are checked
+// again against where the task really is.
+func (im *importer) replay(ctx context.Context) error {
+    for taskID, state := range im.before {
+        *im.tasks[taskID] = state
+    }
+    return im.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        for _, r := range im.batch {
+            state := im.tasks[r.row.TaskId]
+            if msg := state.check(r.row.ChangeDate, r.oldStatus, r.row.NewStatus); msg != "" {
+                im.result.fail(r.line, r.row.TaskId.String(), "%s", msg)
+                continue
+            }
+            moved := *state
+            row := moved.advance(r.row)
+            res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+            if res.Error != nil {
+                return res.Error
+            }
+            if res.RowsAffected == 0 {
+                im.result.Skipped++
+                continue
+            }
+            *state = moved
+            im.result.Imported++
+        }
+        return im.setTaskStatuses(tx)
+    })
+}
+
+// setTaskStatuses sets "Tasks"."TaskStatusId" to the last imported status of
+// every task the batch moved.
+func (im *importer) setTaskStatuses(tx *gorm.DB) error {
+    for taskID, before := range im.before {
+        state := im.tasks[taskID]
+        if state.status == before.status && state.changeDate.Equal(before.changeDate) {
+            continue
+        }
+        err := tx.Exec(`UPDATE "Tasks" SET "TaskStatusId" = (SELECT "Id" FROM "TaskStatuses" WHERE "Name" = ?)
+            WHERE "Id" = ? AND EXISTS (SELECT 1 FROM "TaskStatuses" WHERE "Name" = ?)`,
+            state.status, taskID, state.status).Error
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func statusOrNone(status string) string {
+    if status == "" {
+        return "no history"
+    }
+    return status
+}
+
+// importFormat picks the input format from ?format= or the Content-Type.
+func importFormat(c *gin.Context) (string, bool) {
+    switch format := strings.ToLower(c.Query("format")); format {
+    case "ndjson", "csv":
+        return format, true
+    case "":
+    default:
+        return "", false
+    }
+    if strings.Contains(c.ContentType(), "csv") {
+        return "csv", true
+    }
+    return "ndjson", true
+}
+
+// POST /api/taskHistories/import?format=ndjson|csv&dryRun=true
+//
+// Reads the body line by line: NDJSON objects or CSV with a header row, both
+// with the fields task_id, change_date (RFC 3339), new_status and optional
+// id and old_status. Lines of a task must be in ChangeDate order and after
+// its existing history; each is checked against the status state machine.
+// Valid lines are imported and invalid ones reported with their line number.
+// Rows are written in transactions of 500, so when the import fails part way
+// the batches before stay imported; importing the file again skips the rows
+// with an id and reports the others as not after the task's history.
+// dryRun=true only validates. Requires an admin token.
+func importHistories(c *gin.Context) {
+    format, ok := importFormat(c)
+    if !ok {
+        abortWithError(c, apierror.InvalidArgument("Invalid query parameters",
+            apierror.Field("format", "use ndjson or csv")))
+        return
+    }
+    dryRun := c.Query("dryRun") == "true"
+
+    var reader recordReader
+    if format == "csv" {
+        r, err := newCSVRecordReader(c.Request.Body)
+        if err != nil {
+            abortWithError(c, apierror.InvalidArgument("Invalid CSV", apierror.Field("body", err.Error())))
+            return
+        }
+        reader = r
+    } else {
+        reader = &ndjsonReader{r: bufio.NewReaderSize(c.Request.Body, 64*1024)}
+    }
+
+    im := &importer{
+        db:     db,
+        dryRun: dryRun,
+        result: ImportResult{DryRun: dryRun, Errors: []ImportLineError{}},
+        tasks:  map[uuid.UUID]*importTaskState{},
+        before: map[uuid.UUID]importTaskState{},
+    }
+    err := im.run(c.Request.Context(), reader)
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to import task histories", err))
+        return
+    }
+
+    message := "Task histories imported"
+    if dryRun {
+        message = "Task histories validated, nothing was imported"
+    }
+    c.JSON(200, ApiResponse{Message: message, Data: im.result})
+}
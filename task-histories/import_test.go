@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAll drains r and returns the records and the lines that failed.
+func readAll(t *testing.T, r recordReader) ([]importRecord, []int, []int) {
+	t.Helper()
+	var records []importRecord
+	var lines, bad []int
+	for {
+		rec, line, err := r.Next()
+		var badLine *lineError
+		switch {
+		case errors.Is(err, io.EOF):
+			return records, lines, bad
+		case errors.As(err, &badLine):
+			bad = append(bad, badLine.line)
+			continue
+		case err != nil:
+			t.Fatalf("unexpected error: %v", err)
+		}
+		records = append(records, rec)
+		lines = append(lines, line)
+	}
+}
+
+func TestNDJSONReader(t *testing.T) {
+	body := `{"task_id":"a","change_date":"2025-01-06T09:00:00Z","new_status":"Created"}
+
+not json
+{"task_id":"a","change_date":"2025-01-06T10:00:00Z","old_status":"Created","new_status":"Assigned"}`
+
+	records, lines, bad := readAll(t, &ndjsonReader{r: bufio.NewReader(strings.NewReader(body))})
+	if len(records) != 2 || lines[0] != 1 || lines[1] != 4 {
+		t.Fatalf("got %d records on lines %v, want lines [1 4]", len(records), lines)
+	}
+	if len(bad) != 1 || bad[0] != 3 {
+		t.Errorf("bad lines = %v, want [3]", bad)
+	}
+	if records[0].OldStatus != nil || records[1].OldStatus == nil || *records[1].OldStatus != "Created" {
+		t.Errorf("old statuses not read correctly: %+v", records)
+	}
+}
+
+func TestNDJSONReaderLongLine(t *testing.T) {
+	body := strings.Repeat("x", 3*maxImportLineLength) + "\n" +
+		`{"task_id":"a","change_date":"2025-01-06T09:00:00Z","new_status":"Created"}`
+
+	n := &ndjsonReader{r: bufio.NewReaderSize(strings.NewReader(body), 4096)}
+	records, lines, bad := readAll(t, n)
+	if len(bad) != 1 || bad[0] != 1 {
+		t.Errorf("bad lines = %v, want [1]", bad)
+	}
+	if len(records) != 1 || lines[0] != 2 {
+		t.Fatalf("got %d records on lines %v, want one on line 2", len(records), lines)
+	}
+	// append may round the buffer up, but never to the whole line.
+	if cap(n.buf) >= 2*maxImportLineLength {
+		t.Errorf("kept %d bytes for a line over the limit", cap(n.buf))
+	}
+}
+
+func TestCSVRecordReader(t *testing.T) {
+	body := "\ufeffTask_Id,new_status,change_date\n" +
+		"a,Created,2025-01-06T09:00:00Z\n" +
+		"a,\"Assigned,2025-01-06T10:00:00Z\n"
+
+	r, err := newCSVRecordReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, lines, bad := readAll(t, r)
+	if len(records) != 1 || lines[0] != 2 {
+		t.Fatalf("got %d records on lines %v, want one on line 2", len(records), lines)
+	}
+	if rec := records[0]; rec.TaskID != "a" || rec.NewStatus != "Created" || rec.OldStatus != nil {
+		t.Errorf("record = %+v", rec)
+	}
+	if len(bad) != 1 {
+		t.Errorf("bad lines = %v, want the unterminated quote reported", bad)
+	}
+
+	if _, err := newCSVRecordReader(strings.NewReader("task_id,new_status\n")); err == nil {
+		t.Error("header without change_date accepted")
+	}
+}
+
+func TestImportTaskStateCheck(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2025, 1, 6, hour, 0, 0, 0, time.UTC) }
+	created := StatusCreated
+
+	tests := []struct {
+		name      string
+		state     importTaskState
+		at        time.Time
+		oldStatus *string
+		newStatus string
+		ok        bool
+	}{
+		{"first row", importTaskState{}, at(9), nil, StatusCreated, true},
+		{"first row must be Created", importTaskState{}, at(9), nil, StatusStarted, false},
+		{"next status", importTaskState{status: StatusCreated, changeDate: at(9)}, at(10), &created, StatusAssigned, true},
+		{"not after", importTaskState{status: StatusCreated, changeDate: at(9)}, at(9), nil, StatusAssigned, false},
+		{"old status mismatch", importTaskState{status: StatusAssigned, changeDate: at(9)}, at(10), &created, StatusStarted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.state.check(tt.at, tt.oldStatus, tt.newStatus)
+			if (msg == "") != tt.ok {
+				t.Errorf("check = %q, want ok %v", msg, tt.ok)
+			}
+		})
+	}
+}
+
+func TestImportTaskStateAdvance(t *testing.T) {
+	var state importTaskState
+	first := state.advance(TaskHistory{NewStatus: StatusCreated, ChangeDate: time.Unix(0, 0)})
+	second := state.advance(TaskHistory{NewStatus: StatusAssigned, ChangeDate: time.Unix(60, 0)})
+	if first.OldStatus == nil || *first.OldStatus != "" {
+		t.Errorf("first row old status = %v, want empty", first.OldStatus)
+	}
+	if second.OldStatus == nil || *second.OldStatus != StatusCreated {
+		t.Errorf("second row old status = %v, want Created", second.OldStatus)
+	}
+	if state.status != StatusAssigned || !state.changeDate.Equal(time.Unix(60, 0)) {
+		t.Errorf("state = %+v", state)
+	}
+}
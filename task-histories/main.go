@@ -1,26 +1,44 @@
 ﻿package main
 
 import (
-    "fmt"
+    "context"
+    "errors"
     "log"
+    "net/http"
     "os"
-    "strings"
+    "os/signal"
+    "sync"
+    "syscall"
     "time"
 
     "github.com/gin-gonic/gin"
     "github.com/google/uuid"
-    _ "github.com/jackc/pgx/v5/stdlib"
-    "gorm.io/driver/postgres"
+    "go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
     "gorm.io/gorm"
-    "gorm.io/gorm/logger"
     "gorm.io/gorm/schema"
+
+    "go-shared/apierror"
+    "go-shared/config"
+    "go-shared/database"
+    "go-shared/health"
+    "go-shared/rabbitmq"
+    "go-shared/telemetry"
 )
 
-var db *gorm.DB
+var (
+    db        *gorm.DB
+    publisher *taskEventPublisher
+)
 
 type ApiResponse struct {
     Message string `json:"message"`
     Data    any    `json:"data,omitempty"`
+    Meta    any    `json:"meta,omitempty"`
+}
+
+// abortWithError sends the shared error envelope with the error's status.
+func abortWithError(c *gin.Context, err *apierror.Error) {
+    c.AbortWithStatusJSON(err.Status, err.Envelope())
 }
 
 type TaskHistory struct {
@@ -35,121 +53,158 @@ func (TaskHistory) TableName() string {
     return "TaskHistories"
 }
 
-func checkDatabaseConnection() bool {
-    sqlDB, err := db.DB()
-    if err != nil {
-        return false
+func main() {
+    if len(os.Args) > 1 && os.Args[1] == "retention" {
+        os.Exit(retentionCommand(os.Args[2:]))
     }
-    return sqlDB.Ping() == nil
-}
 
-func healthCheck(c *gin.Context) {
-    dbOK := checkDatabaseConnection()
-
-    if !dbOK {
-        c.JSON(503, ApiResponse{
-            Message: "Service unavailable",
-            Data: gin.H{
-                "status": "unhealthy",
-                "database": map[string]string{
-                    "status": func() string {
-                        if dbOK {
-                            return "connected"
-                        }
-                        return "disconnected"
-                    }(),
-                },
-            },
-        })
-        return
+    loader := config.NewLoader()
+    dbConfig := database.LoadConfig(loader)
+    telemetryConfig := telemetry.LoadConfig(loader, "task-histories")
+    rabbitConfig := rabbitmq.LoadConfig(loader)
+    anomalyConfig := loadAnomalyConfig(loader)
+    streamSettings = loadStreamConfig(loader)
+    jwtSettings := loadJWTConfig(loader)
+    retentionConfig := loadRetentionConfig(loader)
+    archiveConfig := loadArchiveStoreConfig(loader)
+    port := loader.String("PORT", "8080")
+    shutdownTimeout := loader.Duration("SHUTDOWN_TIMEOUT", 15*time.Second)
+    checker := health.FromConfig(loader)
+
+    loader.Report(log.Printf)
+    if err := loader.Validate(); err != nil {
+        log.Fatal("Invalid configuration: ", err)
     }
 
-    c.JSON(200, ApiResponse{
-        Message: "Service healthy",
-        Data: gin.H{
-            "status":   "healthy",
-            "database": map[string]string{"status": "connected"},
-        },
-    })
-}
+    startupCtx := context.Background()
 
-func initDatabase() {
-    host := getEnv("DB_HOST", "db")
-    port := getEnv("DB_PORT", "5432")
-    user := getEnv("DB_USER", "postgres")
-    password := getEnv("DB_PASSWORD", "password")
-    dbname := getEnv("DB_DATABASE", "project")
-
-    dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-        host, user, password, dbname, port)
-
-    var err error
+    shutdownTracing, err := telemetry.Init(startupCtx, telemetryConfig)
+    if err != nil {
+        log.Fatal("Failed to initialize tracing:", err)
+    }
 
-    db, err = gorm.Open(postgres.New(postgres.Config{
-        DriverName: "pgx",
-        DSN:        dsn,
-    }), &gorm.Config{
-        Logger: logger.Default.LogMode(logger.Info),
+    db, err = database.Open(startupCtx, dbConfig, &gorm.Config{
         NamingStrategy: schema.NamingStrategy{
             SingularTable: true,
         },
     })
-
     if err != nil {
         log.Fatal("Failed to connect to database:", err)
     }
 
-    log.Println("Connected to database successfully")
-}
+    checker.AddWithDetails("postgres", database.HealthCheck(db), database.Stats(db))
 
-func getEnv(key, defaultValue string) string {
-    if value := os.Getenv(key); value != "" {
-        return value
+    publisher, err = newTaskEventPublisher(startupCtx, rabbitConfig)
+    if err != nil {
+        log.Fatal("Failed to connect to RabbitMQ:", err)
     }
-    return defaultValue
-}
-
-func getHistories(c *gin.Context) {
-    taskId := c.Query("taskId")
-    taskIdIsPresent := strings.TrimSpace(taskId) != ""
+    checker.Add("rabbitmq", publisher.HealthCheck())
 
-    var histories []TaskHistory
-    var result *gorm.DB
-
-    if taskIdIsPresent {
-        result = db.Where("\"TaskId\" = ?", taskId).Find(&histories)
-    } else {
-        result = db.Find(&histories)
+    if anomalyConfig.Alerts {
+        if err := publisher.DeclareQueue(anomalyQueue); err != nil {
+            log.Fatal("Failed to declare anomaly queue:", err)
+        }
     }
+    detector = newAnomalyDetector(db, anomalyConfig, publisher)
 
-    if result.Error != nil {
-        c.JSON(500, ApiResponse{
-            Message: "Failed to retrieve task histories",
-            Data:    result.Error.Error(),
-        })
-        return
+    archives, err := openArchiveStore(startupCtx, archiveConfig)
+    if err != nil {
+        log.Fatal("Failed to open archive store:", err)
     }
-    c.JSON(200, ApiResponse{
-        Message: "Task histories retrieved successfully",
-        Data:    histories,
-    })
-}
+    retention = newRetentionManager(db, archives, retentionConfig)
 
-func main() {
-    initDatabase()
     r := gin.Default()
+    r.Use(otelgin.Middleware(telemetryConfig.ServiceName))
 
-    r.GET("/health", healthCheck)
+    r.GET("/livez", livenessHandler(checker))
+    r.GET("/readyz", readinessHandler(checker))
+    r.GET("/health", readinessHandler(checker))
     api := r.Group("/api")
     {
         api.GET("/taskHistories", getHistories)
+        api.POST("/taskHistories", requireRole(jwtSettings, "admin", "manager"), createHistory)
+        api.GET("/taskHistories/timesheet", getTimesheet)
+        api.GET("/taskHistories/anomalies", getAnomalies)
+        api.GET("/taskHistories/stream", requireRole(jwtSettings, streamRoles...), streamHistories)
+        api.GET("/taskHistories/flow", getFlowMetrics)
+        api.GET("/taskHistories/export", exportHistories)
+        api.POST("/taskHistories/import", requireRole(jwtSettings, "admin"), importHistories)
+        api.GET("/taskHistories/retention", getRetention)
+        api.GET("/taskHistories/:taskId/timeline", getTimeline)
     }
 
-    port := getEnv("PORT", "8080")
-    log.Printf("Reports service starting on port %s", port)
-    err := r.Run(":" + port)
-    if err != nil {
+    server := &http.Server{
+        Addr:    ":" + port,
+        Handler: r,
+    }
+    // Open event streams never finish on their own; end them so Shutdown
+    // does not wait for its whole timeout.
+    server.RegisterOnShutdown(func() { close(streamsClosing) })
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Background jobs get their own context so they are stopped only after
+    // the HTTP server has drained.
+    jobsCtx, stopJobs := context.WithCancel(context.Background())
+    var jobs sync.WaitGroup
+    jobs.Add(2)
+    go func() {
+        defer jobs.Done()
+        detector.Run(jobsCtx)
+    }()
+    go func() {
+        defer jobs.Done()
+        retention.Run(jobsCtx)
+    }()
+
+    serverErr := make(chan error, 1)
+    go func() {
+        log.Printf("Task histories service starting on port %s", port)
+        if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            serverErr <- err
+        }
+    }()
+
+    select {
+    case <-ctx.Done():
+        log.Printf("Shutdown signal received, draining (timeout %s)", shutdownTimeout)
+    case err := <-serverErr:
         log.Fatal("Failed to start server:", err)
-        return
     }
+    stop()
+    checker.MarkShuttingDown()
+
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+    defer cancel()
+
+    if err := server.Shutdown(shutdownCtx); err != nil {
+        log.Println("Error shutting down HTTP server:", err)
+    }
+
+    stopJobs()
+    jobsDone := make(chan struct{})
+    go func() {
+        jobs.Wait()
+        close(jobsDone)
+    }()
+    select {
+    case <-jobsDone:
+    case <-shutdownCtx.Done():
+        log.Println("Timed out waiting for background jobs:", shutdownCtx.Err())
+    }
+
+    if err := publisher.Close(); err != nil {
+        log.Println("Error closing RabbitMQ connection:", err)
+    }
+
+    if err := database.Close(db); err != nil {
+        log.Println("Error closing database pool:", err)
+    }
+
+    if err := shutdownTracing(shutdownCtx); err != nil {
+        log.Println("Error shutting down tracer provider:", err)
+    }
+
+    log.Println("Task histories service stopped")
 }
@@ -0,0 +1,254 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "slices"
+    "strings"
+    "sync"
+
+    "github.com/google/uuid"
+    amqp "github.com/rabbitmq/amqp091-go"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/trace"
+
+    "go-shared/health"
+    "go-shared/rabbitmq"
+    "go-shared/telemetry"
+)
+
+const taskQueue = "task_queue"
+
+// maxPendingEvents bounds the events held while the publisher reconnects.
+const maxPendingEvents = 1000
+
+// taskEventPublisher sends status changes to task_queue in the format the
+// FastAPI service uses, so goservice handles both the same way. When the
+// broker closes the connection or channel it reconnects in the background,
+// holding the events published meanwhile and sending them once it is back.
+type taskEventPublisher struct {
+    cfg    rabbitmq.Config
+    ctx    context.Context
+    cancel context.CancelFunc
+
+    mu         sync.Mutex
+    conn       *amqp.Connection
+    ch         *amqp.Channel
+    connClosed chan *amqp.Error
+    chClosed   chan *amqp.Error
+    queues     []string
+    pending    []pendingEvent
+}
+
+type pendingEvent struct {
+    queue string
+    msg   amqp.Publishing
+}
+
+func newTaskEventPublisher(ctx context.Context, cfg rabbitmq.Config) (*taskEventPublisher, error) {
+    p := &taskEventPublisher{cfg: cfg, queues: []string{taskQueue}}
+    if err := p.connect(ctx); err != nil {
+        return nil, err
+    }
+    p.ctx, p.cancel = context.WithCancel(context.Background())
+    go p.watch()
+    return p, nil
+}
+
+// connect dials the broker, opens a channel and declares the queues.
+func (p *taskEventPublisher) connect(ctx context.Context) error {
+    conn, err := rabbitmq.Dial(ctx, p.cfg)
+    if err != nil {
+        return err
+    }
+    ch, err := conn.Channel()
+    if err != nil {
+        _ = conn.Close()
+        return fmt.Errorf("open channel: %w", err)
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    for _, queue := range p.queues {
+        if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+            _ = conn.Close()
+            return fmt.Errorf("declare %s: %w", queue, err)
+        }
+    }
+    p.conn, p.ch = conn, ch
+    p.connClosed = conn.NotifyClose(make(chan *amqp.Error, 1))
+    p.chClosed = ch.NotifyClose(make(chan *amqp.Error, 1))
+    // Before anything published from now on, to keep the order.
+    p.sendPending()
+    return nil
+}
+
+// watch reconnects whenever the connection or channel closes, until Close.
+func (p *taskEventPublisher) watch() {
+    for {
+        p.mu.Lock()
+        connClosed, chClosed := p.connClosed, p.chClosed
+        p.mu.Unlock()
+
+        var reason *amqp.Error
+        select {
+        case <-p.ctx.Done():
+            return
+        case reason = <-connClosed:
+        case reason = <-chClosed:
+        }
+        if p.ctx.Err() != nil {
+            return
+        }
+        log.Printf("RabbitMQ publisher connection lost (%v), reconnecting", reason)
+
+        p.mu.Lock()
+        // The connection may still be open when only the channel closed.
+        _ = p.conn.Close()
+        p.conn, p.ch = nil, nil
+        p.mu.Unlock()
+
+        for {
+            err := p.connect(p.ctx)
+            if err == nil {
+                break
+            }
+            if p.ctx.Err() != nil {
+                return
+            }
+            log.Println("RabbitMQ publisher reconnect failed:", err)
+        }
+        log.Println("RabbitMQ publisher reconnected")
+    }
+}
+
+// hold keeps msg for after the reconnect. Callers hold p.mu.
+func (p *taskEventPublisher) hold(queue string, msg amqp.Publishing) error {
+    if len(p.pending) >= maxPendingEvents {
+        return fmt.Errorf("not connected to RabbitMQ and %d events are already waiting", len(p.pending))
+    }
+    p.pending = append(p.pending, pendingEvent{queue: queue, msg: msg})
+    return nil
+}
+
+// sendPending publishes the held events in order, keeping the rest when one
+// fails so the next reconnect sends them. Callers hold p.mu.
+func (p *taskEventPublisher) sendPending() {
+    for i, event := range p.pending {
+        if err := p.ch.PublishWithContext(context.Background(), "", event.queue, false, false, event.msg); err != nil {
+            log.Printf("Failed to send %d held events: %v", len(p.pending)-i, err)
+            p.pending = p.pending[i:]
+            return
+        }
+    }
+    if len(p.pending) > 0 {
+        log.Printf("Sent %d events held while reconnecting", len(p.pending))
+    }
+    p.pending = nil
+}
+
+type taskEventMessage struct {
+    Action string `json:"action"`
+    TaskID string `json:"task_id"`
+}
+
+// Publish sends a task_<status> event for taskID to task_queue.
+func (p *taskEventPublisher) Publish(ctx context.Context, taskID uuid.UUID, newStatus string) error {
+    return p.PublishJSON(ctx, taskQueue, taskEventMessage{
+        Action: "task_" + strings.ToLower(newStatus),
+        TaskID: taskID.String(),
+    })
+}
+
+// PublishJSON sends v as a persistent JSON message to queue with the trace
+// context of ctx in the message headers. While the publisher reconnects the
+// message is held and sent after the reconnect.
+func (p *taskEventPublisher) PublishJSON(ctx context.Context, queue string, v any) (err error) {
+    ctx, span := otel.Tracer("task-histories").Start(ctx, queue+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+        trace.WithAttributes(
+            attribute.String("messaging.system", "rabbitmq"),
+            attribute.String("messaging.destination.name", queue),
+        ))
+    defer func() {
+        if err != nil {
+            telemetry.RecordError(span, err)
+        }
+        span.End()
+    }()
+
+    body, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+
+    msg := amqp.Publishing{
+        ContentType:  "application/json",
+        DeliveryMode: amqp.Persistent,
+        Headers:      rabbitmq.InjectTraceContext(ctx, nil),
+        Body:         body,
+    }
+
+    // A channel must not be used for concurrent publishes.
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.ch == nil || p.ch.IsClosed() {
+        return p.hold(queue, msg)
+    }
+    if err := p.ch.PublishWithContext(ctx, "", queue, false, false, msg); err != nil {
+        if p.ch.IsClosed() {
+            return p.hold(queue, msg)
+        }
+        return err
+    }
+    return nil
+}
+
+// DeclareQueue declares a durable queue to publish to, again after every
+// reconnect.
+func (p *taskEventPublisher) DeclareQueue(name string) error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if !slices.Contains(p.queues, name) {
+        p.queues = append(p.queues, name)
+    }
+    if p.ch == nil {
+        return nil
+    }
+    if _, err := p.ch.QueueDeclare(name, true, false, false, false, nil); err != nil {
+        return fmt.Errorf("declare %s: %w", name, err)
+    }
+    return nil
+}
+
+// HealthCheck reports the state of the publishing connection, unhealthy
+// while it reconnects.
+func (p *taskEventPublisher) HealthCheck() health.Check {
+    return func(ctx context.Context) error {
+        p.mu.Lock()
+        conn, ch := p.conn, p.ch
+        p.mu.Unlock()
+        return rabbitmq.HealthCheck(conn, ch)(ctx)
+    }
+}
+
+// Close stops reconnecting and closes the connection. Events still held are
+// dropped.
+func (p *taskEventPublisher) Close() error {
+    p.cancel()
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if len(p.pending) > 0 {
+        log.Printf("Dropping %d events held while reconnecting", len(p.pending))
+    }
+    if p.conn == nil {
+        return nil
+    }
+    if err := p.ch.Close(); err != nil {
+        _ = p.conn.Close()
+        return err
+    }
+    return p.conn.Close()
+}
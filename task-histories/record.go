@@ -0,0 +1,209 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "go-shared/apierror"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyNamespace is the UUIDv5 namespace for rows written with an
+// Idempotency-Key. Changing it would make retries of old requests create new
+// rows.
+var idempotencyNamespace = uuid.MustParse("8d5f7c1e-3b2a-4e6f-9a0d-1c4b7e2f5a93")
+
+// newHistoryRequest is the body of POST /api/taskHistories.
+type newHistoryRequest struct {
+    TaskID    string `json:"task_id"`
+    NewStatus string `json:"new_status"`
+}
+
+// historyID is the Id of the row a request writes. With an idempotency key
+// it is derived from the task and the key, so a retried request finds the
+// row it wrote the first time.
+func historyID(taskID uuid.UUID, idempotencyKey string) uuid.UUID {
+    if idempotencyKey == "" {
+        return uuid.New()
+    }
+    return uuid.NewSHA1(idempotencyNamespace, []byte(taskID.String()+"|"+idempotencyKey))
+}
+
+// recordTransition appends a history row moving the task to newStatus and
+// updates "Tasks"."TaskStatusId" in the same transaction. replayed is true
+// when the row already existed, in which case nothing is written. Rule
+// violations are returned as *apierror.Error.
+func recordTransition(ctx context.Context, taskID uuid.UUID, newStatus string, id uuid.UUID) (row TaskHistory, replayed bool, err error) {
+    err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        // Locking the task serializes writers for the same task, so the
+        // latest row read below cannot change before the insert.
+        var task struct {
+            Status string
+        }
+        res := tx.Raw(`SELECT ts."Name" AS status
+            FROM "Tasks" t JOIN "TaskStatuses" ts ON ts."Id" = t."TaskStatusId"
+            WHERE t."Id" = ? FOR UPDATE OF t`, taskID).Scan(&task)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            return apierror.NotFound("Task not found")
+        }
+
+        var existing TaskHistory
+        res = tx.Where(`"Id" = ?`, id).Limit(1).Find(&existing)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected > 0 {
+            if existing.TaskId != taskID || existing.NewStatus != newStatus {
+                return apierror.Conflict("Idempotency-Key was already used for a different request")
+            }
+            row, replayed = existing, true
+            return nil
+        }
+
+        var last TaskHistory
+        res = tx.Where(`"TaskId" = ?`, taskID).
+            Order(`"ChangeDate" DESC`).Order(`"Id" DESC`).
+            Limit(1).Find(&last)
+        if res.Error != nil {
+            return res.Error
+        }
+        hasHistory := res.RowsAffected > 0
+
+        // Without history the task's own status is the starting point,
+        // except for the initial Created row of a task still in Created,
+        // whose OldStatus is stored as '' like the seeded rows.
+        current := task.Status
+        switch {
+        case hasHistory:
+            current = last.NewStatus
+        case newStatus == StatusCreated && task.Status == StatusCreated:
+            current = ""
+        }
+        oldStatus := current
+        if !canTransition(current, newStatus) {
+            from := current
+            if from == "" {
+                from = "no history"
+            }
+            return apierror.Conflict("Invalid status transition",
+                apierror.Field("new_status", fmt.Sprintf("cannot move from %s to %s; allowed: %s",
+                    from, newStatus, allowedList(current))))
+        }
+
+        var statusID int
+        res = tx.Raw(`SELECT "Id" FROM "TaskStatuses" WHERE "Name" = ?`, newStatus).Scan(&statusID)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            return fmt.Errorf("status %s missing from TaskStatuses", newStatus)
+        }
+
+        // ChangeDate orders the history, so it must not fall behind the last
+        // row even if another writer's clock is ahead of ours.
+        changeDate := time.Now().UTC()
+        if hasHistory && !changeDate.After(last.ChangeDate) {
+            changeDate = last.ChangeDate.Add(time.Microsecond)
+        }
+
+        row = TaskHistory{
+            ID:         id,
+            TaskId:     taskID,
+            ChangeDate: changeDate,
+            OldStatus:  &oldStatus,
+            NewStatus:  newStatus,
+        }
+        res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            return apierror.Conflict("Idempotency-Key was already used for a different request")
+        }
+
+        return tx.Exec(`UPDATE "Tasks" SET "TaskStatusId" = ? WHERE "Id" = ?`, statusID, taskID).Error
+    })
+    return row, replayed, err
+}
+
+func allowedList(current string) string {
+    allowed := allowedTransitions(current)
+    if len(allowed) == 0 {
+        return "none"
+    }
+    return strings.Join(allowed, ", ")
+}
+
+// POST /api/taskHistories
+// Body: {"task_id": "{uuid}", "new_status": "Started"}
+// Header: Idempotency-Key (optional)
+//
+// Records a status change if the task's state machine allows it and
+// publishes task_<status> to task_queue. A retry with the same
+// Idempotency-Key returns the original row with 200 and publishes nothing.
+// Requires an admin or manager token.
+func createHistory(c *gin.Context) {
+    var req newHistoryRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        abortWithError(c, apierror.InvalidArgument("Invalid request body",
+            apierror.Field("body", "must be a JSON object with task_id and new_status")))
+        return
+    }
+
+    var v apierror.Validation
+    taskID, err := uuid.Parse(req.TaskID)
+    if err != nil {
+        v.Add("task_id", "not a valid UUID: "+req.TaskID)
+    }
+    newStatus, ok := canonicalStatus(req.NewStatus)
+    if !ok {
+        v.Add("new_status", "must be one of "+strings.Join(knownStatuses, ", "))
+    }
+    key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+    if len(key) > maxIdempotencyKeyLength {
+        v.Add("Idempotency-Key", fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength))
+    }
+    if apiErr := v.Err("Invalid task history"); apiErr != nil {
+        abortWithError(c, apiErr)
+        return
+    }
+
+    ctx := c.Request.Context()
+    row, replayed, err := recordTransition(ctx, taskID, newStatus, historyID(taskID, key))
+    var apiErr *apierror.Error
+    switch {
+    case errors.As(err, &apiErr):
+        abortWithError(c, apiErr)
+        return
+    case err != nil:
+        abortWithError(c, apierror.Internal("Failed to record task history", err))
+        return
+    }
+
+    if replayed {
+        c.Header("Idempotent-Replayed", "true")
+        c.JSON(200, ApiResponse{Message: "Task history already recorded", Data: row})
+        return
+    }
+
+    // The row is committed at this point; a lost event only delays the
+    // dashboards until the next change, so it does not fail the request.
+    if err := publisher.Publish(ctx, taskID, newStatus); err != nil {
+        log.Printf("Failed to publish %s event for task %s: %v", newStatus, taskID, err)
+    }
+
+    c.JSON(201, ApiResponse{Message: "Task history created", Data: row})
+}
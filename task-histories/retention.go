@@ -0,0 +1,709 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "math"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+
+    "go-shared/apierror"
+    "go-shared/config"
+)
+
+const (
+    manifestKey = "manifest.json"
+    // retentionLockKey is the Postgres advisory lock held by every archive
+    // and restore transaction, so replicas never archive the same rows.
+    retentionLockKey int64 = 0x7461736b68697374
+    restoreBatchSize       = 500
+)
+
+var (
+    errRetentionBusy    = errors.New("another retention run holds the lock")
+    errArchiveUnknown   = errors.New("archive is not in the manifest")
+    errArchiveCorrupted = errors.New("archive checksum does not match the manifest")
+)
+
+// AuditLog is a row of the AuditLogs table written by the other services.
+// This service only archives and restores it.
+type AuditLog struct {
+    ID          int       `json:"id" gorm:"column:Id;primaryKey"`
+    Timestamp   time.Time `json:"timestamp" gorm:"column:Timestamp;type:timestamptz"`
+    Service     string    `json:"service" gorm:"column:Service"`
+    Action      string    `json:"action" gorm:"column:Action"`
+    Entity      string    `json:"entity" gorm:"column:Entity"`
+    Description string    `json:"description" gorm:"column:Description"`
+}
+
+func (AuditLog) TableName() string {
+    return "AuditLogs"
+}
+
+type retentionConfig struct {
+    // Interval between scheduled runs; zero disables the schedule.
+    Interval time.Duration
+    // DryRun makes scheduled runs only report what they would archive.
+    DryRun bool
+    // BatchSize is the number of rows per archive file.
+    BatchSize int
+    // Maximum row age per table; zero keeps the table forever.
+    TaskHistoriesMaxAge time.Duration
+    AuditLogsMaxAge     time.Duration
+}
+
+func loadRetentionConfig(l *config.Loader) retentionConfig {
+    cfg := retentionConfig{
+        Interval:            l.Duration("RETENTION_INTERVAL", 24*time.Hour),
+        DryRun:              l.Bool("RETENTION_DRY_RUN", true),
+        BatchSize:           l.Int("RETENTION_BATCH_SIZE", 10000),
+        TaskHistoriesMaxAge: l.Duration("RETENTION_TASK_HISTORIES_MAX_AGE", 365*24*time.Hour),
+        AuditLogsMaxAge:     l.Duration("RETENTION_AUDIT_LOGS_MAX_AGE", 90*24*time.Hour),
+    }
+    if cfg.Interval < 0 {
+        l.Invalid("RETENTION_INTERVAL", "must not be negative")
+    }
+    // Row ids of a batch are bound as parameters of one DELETE, and Postgres
+    // allows 65535 of them.
+    if cfg.BatchSize < 1 || cfg.BatchSize > 50000 {
+        l.Invalid("RETENTION_BATCH_SIZE", "must be between 1 and 50000")
+    }
+    if cfg.TaskHistoriesMaxAge < 0 {
+        l.Invalid("RETENTION_TASK_HISTORIES_MAX_AGE", "must not be negative")
+    }
+    if cfg.AuditLogsMaxAge < 0 {
+        l.Invalid("RETENTION_AUDIT_LOGS_MAX_AGE", "must not be negative")
+    }
+    return cfg
+}
+
+// ArchiveEntry describes one archive file: rows of Table dated between From
+// and To, for TaskHistories the whole histories of some tasks. Held entries
+// were restored and their range is left alone by later runs until released.
+type ArchiveEntry struct {
+    ID         uuid.UUID  `json:"id"`
+    Table      string     `json:"table"`
+    Key        string     `json:"key"`
+    From       time.Time  `json:"from"`
+    To         time.Time  `json:"to"`
+    Rows       int        `json:"rows"`
+    Bytes      int        `json:"bytes"`
+    SHA256     string     `json:"sha256"`
+    ArchivedAt time.Time  `json:"archived_at"`
+    RestoredAt *time.Time `json:"restored_at,omitempty"`
+    Held       bool       `json:"held,omitempty"`
+}
+
+type archiveManifest struct {
+    Archives []ArchiveEntry `json:"archives"`
+}
+
+func (m *archiveManifest) find(id uuid.UUID) *ArchiveEntry {
+    for i := range m.Archives {
+        if m.Archives[i].ID == id {
+            return &m.Archives[i]
+        }
+    }
+    return nil
+}
+
+// TableRetention is the part of a run that concerns one table. In a dry run
+// Rows and ArchiveCount are what a real run would archive.
+type TableRetention struct {
+    Table        string         `json:"table"`
+    MaxAge       string         `json:"max_age"`
+    Cutoff       time.Time      `json:"cutoff"`
+    Rows         int64          `json:"rows"`
+    Oldest       *time.Time     `json:"oldest,omitempty"`
+    Newest       *time.Time     `json:"newest,omitempty"`
+    ArchiveCount int            `json:"archive_count"`
+    Archives     []ArchiveEntry `json:"archives,omitempty"`
+}
+
+// RetentionReport is the outcome of one run.
+type RetentionReport struct {
+    DryRun     bool             `json:"dry_run"`
+    StartedAt  time.Time        `json:"started_at"`
+    FinishedAt time.Time        `json:"finished_at"`
+    Tables     []TableRetention `json:"tables"`
+    Error      string           `json:"error,omitempty"`
+}
+
+// archivedBatch is what one archive file holds. Groups are the groups whose
+// rows it holds, for tables archived by group.
+type archivedBatch struct {
+    Rows     int
+    IDs      []any
+    Groups   []any
+    From, To time.Time
+}
+
+// retentionTable knows how to move the rows of one table in and out of
+// NDJSON.
+type retentionTable struct {
+    Name       string
+    Prefix     string
+    DateColumn string
+    // GroupColumn, when set, archives rows a group at a time and only once
+    // the group's newest row has expired. Task histories are grouped by task,
+    // since durations, timelines and the state machine need all of a task's
+    // rows.
+    GroupColumn string
+    MaxAge      time.Duration
+    model       any
+    // load reads up to limit rows of scope oldest first, locks them and
+    // writes them to w. Grouped tables are read whole groups at a time,
+    // oldest first, as many as fit in limit rows but at least one.
+    load func(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, limit int, w io.Writer) (archivedBatch, error)
+    // restore inserts the rows read from r, skipping ids that exist.
+    restore func(tx *gorm.DB, r io.Reader) (int64, error)
+}
+
+// newRetentionTable builds the table with the row type T, whose key returns
+// a row's id and date.
+func newRetentionTable[T any](name, prefix, dateColumn, groupColumn string, maxAge time.Duration, key func(*T) (any, time.Time)) retentionTable {
+    return retentionTable{
+        Name:        name,
+        Prefix:      prefix,
+        DateColumn:  dateColumn,
+        GroupColumn: groupColumn,
+        MaxAge:      maxAge,
+        model:       new(T),
+        load: func(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, limit int, w io.Writer) (archivedBatch, error) {
+            var batch archivedBatch
+            query := tx.Model(new(T)).Scopes(scope).Limit(limit)
+            if groupColumn != "" {
+                group := `"` + groupColumn + `"`
+                var groups []struct {
+                    ID   string
+                    Rows int
+                }
+                err := tx.Model(new(T)).Scopes(scope).
+                    Select(group + ` AS id, COUNT(*) AS rows`).
+                    Group(groupColumn).
+                    Order(`MAX("` + dateColumn + `")`).Order(group).
+                    Limit(limit).
+                    Scan(&groups).Error
+                if err != nil {
+                    return archivedBatch{}, err
+                }
+                rows := 0
+                for _, g := range groups {
+                    if len(batch.Groups) > 0 && rows+g.Rows > limit {
+                        break
+                    }
+                    batch.Groups = append(batch.Groups, g.ID)
+                    rows += g.Rows
+                }
+                if len(batch.Groups) == 0 {
+                    return archivedBatch{}, nil
+                }
+                query = tx.Model(new(T)).Where(group+` IN ?`, batch.Groups)
+            }
+
+            var rows []T
+            err := query.
+                Order(`"` + dateColumn + `"`).Order(`"Id"`).
+                Clauses(clause.Locking{Strength: "UPDATE"}).
+                Find(&rows).Error
+            if err != nil {
+                return archivedBatch{}, err
+            }
+            batch.Rows, batch.IDs = len(rows), make([]any, 0, len(rows))
+            enc := json.NewEncoder(w)
+            for i := range rows {
+                id, date := key(&rows[i])
+                if i == 0 {
+                    batch.From = date
+                }
+                batch.To = date
+                batch.IDs = append(batch.IDs, id)
+                if err := enc.Encode(rows[i]); err != nil {
+                    return archivedBatch{}, err
+                }
+            }
+            return batch, nil
+        },
+        restore: func(tx *gorm.DB, r io.Reader) (int64, error) {
+            var inserted int64
+            batch := make([]T, 0, restoreBatchSize)
+            flush := func() error {
+                if len(batch) == 0 {
+                    return nil
+                }
+                res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch)
+                inserted += res.RowsAffected
+                batch = batch[:0]
+                return res.Error
+            }
+            dec := json.NewDecoder(r)
+            for {
+                var row T
+                if err := dec.Decode(&row); errors.Is(err, io.EOF) {
+                    break
+                } else if err != nil {
+                    return inserted, err
+                }
+                batch = append(batch, row)
+                if len(batch) == restoreBatchSize {
+                    if err := flush(); err != nil {
+                        return inserted, err
+                    }
+                }
+            }
+            return inserted, flush()
+        },
+    }
+}
+
+// retentionTables lists every table, including those kept forever, so
+// their old archives can still be restored.
+func retentionTables(cfg retentionConfig) []retentionTable {
+    return []retentionTable{
+        newRetentionTable("TaskHistories", "task_histories", "ChangeDate", "TaskId", cfg.TaskHistoriesMaxAge,
+            func(h *TaskHistory) (any, time.Time) { return h.ID, h.ChangeDate }),
+        newRetentionTable("AuditLogs", "audit_logs", "Timestamp", "", cfg.AuditLogsMaxAge,
+            func(a *AuditLog) (any, time.Time) { return a.ID, a.Timestamp }),
+    }
+}
+
+// retentionManager archives expired rows into the store, keeps the manifest
+// and restores archives.
+type retentionManager struct {
+    db     *gorm.DB
+    store  archiveStore
+    cfg    retentionConfig
+    tables []retentionTable
+
+    // mu serializes runs and restores within the process. The manifest is
+    // only rewritten while holding retentionLockKey, which does the same
+    // across replicas.
+    mu   sync.Mutex
+    last *RetentionReport
+}
+
+func newRetentionManager(db *gorm.DB, store archiveStore, cfg retentionConfig) *retentionManager {
+    return &retentionManager{db: db, store: store, cfg: cfg, tables: retentionTables(cfg)}
+}
+
+// Run runs on the configured schedule until ctx is cancelled.
+func (m *retentionManager) Run(ctx context.Context) {
+    if m.cfg.Interval == 0 || (m.cfg.TaskHistoriesMaxAge == 0 && m.cfg.AuditLogsMaxAge == 0) {
+        log.Println("Retention schedule disabled")
+        return
+    }
+    mode := "archiving"
+    if m.cfg.DryRun {
+        mode = "dry run"
+    }
+    log.Printf("Retention every %s (%s) into %s", m.cfg.Interval, mode, m.store)
+
+    ticker := time.NewTicker(m.cfg.Interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+        report, err := m.RunOnce(ctx, m.cfg.DryRun)
+        switch {
+        case errors.Is(err, errRetentionBusy):
+            log.Println("Retention skipped:", err)
+        case err != nil && ctx.Err() == nil:
+            log.Printf("Retention run failed: %v", err)
+        case err == nil:
+            report.log()
+        }
+    }
+}
+
+func (r *RetentionReport) log() {
+    verb := "archived"
+    if r.DryRun {
+        verb = "would archive"
+    }
+    for _, t := range r.Tables {
+        log.Printf("Retention %s %d %s rows older than %s in %d archives",
+            verb, t.Rows, t.Table, t.Cutoff.Format(time.RFC3339), t.ArchiveCount)
+    }
+}
+
+// Latest returns the report of the last run, or nil before the first one.
+func (m *retentionManager) Latest() *RetentionReport {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.last
+}
+
+func (m *retentionManager) readManifest(ctx context.Context) (*archiveManifest, error) {
+    body, err := m.store.Get(ctx, manifestKey)
+    if errors.Is(err, errArchiveNotFound) {
+        return &archiveManifest{Archives: []ArchiveEntry{}}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var manifest archiveManifest
+    if err := json.Unmarshal(body, &manifest); err != nil {
+        return nil, fmt.Errorf("reading %s: %w", manifestKey, err)
+    }
+    return &manifest, nil
+}
+
+func (m *retentionManager) writeManifest(ctx context.Context, manifest *archiveManifest) error {
+    body, err := json.MarshalIndent(manifest, "", "  ")
+    if err != nil {
+        return err
+    }
+    return m.store.Put(ctx, manifestKey, body, "application/json")
+}
+
+// Manifest returns every archive, oldest first.
+func (m *retentionManager) Manifest(ctx context.Context) (*archiveManifest, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.readManifest(ctx)
+}
+
+// expired selects the rows of t older than cutoff, leaving out the ranges of
+// held archives. For a grouped table it selects the groups whose rows are
+// all older than cutoff and none in a held range.
+func (t retentionTable) expired(cutoff time.Time, manifest *archiveManifest) func(*gorm.DB) *gorm.DB {
+    column := `"` + t.DateColumn + `"`
+    var held []ArchiveEntry
+    for _, a := range manifest.Archives {
+        if a.Held && a.Table == t.Name {
+            held = append(held, a)
+        }
+    }
+    if t.GroupColumn == "" {
+        return func(tx *gorm.DB) *gorm.DB {
+            tx = tx.Where(column+" < ?", cutoff)
+            for _, a := range held {
+                tx = tx.Where("NOT ("+column+" BETWEEN ? AND ?)", a.From, a.To)
+            }
+            return tx
+        }
+    }
+
+    group := `"` + t.GroupColumn + `"`
+    having, args := "MAX("+column+") < ?", []any{cutoff}
+    for _, a := range held {
+        having += " AND COUNT(CASE WHEN " + column + " BETWEEN ? AND ? THEN 1 END) = 0"
+        args = append(args, a.From, a.To)
+    }
+    return func(tx *gorm.DB) *gorm.DB {
+        groups := tx.Session(&gorm.Session{NewDB: true}).Model(t.model).
+            Select(group).Group(t.GroupColumn).Having(having, args...)
+        return tx.Where(group+" IN (?)", groups)
+    }
+}
+
+func lockRetention(tx *gorm.DB) error {
+    var locked bool
+    if err := tx.Raw(`SELECT pg_try_advisory_xact_lock(?)`, retentionLockKey).Scan(&locked).Error; err != nil {
+        return err
+    }
+    if !locked {
+        return errRetentionBusy
+    }
+    return nil
+}
+
+// RunOnce archives every expired row, or with dryRun only counts them.
+//
+// Each archive file is its own transaction: the rows are locked and written
+// to the store, the manifest is updated and the rows are deleted before the
+// commit. If the commit fails the manifest lists an archive whose rows are
+// still in the table; they are archived again by the next run and restoring
+// either copy is harmless because restores skip existing ids.
+func (m *retentionManager) RunOnce(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    report := &RetentionReport{DryRun: dryRun, StartedAt: time.Now(), Tables: []TableRetention{}}
+    err := m.runOnce(ctx, report)
+    report.FinishedAt = time.Now()
+    if err != nil {
+        report.Error = err.Error()
+    }
+    m.last = report
+    return report, err
+}
+
+func (m *retentionManager) runOnce(ctx context.Context, report *RetentionReport) error {
+    for _, t := range m.tables {
+        if t.MaxAge == 0 {
+            continue
+        }
+        result := TableRetention{
+            Table:  t.Name,
+            MaxAge: t.MaxAge.String(),
+            Cutoff: report.StartedAt.Add(-t.MaxAge).UTC(),
+        }
+
+        if report.DryRun {
+            manifest, err := m.readManifest(ctx)
+            if err != nil {
+                return err
+            }
+            var stats struct {
+                Rows           int64
+                Oldest, Newest *time.Time
+            }
+            column := `"` + t.DateColumn + `"`
+            err = m.db.WithContext(ctx).Model(t.model).Scopes(t.expired(result.Cutoff, manifest)).
+                Select(`COUNT(*) AS rows, MIN(` + column + `) AS oldest, MAX(` + column + `) AS newest`).
+                Scan(&stats).Error
+            if err != nil {
+                return fmt.Errorf("%s: %w", t.Name, err)
+            }
+            result.Rows, result.Oldest, result.Newest = stats.Rows, stats.Oldest, stats.Newest
+            result.ArchiveCount = int(math.Ceil(float64(stats.Rows) / float64(m.cfg.BatchSize)))
+            report.Tables = append(report.Tables, result)
+            continue
+        }
+
+        for ctx.Err() == nil {
+            entry, err := m.archiveBatch(ctx, t, result.Cutoff)
+            if err != nil {
+                report.Tables = append(report.Tables, result)
+                return fmt.Errorf("%s: %w", t.Name, err)
+            }
+            if entry == nil {
+                break
+            }
+            result.Rows += int64(entry.Rows)
+            result.ArchiveCount++
+            result.Archives = append(result.Archives, *entry)
+            if result.Oldest == nil {
+                result.Oldest = &entry.From
+            }
+            result.Newest = &entry.To
+            if t.GroupColumn == "" && entry.Rows < m.cfg.BatchSize {
+                break
+            }
+        }
+        report.Tables = append(report.Tables, result)
+    }
+    return ctx.Err()
+}
+
+// archiveBatch moves one batch of t into the store. It returns nil when
+// nothing is left to archive.
+func (m *retentionManager) archiveBatch(ctx context.Context, t retentionTable, cutoff time.Time) (*ArchiveEntry, error) {
+    var entry *ArchiveEntry
+    err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := lockRetention(tx); err != nil {
+            return err
+        }
+        manifest, err := m.readManifest(ctx)
+        if err != nil {
+            return err
+        }
+
+        var buf bytes.Buffer
+        gz := gzip.NewWriter(&buf)
+        batch, err := t.load(tx, t.expired(cutoff, manifest), m.cfg.BatchSize, gz)
+        if err != nil {
+            return err
+        }
+        if batch.Rows == 0 {
+            return nil
+        }
+        if err := gz.Close(); err != nil {
+            return err
+        }
+
+        id := uuid.New()
+        sum := sha256.Sum256(buf.Bytes())
+        e := ArchiveEntry{
+            ID:    id,
+            Table: t.Name,
+            Key: fmt.Sprintf("%s/%s/%s_%s.ndjson.gz", t.Prefix, batch.From.UTC().Format("2006/01"),
+                batch.From.UTC().Format("20060102T150405Z"), id),
+            From:       batch.From,
+            To:         batch.To,
+            Rows:       batch.Rows,
+            Bytes:      buf.Len(),
+            SHA256:     hex.EncodeToString(sum[:]),
+            ArchivedAt: time.Now().UTC(),
+        }
+        if err := m.store.Put(ctx, e.Key, buf.Bytes(), "application/gzip"); err != nil {
+            return fmt.Errorf("uploading %s: %w", e.Key, err)
+        }
+
+        del := tx.Where(`"Id" IN ?`, batch.IDs)
+        if t.GroupColumn != "" {
+            // A group may hold more rows than can be bound as parameters.
+            del = tx.Where(`"`+t.GroupColumn+`" IN ?`, batch.Groups)
+        }
+        res := del.Delete(t.model)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected != int64(batch.Rows) {
+            return fmt.Errorf("deleted %d rows but archived %d", res.RowsAffected, batch.Rows)
+        }
+
+        manifest.Archives = append(manifest.Archives, e)
+        if err := m.writeManifest(ctx, manifest); err != nil {
+            return fmt.Errorf("updating manifest: %w", err)
+        }
+        entry = &e
+        return nil
+    })
+    return entry, err
+}
+
+func (m *retentionManager) table(name string) (retentionTable, bool) {
+    for _, t := range m.tables {
+        if t.Name == name {
+            return t, true
+        }
+    }
+    return retentionTable{}, false
+}
+
+// Restore inserts the rows of an archive back into its table, for task
+// histories whole histories, and holds the archive's range so the next run
+// does not archive them again. Rows whose id exists are skipped, so
+// restoring twice is harmless.
+func (m *retentionManager) Restore(ctx context.Context, id uuid.UUID) (*ArchiveEntry, int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var entry *ArchiveEntry
+    var inserted int64
+    err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, retentionLockKey).Error; err != nil {
+            return err
+        }
+        manifest, err := m.readManifest(ctx)
+        if err != nil {
+            return err
+        }
+        if entry = manifest.find(id); entry == nil {
+            return errArchiveUnknown
+        }
+        t, ok := m.table(entry.Table)
+        if !ok {
+            return fmt.Errorf("archive %s is of unknown table %s", id, entry.Table)
+        }
+
+        body, err := m.store.Get(ctx, entry.Key)
+        if err != nil {
+            return fmt.Errorf("downloading %s: %w", entry.Key, err)
+        }
+        if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != entry.SHA256 {
+            return errArchiveCorrupted
+        }
+        gz, err := gzip.NewReader(bytes.NewReader(body))
+        if err != nil {
+            return err
+        }
+        if inserted, err = t.restore(tx, gz); err != nil {
+            return err
+        }
+
+        now := time.Now().UTC()
+        entry.RestoredAt = &now
+        entry.Held = true
+        return m.writeManifest(ctx, manifest)
+    })
+    if err != nil {
+        return nil, 0, err
+    }
+    return entry, inserted, nil
+}
+
+// Release lets later runs archive the range of a restored archive again.
+func (m *retentionManager) Release(ctx context.Context, id uuid.UUID) (*ArchiveEntry, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var entry *ArchiveEntry
+    err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, retentionLockKey).Error; err != nil {
+            return err
+        }
+        manifest, err := m.readManifest(ctx)
+        if err != nil {
+            return err
+        }
+        if entry = manifest.find(id); entry == nil {
+            return errArchiveUnknown
+        }
+        if !entry.Held {
+            return nil
+        }
+        entry.Held = false
+        return m.writeManifest(ctx, manifest)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return entry, nil
+}
+
+var retention *retentionManager
+
+// retentionSettings is the configuration shown by GET /api/taskHistories/retention.
+type retentionSettings struct {
+    Interval  string            `json:"interval"`
+    DryRun    bool              `json:"dry_run"`
+    BatchSize int               `json:"batch_size"`
+    Store     string            `json:"store"`
+    MaxAge    map[string]string `json:"max_age"`
+}
+
+// GET /api/taskHistories/retention
+//
+// Returns the retention settings, the report of the last run and the
+// manifest of every archive.
+// Runs, restores and releases are not exposed over HTTP; they are done
+// with `task-histories retention` inside the container.
+func getRetention(c *gin.Context) {
+    manifest, err := retention.Manifest(c.Request.Context())
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to read archive manifest", err))
+        return
+    }
+    settings := retentionSettings{
+        Interval:  retention.cfg.Interval.String(),
+        DryRun:    retention.cfg.DryRun,
+        BatchSize: retention.cfg.BatchSize,
+        Store:     retention.store.String(),
+        MaxAge:    map[string]string{},
+    }
+    for _, t := range retention.tables {
+        maxAge := "forever"
+        if t.MaxAge > 0 {
+            maxAge = t.MaxAge.String()
+        }
+        settings.MaxAge[t.Name] = maxAge
+    }
+
+    c.JSON(200, ApiResponse{
+        Message: "Retention status retrieved successfully",
+        Data: gin.H{
+            "settings": settings,
+            "last_run": retention.Latest(),
+            "archives": manifest.Archives,
+        },
+    })
+}
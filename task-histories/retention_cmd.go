@@ -0,0 +1,127 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "syscall"
+
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+    "gorm.io/gorm/schema"
+
+    "go-shared/config"
+    "go-shared/database"
+)
+
+const retentionUsage = `usage: task-histories retention <command>
+
+commands:
+  run [-dry-run]   archive rows older than the configured age now
+  list             print the archive manifest
+  restore <id>     insert the rows of an archive back into its table
+  release <id>     let later runs archive a restored range again
+
+Settings are read from the same environment as the service.`
+
+// retentionCommand runs `task-histories retention ...` against the
+// configured database and archive store and returns the exit code. It is
+// meant for `docker compose exec task-histories ./task-histories retention`.
+func retentionCommand(args []string) int {
+    if len(args) == 0 {
+        fmt.Fprintln(os.Stderr, retentionUsage)
+        return 2
+    }
+    command, args := args[0], args[1:]
+
+    flags := flag.NewFlagSet("retention "+command, flag.ContinueOnError)
+    dryRun := flags.Bool("dry-run", false, "only report what would be archived")
+    if err := flags.Parse(args); err != nil {
+        return 2
+    }
+    var id uuid.UUID
+    switch command {
+    case "run", "list":
+        if flags.NArg() != 0 {
+            fmt.Fprintln(os.Stderr, retentionUsage)
+            return 2
+        }
+    case "restore", "release":
+        var err error
+        if flags.NArg() != 1 {
+            fmt.Fprintln(os.Stderr, retentionUsage)
+            return 2
+        }
+        if id, err = uuid.Parse(flags.Arg(0)); err != nil {
+            fmt.Fprintf(os.Stderr, "invalid archive id %q\n", flags.Arg(0))
+            return 2
+        }
+    default:
+        fmt.Fprintln(os.Stderr, retentionUsage)
+        return 2
+    }
+
+    loader := config.NewLoader()
+    dbConfig := database.LoadConfig(loader)
+    retentionConfig := loadRetentionConfig(loader)
+    storeConfig := loadArchiveStoreConfig(loader)
+    if err := loader.Validate(); err != nil {
+        log.Print("Invalid configuration: ", err)
+        return 1
+    }
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    conn, err := database.Open(ctx, dbConfig, &gorm.Config{
+        NamingStrategy: schema.NamingStrategy{
+            SingularTable: true,
+        },
+    })
+    if err != nil {
+        log.Print("Failed to connect to database: ", err)
+        return 1
+    }
+    defer database.Close(conn)
+
+    store, err := openArchiveStore(ctx, storeConfig)
+    if err != nil {
+        log.Print("Failed to open archive store: ", err)
+        return 1
+    }
+    manager := newRetentionManager(conn, store, retentionConfig)
+
+    var result any
+    switch command {
+    case "run":
+        result, err = manager.RunOnce(ctx, *dryRun)
+    case "list":
+        result, err = manager.Manifest(ctx)
+    case "restore":
+        var entry *ArchiveEntry
+        var inserted int64
+        entry, inserted, err = manager.Restore(ctx, id)
+        if err == nil {
+            log.Printf("Restored %d of %d rows into %s", inserted, entry.Rows, entry.Table)
+            result = entry
+        }
+    case "release":
+        result, err = manager.Release(ctx, id)
+    }
+    if err != nil {
+        log.Printf("retention %s failed: %v", command, err)
+        return 1
+    }
+
+    enc := json.NewEncoder(os.Stdout)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(result); err != nil {
+        log.Print(err)
+        return 1
+    }
+    return 0
+}
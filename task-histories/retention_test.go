@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openRetentionTestDB returns an in-memory SQLite database with the tables
+// retention works on. It needs cgo and skips the test without it.
+func openRetentionTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err == nil {
+		err = conn.Exec(`SELECT 1`).Error
+	}
+	if err != nil {
+		t.Skipf("SQLite not available: %v", err)
+	}
+	sqlDB, _ := conn.DB()
+	// Every connection to :memory: is a new database.
+	sqlDB.SetMaxOpenConns(1)
+	// DATETIME rather than timestamptz, which the SQLite driver would not
+	// read back as time.Time.
+	err = conn.Exec(`CREATE TABLE "TaskHistories" ("Id" TEXT PRIMARY KEY, "TaskId" TEXT, "ChangeDate" DATETIME,
+		"OldStatus" TEXT, "NewStatus" TEXT)`).Error
+	if err == nil {
+		err = conn.Exec(`CREATE TABLE "AuditLogs" ("Id" INTEGER PRIMARY KEY, "Timestamp" DATETIME, "Service" TEXT,
+		"Action" TEXT, "Entity" TEXT, "Description" TEXT)`).Error
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+var retentionNow = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func day(n int) time.Time { return retentionNow.AddDate(0, 0, n) }
+
+// historyRows returns a task's rows at the given days relative to
+// retentionNow, in order from Created.
+func historyRows(taskID uuid.UUID, days ...int) []TaskHistory {
+	statuses := []string{StatusCreated, StatusAssigned, StatusStarted, StatusCompleted}
+	var rows []TaskHistory
+	for i, d := range days {
+		row := TaskHistory{ID: uuid.New(), TaskId: taskID, ChangeDate: day(d), NewStatus: statuses[i]}
+		if i > 0 {
+			old := statuses[i-1]
+			row.OldStatus = &old
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func seedHistories(t *testing.T, conn *gorm.DB, rows ...[]TaskHistory) {
+	t.Helper()
+	for _, r := range rows {
+		if err := conn.Create(&r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expiredIDs(t *testing.T, conn *gorm.DB, table retentionTable, cutoff time.Time, manifest *archiveManifest) map[string]bool {
+	t.Helper()
+	var ids []string
+	if err := conn.Model(table.model).Scopes(table.expired(cutoff, manifest)).Pluck("Id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, id := range ids {
+		found[id] = true
+	}
+	return found
+}
+
+func TestRetentionExpiredKeepsTaskHistoriesWhole(t *testing.T) {
+	conn := openRetentionTestDB(t)
+	histories := retentionTables(retentionConfig{})[0]
+
+	done := historyRows(uuid.New(), -300, -299, -298, -290)
+	// Started long ago but changed after the cutoff.
+	running := historyRows(uuid.New(), -300, -299, -10)
+	recent := historyRows(uuid.New(), -5, -4)
+	seedHistories(t, conn, done, running, recent)
+
+	found := expiredIDs(t, conn, histories, day(-30), &archiveManifest{})
+	if len(found) != len(done) {
+		t.Errorf("selected %d rows, want the %d of the finished task", len(found), len(done))
+	}
+	for _, row := range done {
+		if !found[row.ID.String()] {
+			t.Errorf("row at %s of the finished task not selected", row.ChangeDate)
+		}
+	}
+}
+
+func TestRetentionExpiredSkipsHeldRanges(t *testing.T) {
+	conn := openRetentionTestDB(t)
+	tables := retentionTables(retentionConfig{})
+	histories, auditLogs := tables[0], tables[1]
+
+	held := historyRows(uuid.New(), -300, -299)
+	free := historyRows(uuid.New(), -200, -199)
+	seedHistories(t, conn, held, free)
+	logs := []AuditLog{{ID: 1, Timestamp: day(-300)}, {ID: 2, Timestamp: day(-200)}}
+	if err := conn.Create(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &archiveManifest{Archives: []ArchiveEntry{
+		{Table: "TaskHistories", From: day(-299), To: day(-250), Held: true},
+		{Table: "AuditLogs", From: day(-301), To: day(-299), Held: true},
+		// Released archives no longer hold their range.
+		{Table: "TaskHistories", From: day(-201), To: day(-199)},
+	}}
+
+	found := expiredIDs(t, conn, histories, day(-30), manifest)
+	for _, row := range held {
+		if found[row.ID.String()] {
+			t.Errorf("row at %s of a task in a held range selected", row.ChangeDate)
+		}
+	}
+	for _, row := range free {
+		if !found[row.ID.String()] {
+			t.Errorf("row at %s outside held ranges not selected", row.ChangeDate)
+		}
+	}
+
+	var ids []int
+	if err := conn.Model(&AuditLog{}).Scopes(auditLogs.expired(day(-30), manifest)).Pluck("Id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int{2}) {
+		t.Errorf("audit logs selected %v, want [2]", ids)
+	}
+}
+
+func TestRetentionArchiveRestoreRoundTrip(t *testing.T) {
+	conn := openRetentionTestDB(t)
+	histories := retentionTables(retentionConfig{})[0]
+
+	first := historyRows(uuid.New(), -300, -299, -298)
+	second := historyRows(uuid.New(), -280, -279)
+	kept := historyRows(uuid.New(), -5)
+	seedHistories(t, conn, first, second, kept)
+
+	// A limit below the two histories still archives the first whole.
+	var buf bytes.Buffer
+	batch, err := histories.load(conn, histories.expired(day(-30), &archiveManifest{}), 4, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Rows != len(first) || len(batch.Groups) != 1 || batch.Groups[0] != first[0].TaskId.String() {
+		t.Fatalf("batch = %d rows of %v, want the %d rows of task %s", batch.Rows, batch.Groups, len(first), first[0].TaskId)
+	}
+	if !batch.From.Equal(day(-300)) || !batch.To.Equal(day(-298)) {
+		t.Errorf("batch covers %s to %s", batch.From, batch.To)
+	}
+
+	if err := conn.Where(`"TaskId" IN ?`, batch.Groups).Delete(&TaskHistory{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	inserted, err := histories.restore(conn, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != int64(len(first)) {
+		t.Errorf("restored %d rows, want %d", inserted, len(first))
+	}
+	// Restoring again skips the rows that are back.
+	if inserted, err := histories.restore(conn, bytes.NewReader(buf.Bytes())); err != nil || inserted != 0 {
+		t.Errorf("second restore inserted %d rows (%v), want 0", inserted, err)
+	}
+
+	var restored []TaskHistory
+	err = conn.Where(`"TaskId" = ?`, first[0].TaskId).Order(`"ChangeDate"`).Find(&restored).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != len(first) {
+		t.Fatalf("task has %d rows after restore, want %d", len(restored), len(first))
+	}
+	for i, row := range restored {
+		want := first[i]
+		if row.ID != want.ID || !row.ChangeDate.Equal(want.ChangeDate) || row.NewStatus != want.NewStatus ||
+			!reflect.DeepEqual(row.OldStatus, want.OldStatus) {
+			t.Errorf("row %d = %+v, want %+v", i, row, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "go-shared/apierror"
+    "go-shared/config"
+)
+
+const streamBatchSize = 100
+
+type streamConfig struct {
+    // PollInterval is how often new rows are looked for.
+    PollInterval time.Duration
+    // Heartbeat is how often a comment is sent on an idle stream so proxies
+    // keep the connection open.
+    Heartbeat time.Duration
+    // LateWindow is how far behind the newest streamed row each poll looks
+    // again for rows that committed after rows with a later ChangeDate.
+    LateWindow time.Duration
+}
+
+func loadStreamConfig(l *config.Loader) streamConfig {
+    cfg := streamConfig{
+        PollInterval: l.Duration("STREAM_POLL_INTERVAL", time.Second),
+        Heartbeat:    l.Duration("STREAM_HEARTBEAT", 15*time.Second),
+        LateWindow:   l.Duration("STREAM_LATE_WINDOW", 10*time.Second),
+    }
+    if cfg.PollInterval <= 0 {
+        l.Invalid("STREAM_POLL_INTERVAL", "must be positive")
+    }
+    if cfg.Heartbeat <= 0 {
+        l.Invalid("STREAM_HEARTBEAT", "must be positive")
+    }
+    if cfg.LateWindow < 0 {
+        l.Invalid("STREAM_LATE_WINDOW", "must not be negative")
+    }
+    return cfg
+}
+
+var (
+    streamSettings streamConfig
+    // streamsClosing is closed when the server starts shutting down, which
+    // ends every open stream so the HTTP server can drain.
+    streamsClosing = make(chan struct{})
+)
+
+// parseStreamStart returns the position the stream starts after: the
+// Last-Event-ID header (or lastEventId parameter for clients that cannot set
+// headers), else ?since=, else the current time.
+func parseStreamStart(c *gin.Context, v *apierror.Validation) historyCursor {
+    lastEventID := c.GetHeader("Last-Event-ID")
+    if lastEventID == "" {
+        lastEventID = c.Query("lastEventId")
+    }
+    if lastEventID != "" {
+        cursor, err := decodeHistoryCursor(lastEventID)
+        if err != nil {
+            v.Add("Last-Event-ID", "not an event id sent by this stream")
+            return historyCursor{}
+        }
+        return *cursor
+    }
+    if since := c.Query("since"); since != "" {
+        t, err := parseTimeParam(since, false)
+        if err != nil {
+            v.Add("since", "use RFC 3339 or YYYY-MM-DD")
+            return historyCursor{}
+        }
+        return historyCursor{ChangeDate: *t, ID: uuid.Nil}
+    }
+    return historyCursor{ChangeDate: time.Now(), ID: uuid.Nil}
+}
+
+// streamRoles may tail the stream. The auditor role lets someone follow
+// histories without being made a manager of a team.
+var streamRoles = []string{"admin", "manager", "auditor"}
+
+// GET /api/taskHistories/stream?taskId={uuid,...}&teamId={uuid,...}&oldStatus=&newStatus=&since={time}
+//
+// Server-sent events, one "history" event per new row in (ChangeDate, Id)
+// order. The event id is the row's cursor, so a reconnecting EventSource
+// resumes after the last row it received through Last-Event-ID.
+//
+// New rows are found by polling. Rows are not always committed in ChangeDate
+// order, so each poll also looks for unsent rows up to STREAM_LATE_WINDOW
+// before the newest one sent; those are sent with the current event id. A
+// row that commits later than that, or while the client reconnects, is not
+// streamed.
+//
+// Requires a token with one of streamRoles.
+func streamHistories(c *gin.Context) {
+    var v apierror.Validation
+    q := parseHistoryFilters(c, &v)
+    position := parseStreamStart(c, &v)
+    if apiErr := v.Err("Invalid query parameters"); apiErr != nil {
+        abortWithError(c, apiErr)
+        return
+    }
+
+    c.Header("Content-Type", "text/event-stream")
+    c.Header("Cache-Control", "no-cache")
+    c.Header("Connection", "keep-alive")
+    c.Header("X-Accel-Buffering", "no")
+    c.Status(200)
+    fmt.Fprintf(c.Writer, "retry: %d\n\n", streamSettings.PollInterval.Milliseconds()*2)
+    c.Writer.Flush()
+
+    ctx := c.Request.Context()
+    poll := time.NewTicker(streamSettings.PollInterval)
+    defer poll.Stop()
+    lastWrite := time.Now()
+    start := position.ChangeDate
+    // sent holds the rows sent within the late window of position, by Id.
+    sent := map[uuid.UUID]time.Time{}
+
+    for {
+        var rows []TaskHistory
+        err := position.after(q.order(q.filter(db.WithContext(ctx).Model(&TaskHistory{}))), false).
+            Limit(streamBatchSize).
+            Find(&rows).Error
+        if err == nil && len(rows) < streamBatchSize && streamSettings.LateWindow > 0 {
+            var late []TaskHistory
+            late, err = lateHistories(ctx, q, position, start, sent)
+            rows = append(late, rows...)
+        }
+        if err != nil {
+            if ctx.Err() == nil {
+                log.Printf("History stream query failed: %v", err)
+                fmt.Fprint(c.Writer, "event: error\ndata: {\"message\":\"Failed to retrieve task histories\"}\n\n")
+                c.Writer.Flush()
+            }
+            return
+        }
+
+        for _, row := range rows {
+            data, err := json.Marshal(row)
+            if err != nil {
+                log.Printf("History stream encoding failed: %v", err)
+                return
+            }
+            if row.ChangeDate.After(position.ChangeDate) ||
+                row.ChangeDate.Equal(position.ChangeDate) && row.ID.String() > position.ID.String() {
+                position = historyCursor{ChangeDate: row.ChangeDate, ID: row.ID}
+            }
+            sent[row.ID] = row.ChangeDate
+            fmt.Fprintf(c.Writer, "id: %s\nevent: history\ndata: %s\n\n", position.encode(), data)
+        }
+        if len(rows) > 0 {
+            c.Writer.Flush()
+            lastWrite = time.Now()
+        }
+        for id, changeDate := range sent {
+            if !changeDate.After(position.ChangeDate.Add(-streamSettings.LateWindow)) {
+                delete(sent, id)
+            }
+        }
+        if len(rows) == streamBatchSize {
+            // More rows are waiting; fetch them without sleeping.
+            continue
+        }
+
+        if time.Since(lastWrite) >= streamSettings.Heartbeat {
+            fmt.Fprint(c.Writer, ": heartbeat\n\n")
+            c.Writer.Flush()
+            lastWrite = time.Now()
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-streamsClosing:
+            return
+        case <-poll.C:
+        }
+    }
+}
+
+// lateHistories returns the rows at or before position, within the late
+// window and after start, that have not been sent.
+func lateHistories(ctx context.Context, q historyQuery, position historyCursor, start time.Time, sent map[uuid.UUID]time.Time) ([]TaskHistory, error) {
+    from := position.ChangeDate.Add(-streamSettings.LateWindow)
+    if from.Before(start) {
+        from = start
+    }
+    query := q.filter(db.WithContext(ctx).Model(&TaskHistory{})).
+        Where(`"ChangeDate" > ? AND ("ChangeDate", "Id") <= (?, ?)`, from, position.ChangeDate, position.ID)
+    if len(sent) > 0 {
+        ids := make([]uuid.UUID, 0, len(sent))
+        for id := range sent {
+            ids = append(ids, id)
+        }
+        query = query.Where(`"Id" NOT IN ?`, ids)
+    }
+    var rows []TaskHistory
+    err := q.order(query).Find(&rows).Error
+    return rows, err
+}
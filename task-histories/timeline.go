@@ -0,0 +1,166 @@
+package main
+
+import (
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+
+    "go-shared/apierror"
+    "go-shared/duration"
+)
+
+// WorkInterval is a span between a Started row and the Paused, Stopped or
+// Completed row that ends it. End and EndStatus are empty for the interval
+// that is still open; its duration runs until the time of the request.
+type WorkInterval struct {
+    Start           time.Time  `json:"start"`
+    End             *time.Time `json:"end"`
+    EndStatus       string     `json:"end_status,omitempty"`
+    DurationSeconds float64    `json:"duration_seconds"`
+    Duration        string     `json:"duration"`
+}
+
+// IdleGap is the time between the end of one work interval and the start of
+// the next. Status is the status the task waited in.
+type IdleGap struct {
+    Start           time.Time `json:"start"`
+    End             time.Time `json:"end"`
+    Status          string    `json:"status"`
+    DurationSeconds float64   `json:"duration_seconds"`
+    Duration        string    `json:"duration"`
+}
+
+// Timeline is the response of GET /api/taskHistories/{taskId}/timeline.
+type Timeline struct {
+    TaskID             uuid.UUID      `json:"task_id"`
+    Status             string         `json:"status"`
+    FirstStart         *time.Time     `json:"first_start"`
+    CompletedAt        *time.Time     `json:"completed_at"`
+    TotalActiveSeconds float64        `json:"total_active_seconds"`
+    TotalActive        string         `json:"total_active"`
+    TotalIdleSeconds   float64        `json:"total_idle_seconds"`
+    TotalIdle          string         `json:"total_idle"`
+    Intervals          []WorkInterval `json:"intervals"`
+    IdleGaps           []IdleGap      `json:"idle_gaps"`
+    OpenInterval       *WorkInterval  `json:"open_interval"`
+}
+
+func newWorkInterval(start, end time.Time, endStatus string) WorkInterval {
+    d := end.Sub(start)
+    w := WorkInterval{
+        Start:           start,
+        DurationSeconds: duration.Seconds(d),
+        Duration:        duration.ISO8601(d),
+    }
+    if endStatus != "" {
+        w.End = &end
+        w.EndStatus = endStatus
+    }
+    return w
+}
+
+// buildTimeline computes the timeline of one task from its history rows in
+// ChangeDate order. It follows GetManagerTasksWithDetails: time counts from a
+// Started row to the next Paused, Stopped or Completed row, and an interval
+// still open at the last row counts until now.
+//
+// Rows that the state machine now rejects are tolerated for older data: a
+// Started row while already started keeps the earlier start, and a closing
+// row while not started is ignored.
+func buildTimeline(taskID uuid.UUID, rows []TaskHistory, now time.Time) Timeline {
+    t := Timeline{
+        TaskID:    taskID,
+        Intervals: []WorkInterval{},
+        IdleGaps:  []IdleGap{},
+    }
+
+    var active, idle time.Duration
+    var openStart, lastEnd *time.Time
+    var lastEndStatus string
+
+    for _, row := range rows {
+        t.Status = row.NewStatus
+        switch row.NewStatus {
+        case StatusStarted:
+            if openStart != nil {
+                continue
+            }
+            start := row.ChangeDate
+            openStart = &start
+            if t.FirstStart == nil {
+                t.FirstStart = &start
+            }
+            if lastEnd != nil {
+                gap := start.Sub(*lastEnd)
+                idle += gap
+                t.IdleGaps = append(t.IdleGaps, IdleGap{
+                    Start:           *lastEnd,
+                    End:             start,
+                    Status:          lastEndStatus,
+                    DurationSeconds: duration.Seconds(gap),
+                    Duration:        duration.ISO8601(gap),
+                })
+            }
+        case StatusPaused, StatusStopped, StatusCompleted:
+            if openStart == nil {
+                break
+            }
+            end := row.ChangeDate
+            active += end.Sub(*openStart)
+            t.Intervals = append(t.Intervals, newWorkInterval(*openStart, end, row.NewStatus))
+            lastEnd, lastEndStatus = &end, row.NewStatus
+            openStart = nil
+        }
+        if row.NewStatus == StatusCompleted {
+            completed := row.ChangeDate
+            t.CompletedAt = &completed
+        }
+    }
+
+    // A task started again after being completed is no longer complete.
+    if t.Status != StatusCompleted {
+        t.CompletedAt = nil
+    }
+
+    if openStart != nil {
+        open := newWorkInterval(*openStart, now, "")
+        active += now.Sub(*openStart)
+        t.OpenInterval = &open
+    }
+
+    t.TotalActiveSeconds = duration.Seconds(active)
+    t.TotalActive = duration.ISO8601(active)
+    t.TotalIdleSeconds = duration.Seconds(idle)
+    t.TotalIdle = duration.ISO8601(idle)
+    return t
+}
+
+// GET /api/taskHistories/{taskId}/timeline
+func getTimeline(c *gin.Context) {
+    taskID, err := uuid.Parse(c.Param("taskId"))
+    if err != nil {
+        abortWithError(c, apierror.InvalidArgument("Invalid task ID",
+            apierror.Field("taskId", "not a valid UUID: "+c.Param("taskId"))))
+        return
+    }
+
+    var rows []TaskHistory
+    err = db.WithContext(c.Request.Context()).
+        Where(`"TaskId" = ?`, taskID).
+        Order(`"ChangeDate" ASC`).Order(`"Id" ASC`).
+        Find(&rows).Error
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to retrieve task histories", err))
+        return
+    }
+    if len(rows) == 0 {
+        abortWithError(c, apierror.NotFound("No history found for task"))
+        return
+    }
+
+    c.JSON(200, ApiResponse{
+        Message: "Task timeline retrieved successfully",
+        Data:    buildTimeline(taskID, rows, time.Now()),
+    })
+}
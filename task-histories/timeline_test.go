@@ -0,0 +1,184 @@
+package main
+
+import (
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+var timelineBase = time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+
+// at returns timelineBase plus the given number of minutes.
+func at(minutes int) time.Time {
+    return timelineBase.Add(time.Duration(minutes) * time.Minute)
+}
+
+// history builds consecutive rows from (minute, status) pairs.
+func history(taskID uuid.UUID, steps ...any) []TaskHistory {
+    var rows []TaskHistory
+    var old *string
+    for i := 0; i < len(steps); i += 2 {
+        status := steps[i+1].(string)
+        rows = append(rows, TaskHistory{
+            ID:         uuid.New(),
+            TaskId:     taskID,
+            ChangeDate: at(steps[i].(int)),
+            OldStatus:  old,
+            NewStatus:  status,
+        })
+        old = &status
+    }
+    return rows
+}
+
+func TestBuildTimeline(t *testing.T) {
+    taskID := uuid.New()
+    now := at(600)
+
+    tests := []struct {
+        name        string
+        rows        []TaskHistory
+        status      string
+        active      time.Duration
+        idle        time.Duration
+        intervals   int
+        gaps        int
+        firstStart  *time.Time
+        completedAt *time.Time
+        open        bool
+    }{
+        {
+            name:   "never started",
+            rows:   history(taskID, 0, StatusCreated, 10, StatusAssigned),
+            status: StatusAssigned,
+        },
+        {
+            name:        "single completed interval",
+            rows:        history(taskID, 0, StatusAssigned, 30, StatusStarted, 90, StatusCompleted),
+            status:      StatusCompleted,
+            active:      time.Hour,
+            intervals:   1,
+            firstStart:  ptr(at(30)),
+            completedAt: ptr(at(90)),
+        },
+        {
+            name: "pauses and stops leave idle gaps",
+            rows: history(taskID,
+                0, StatusStarted, 60, StatusPaused,
+                75, StatusStarted, 120, StatusStopped,
+                300, StatusStarted, 330, StatusCompleted),
+            status:      StatusCompleted,
+            active:      135 * time.Minute,
+            idle:        195 * time.Minute,
+            intervals:   3,
+            gaps:        2,
+            firstStart:  ptr(at(0)),
+            completedAt: ptr(at(330)),
+        },
+        {
+            name:       "open interval counts until now",
+            rows:       history(taskID, 0, StatusStarted, 60, StatusPaused, 500, StatusStarted),
+            status:     StatusStarted,
+            active:     60*time.Minute + 100*time.Minute,
+            idle:       440 * time.Minute,
+            intervals:  1,
+            gaps:       1,
+            firstStart: ptr(at(0)),
+            open:       true,
+        },
+        {
+            name:       "repeated start keeps the first start",
+            rows:       history(taskID, 0, StatusStarted, 30, StatusStarted, 60, StatusPaused),
+            status:     StatusPaused,
+            active:     time.Hour,
+            intervals:  1,
+            firstStart: ptr(at(0)),
+        },
+        {
+            name:   "close without start is ignored",
+            rows:   history(taskID, 0, StatusAssigned, 10, StatusPaused),
+            status: StatusPaused,
+        },
+        {
+            name:       "restarted after completion is not complete",
+            rows:       history(taskID, 0, StatusStarted, 60, StatusCompleted, 120, StatusStarted),
+            status:     StatusStarted,
+            active:     time.Hour + 480*time.Minute,
+            idle:       time.Hour,
+            intervals:  1,
+            gaps:       1,
+            firstStart: ptr(at(0)),
+            open:       true,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := buildTimeline(taskID, tt.rows, now)
+
+            if got.Status != tt.status {
+                t.Errorf("Status = %q, want %q", got.Status, tt.status)
+            }
+            if got.TotalActiveSeconds != tt.active.Seconds() {
+                t.Errorf("TotalActiveSeconds = %v, want %v", got.TotalActiveSeconds, tt.active.Seconds())
+            }
+            if got.TotalIdleSeconds != tt.idle.Seconds() {
+                t.Errorf("TotalIdleSeconds = %v, want %v", got.TotalIdleSeconds, tt.idle.Seconds())
+            }
+            if len(got.Intervals) != tt.intervals {
+                t.Errorf("len(Intervals) = %d, want %d", len(got.Intervals), tt.intervals)
+            }
+            if len(got.IdleGaps) != tt.gaps {
+                t.Errorf("len(IdleGaps) = %d, want %d", len(got.IdleGaps), tt.gaps)
+            }
+            if !equalTime(got.FirstStart, tt.firstStart) {
+                t.Errorf("FirstStart = %v, want %v", got.FirstStart, tt.firstStart)
+            }
+            if !equalTime(got.CompletedAt, tt.completedAt) {
+                t.Errorf("CompletedAt = %v, want %v", got.CompletedAt, tt.completedAt)
+            }
+            if (got.OpenInterval != nil) != tt.open {
+                t.Errorf("OpenInterval = %+v, want open %t", got.OpenInterval, tt.open)
+            }
+        })
+    }
+}
+
+func TestBuildTimelineIntervalDetails(t *testing.T) {
+    taskID := uuid.New()
+    rows := history(taskID, 0, StatusStarted, 90, StatusPaused, 100, StatusStarted)
+    got := buildTimeline(taskID, rows, at(130))
+
+    first := got.Intervals[0]
+    if !first.Start.Equal(at(0)) || !first.End.Equal(at(90)) || first.EndStatus != StatusPaused {
+        t.Errorf("first interval = %+v", first)
+    }
+    if first.Duration != "PT1H30M" || first.DurationSeconds != 5400 {
+        t.Errorf("first interval duration = %q / %v", first.Duration, first.DurationSeconds)
+    }
+
+    gap := got.IdleGaps[0]
+    if !gap.Start.Equal(at(90)) || !gap.End.Equal(at(100)) || gap.Status != StatusPaused || gap.Duration != "PT10M" {
+        t.Errorf("idle gap = %+v", gap)
+    }
+
+    open := got.OpenInterval
+    if open.End != nil || open.EndStatus != "" || !open.Start.Equal(at(100)) || open.Duration != "PT30M" {
+        t.Errorf("open interval = %+v", open)
+    }
+    if got.TotalActive != "PT2H" {
+        t.Errorf("TotalActive = %q, want PT2H", got.TotalActive)
+    }
+}
+
+func ptr(t time.Time) *time.Time {
+    return &t
+}
+
+func equalTime(a, b *time.Time) bool {
+    if a == nil || b == nil {
+        return a == b
+    }
+    return a.Equal(*b)
+}
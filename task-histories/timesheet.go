@@ -0,0 +1,387 @@
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    // The runtime image has no zoneinfo; embed it for the tz parameter.
+    _ "time/tzdata"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
+
+    "go-shared/apierror"
+    "go-shared/csvcell"
+)
+
+const maxTimesheetDays = 93
+
+// TimesheetEntry is the time one developer worked on one task on one
+// calendar day of the requested time zone.
+type TimesheetEntry struct {
+    DeveloperID   uuid.UUID `json:"developer_id"`
+    DeveloperName string    `json:"developer_name"`
+    TaskID        uuid.UUID `json:"task_id"`
+    TaskName      string    `json:"task_name"`
+    Date          string    `json:"date"`
+    Seconds       float64   `json:"seconds"`
+    Hours         float64   `json:"hours"`
+}
+
+// DeveloperTotal sums a developer's entries over the whole range.
+type DeveloperTotal struct {
+    DeveloperID   uuid.UUID `json:"developer_id"`
+    DeveloperName string    `json:"developer_name"`
+    Seconds       float64   `json:"seconds"`
+    Hours         float64   `json:"hours"`
+}
+
+// Timesheet is the JSON response of GET /api/taskHistories/timesheet.
+type Timesheet struct {
+    From     string           `json:"from"`
+    To       string           `json:"to"`
+    Timezone string           `json:"timezone"`
+    Entries  []TimesheetEntry `json:"entries"`
+    Totals   []DeveloperTotal `json:"totals"`
+}
+
+// daySlice is the part of a work interval that falls on one local day.
+type daySlice struct {
+    Date     string
+    Duration time.Duration
+}
+
+// splitByDay cuts [start, end) at every local midnight in loc. Days are
+// found with time.Date, so days of 23 or 25 hours around DST changes are
+// split correctly.
+func splitByDay(start, end time.Time, loc *time.Location) []daySlice {
+    var parts []daySlice
+    start, end = start.In(loc), end.In(loc)
+    for start.Before(end) {
+        y, m, d := start.Date()
+        next := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+        if next.After(end) {
+            next = end
+        }
+        parts = append(parts, daySlice{Date: start.Format("2006-01-02"), Duration: next.Sub(start)})
+        start = next
+    }
+    return parts
+}
+
+// timesheetTask is one task with its developer and full history.
+type timesheetTask struct {
+    TaskID        uuid.UUID
+    TaskName      string
+    DeveloperID   uuid.UUID
+    DeveloperName string
+    Rows          []TaskHistory
+}
+
+// buildTimesheet aggregates the work intervals of tasks that overlap
+// [from, to) by developer, task and local day. Intervals still open are
+// counted until end, which is the earlier of to and the current time.
+func buildTimesheet(tasks []timesheetTask, from, to, end time.Time, loc *time.Location) ([]TimesheetEntry, []DeveloperTotal) {
+    type key struct {
+        developer, task uuid.UUID
+        date            string
+    }
+    sums := map[key]time.Duration{}
+    totals := map[uuid.UUID]time.Duration{}
+    names := map[uuid.UUID]string{}
+    taskByID := map[uuid.UUID]timesheetTask{}
+
+    for _, task := range tasks {
+        taskByID[task.TaskID] = task
+        names[task.DeveloperID] = task.DeveloperName
+
+        timeline := buildTimeline(task.TaskID, task.Rows, end)
+        intervals := timeline.Intervals
+        if timeline.OpenInterval != nil {
+            intervals = append(intervals, *timeline.OpenInterval)
+        }
+        for _, w := range intervals {
+            start, stop := w.Start, end
+            if w.End != nil {
+                stop = *w.End
+            }
+            if start.Before(from) {
+                start = from
+            }
+            if stop.After(to) {
+                stop = to
+            }
+            for _, s := range splitByDay(start, stop, loc) {
+                sums[key{task.DeveloperID, task.TaskID, s.Date}] += s.Duration
+                totals[task.DeveloperID] += s.Duration
+            }
+        }
+    }
+
+    entries := make([]TimesheetEntry, 0, len(sums))
+    for k, d := range sums {
+        task := taskByID[k.task]
+        entries = append(entries, TimesheetEntry{
+            DeveloperID:   k.developer,
+            DeveloperName: task.DeveloperName,
+            TaskID:        k.task,
+            TaskName:      task.TaskName,
+            Date:          k.date,
+            Seconds:       d.Seconds(),
+            Hours:         hours(d),
+        })
+    }
+    sort.Slice(entries, func(i, j int) bool {
+        a, b := entries[i], entries[j]
+        if a.DeveloperName != b.DeveloperName {
+            return a.DeveloperName < b.DeveloperName
+        }
+        if a.Date != b.Date {
+            return a.Date < b.Date
+        }
+        return a.TaskName < b.TaskName
+    })
+
+    developerTotals := make([]DeveloperTotal, 0, len(totals))
+    for id, d := range totals {
+        developerTotals = append(developerTotals, DeveloperTotal{
+            DeveloperID:   id,
+            DeveloperName: names[id],
+            Seconds:       d.Seconds(),
+            Hours:         hours(d),
+        })
+    }
+    sort.Slice(developerTotals, func(i, j int) bool {
+        return developerTotals[i].DeveloperName < developerTotals[j].DeveloperName
+    })
+    return entries, developerTotals
+}
+
+// hours rounds d to hundredths of an hour for display.
+func hours(d time.Duration) float64 {
+    return math.Round(d.Hours()*100) / 100
+}
+
+type timesheetQuery struct {
+    Location     *time.Location
+    From, To     time.Time
+    DeveloperIDs []uuid.UUID
+    TaskIDs      []uuid.UUID
+    CSV          bool
+}
+
+// parseDateIn parses a YYYY-MM-DD date as local midnight in loc.
+func parseDateIn(value string, loc *time.Location) (time.Time, error) {
+    return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+func parseUUIDList(c *gin.Context, v *apierror.Validation, field string) []uuid.UUID {
+    var ids []uuid.UUID
+    for _, raw := range queryList(c, field) {
+        id, err := uuid.Parse(raw)
+        if err != nil {
+            v.Add(field, "not a valid UUID: "+raw)
+            continue
+        }
+        ids = append(ids, id)
+    }
+    return ids
+}
+
+func parseTimesheetQuery(c *gin.Context) (timesheetQuery, *apierror.Error) {
+    var v apierror.Validation
+    q := timesheetQuery{Location: time.UTC}
+
+    if tz := c.Query("tz"); tz != "" {
+        loc, err := time.LoadLocation(tz)
+        if err != nil {
+            v.Add("tz", "unknown IANA time zone: "+tz)
+        } else {
+            q.Location = loc
+        }
+    }
+
+    // The default range is the current week, Monday to Sunday.
+    now := time.Now().In(q.Location)
+    y, m, d := now.Date()
+    weekday := (int(now.Weekday()) + 6) % 7
+    q.From = time.Date(y, m, d-weekday, 0, 0, 0, 0, q.Location)
+    q.To = q.From.AddDate(0, 0, 7)
+
+    datesValid := true
+    if from := c.Query("from"); from != "" {
+        t, err := parseDateIn(from, q.Location)
+        if err != nil {
+            v.Add("from", "use YYYY-MM-DD")
+            datesValid = false
+        }
+        q.From = t
+    }
+    if to := c.Query("to"); to != "" {
+        t, err := parseDateIn(to, q.Location)
+        if err != nil {
+            v.Add("to", "use YYYY-MM-DD")
+            datesValid = false
+        }
+        // to is inclusive, so the range ends at the following midnight.
+        q.To = t.AddDate(0, 0, 1)
+    }
+    if datesValid {
+        if !q.From.Before(q.To) {
+            v.Add("to", "must not be before from")
+        } else if q.To.Sub(q.From) > maxTimesheetDays*24*time.Hour+time.Hour {
+            v.Add("to", fmt.Sprintf("the range can cover at most %d days", maxTimesheetDays))
+        }
+    }
+
+    q.DeveloperIDs = parseUUIDList(c, &v, "developerId")
+    q.TaskIDs = parseUUIDList(c, &v, "taskId")
+
+    switch strings.ToLower(c.Query("format")) {
+    case "":
+        q.CSV = strings.Contains(c.GetHeader("Accept"), "text/csv")
+    case "csv":
+        q.CSV = true
+    case "json":
+    default:
+        v.Add("format", "use json or csv")
+    }
+
+    return q, v.Err("Invalid query parameters")
+}
+
+// loadTimesheetTasks returns the history in [q.From, q.To) of every task that
+// has a developer and matches the filters. Of the rows before q.From only a
+// task's last one is loaded, when it is Started: it is all that is needed to
+// know that the task was already running when the range starts.
+func loadTimesheetTasks(tx *gorm.DB, q timesheetQuery) ([]timesheetTask, error) {
+    type row struct {
+        ID            uuid.UUID
+        TaskID        uuid.UUID
+        ChangeDate    time.Time
+        NewStatus     string
+        TaskName      string
+        DeveloperID   uuid.UUID
+        DeveloperName string
+    }
+
+    lastBefore := tx.Table(`"TaskHistories"`).
+        Select(`DISTINCT ON ("TaskId") "Id", "NewStatus"`).
+        Where(`"ChangeDate" < ?`, q.From).
+        Order(`"TaskId"`).Order(`"ChangeDate" DESC`).Order(`"Id" DESC`)
+    if len(q.TaskIDs) > 0 {
+        lastBefore = lastBefore.Where(`"TaskId" IN ?`, q.TaskIDs)
+    }
+
+    query := tx.Table(`"TaskHistories" AS th`).
+        Select(`th."Id" AS id, th."TaskId" AS task_id, th."ChangeDate" AS change_date, th."NewStatus" AS new_status,
+            t."Name" AS task_name, t."DeveloperId" AS developer_id, u."Username" AS developer_name`).
+        Joins(`JOIN "Tasks" t ON t."Id" = th."TaskId"`).
+        Joins(`JOIN "Users" u ON u."Id" = t."DeveloperId"`).
+        Where(`((th."ChangeDate" >= ? AND th."ChangeDate" < ?) OR th."Id" IN (SELECT "Id" FROM (?) AS last WHERE "NewStatus" = ?))`,
+            q.From, q.To, lastBefore, StatusStarted)
+    if len(q.DeveloperIDs) > 0 {
+        query = query.Where(`t."DeveloperId" IN ?`, q.DeveloperIDs)
+    }
+    if len(q.TaskIDs) > 0 {
+        query = query.Where(`th."TaskId" IN ?`, q.TaskIDs)
+    }
+
+    var rows []row
+    if err := query.Order(`th."TaskId"`).Order(`th."ChangeDate"`).Order(`th."Id"`).Scan(&rows).Error; err != nil {
+        return nil, err
+    }
+
+    var tasks []timesheetTask
+    for _, r := range rows {
+        if len(tasks) == 0 || tasks[len(tasks)-1].TaskID != r.TaskID {
+            tasks = append(tasks, timesheetTask{
+                TaskID:        r.TaskID,
+                TaskName:      r.TaskName,
+                DeveloperID:   r.DeveloperID,
+                DeveloperName: r.DeveloperName,
+            })
+        }
+        task := &tasks[len(tasks)-1]
+        task.Rows = append(task.Rows, TaskHistory{
+            ID:         r.ID,
+            TaskId:     r.TaskID,
+            ChangeDate: r.ChangeDate,
+            NewStatus:  r.NewStatus,
+        })
+    }
+    return tasks, nil
+}
+
+// GET /api/taskHistories/timesheet?from={date}&to={date}&tz={IANA zone}&developerId={uuid,...}&taskId={uuid,...}&format=json|csv
+//
+// from and to are inclusive local dates in tz (UTC by default) and default
+// to the current week. Time is attributed to the task's current developer.
+// CSV is returned for format=csv or an Accept header asking for text/csv.
+func getTimesheet(c *gin.Context) {
+    q, apiErr := parseTimesheetQuery(c)
+    if apiErr != nil {
+        abortWithError(c, apiErr)
+        return
+    }
+
+    tasks, err := loadTimesheetTasks(db.WithContext(c.Request.Context()), q)
+    if err != nil {
+        abortWithError(c, apierror.Internal("Failed to retrieve task histories", err))
+        return
+    }
+
+    end := time.Now()
+    if q.To.Before(end) {
+        end = q.To
+    }
+    entries, totals := buildTimesheet(tasks, q.From, q.To, end, q.Location)
+
+    from := q.From.Format("2006-01-02")
+    to := q.To.AddDate(0, 0, -1).Format("2006-01-02")
+
+    if q.CSV {
+        writeTimesheetCSV(c, from, to, entries)
+        return
+    }
+
+    c.JSON(200, ApiResponse{
+        Message: "Timesheet retrieved successfully",
+        Data: Timesheet{
+            From:     from,
+            To:       to,
+            Timezone: q.Location.String(),
+            Entries:  entries,
+            Totals:   totals,
+        },
+    })
+}
+
+func writeTimesheetCSV(c *gin.Context, from, to string, entries []TimesheetEntry) {
+    c.Header("Content-Type", "text/csv; charset=utf-8")
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="timesheet_%s_%s.csv"`, from, to))
+    c.Status(200)
+
+    w := csv.NewWriter(c.Writer)
+    _ = w.Write([]string{"developer_id", "developer_name", "task_id", "task_name", "date", "hours", "seconds"})
+    for _, e := range entries {
+        _ = w.Write([]string{
+            e.DeveloperID.String(),
+            csvcell.Text(e.DeveloperName),
+            e.TaskID.String(),
+            csvcell.Text(e.TaskName),
+            e.Date,
+            strconv.FormatFloat(e.Hours, 'f', 2, 64),
+            strconv.FormatFloat(e.Seconds, 'f', -1, 64),
+        })
+    }
+    w.Flush()
+    if err := w.Error(); err != nil {
+        c.Error(err)
+    }
+}
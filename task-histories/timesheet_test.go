@@ -0,0 +1,86 @@
+package main
+
+import (
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+)
+
+func TestSplitByDayAcrossMidnightAndDST(t *testing.T) {
+    warsaw, err := time.LoadLocation("Europe/Warsaw")
+    if err != nil {
+        t.Skip("time zone data not available:", err)
+    }
+
+    // 2025-03-30 has 23 hours in Warsaw.
+    start := time.Date(2025, 3, 29, 22, 0, 0, 0, warsaw)
+    end := time.Date(2025, 3, 31, 1, 0, 0, 0, warsaw)
+    got := splitByDay(start, end, warsaw)
+
+    want := []daySlice{
+        {"2025-03-29", 2 * time.Hour},
+        {"2025-03-30", 23 * time.Hour},
+        {"2025-03-31", time.Hour},
+    }
+    if len(got) != len(want) {
+        t.Fatalf("splitByDay = %v, want %v", got, want)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Errorf("slice %d = %v, want %v", i, got[i], want[i])
+        }
+    }
+}
+
+func TestBuildTimesheetClipsToRange(t *testing.T) {
+    developer := uuid.New()
+    task := timesheetTask{
+        TaskID:        uuid.New(),
+        TaskName:      "Task 1",
+        DeveloperID:   developer,
+        DeveloperName: "dev",
+        // Started the evening before the range and still running.
+        Rows: history(uuid.Nil, -120, StatusStarted),
+    }
+    from := at(0)
+    to := from.AddDate(0, 0, 1)
+    end := at(90)
+
+    entries, totals := buildTimesheet([]timesheetTask{task}, from, to, end, time.UTC)
+
+    if len(entries) != 1 || entries[0].Seconds != 5400 || entries[0].Hours != 1.5 {
+        t.Fatalf("entries = %+v, want one entry of 1.5h", entries)
+    }
+    if entries[0].Date != "2025-01-06" {
+        t.Errorf("Date = %q, want 2025-01-06", entries[0].Date)
+    }
+    if len(totals) != 1 || totals[0].DeveloperID != developer || totals[0].Seconds != 5400 {
+        t.Errorf("totals = %+v", totals)
+    }
+}
+
+func TestWriteTimesheetCSVEscapesFormulas(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+
+    writeTimesheetCSV(c, "2025-03-03", "2025-03-10", []TimesheetEntry{{
+        DeveloperName: "@dev",
+        TaskName:      "=HYPERLINK(\"http://x\")",
+        Date:          "2025-03-03",
+        Seconds:       3600,
+        Hours:         1,
+    }})
+
+    lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+    if len(lines) != 2 {
+        t.Fatalf("got %d lines, want 2: %q", len(lines), w.Body.String())
+    }
+    if !strings.Contains(lines[1], ",'@dev,") || !strings.Contains(lines[1], `"'=HYPERLINK(""http://x"")"`) {
+        t.Errorf("names not escaped: %s", lines[1])
+    }
+}
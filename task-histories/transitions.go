@@ -0,0 +1,58 @@
+package main
+
+import (
+    "slices"
+    "strings"
+)
+
+// Task statuses as stored in "TaskStatuses"."Name" and in the OldStatus and
+// NewStatus columns.
+const (
+    StatusCreated   = "Created"
+    StatusAssigned  = "Assigned"
+    StatusStarted   = "Started"
+    StatusPaused    = "Paused"
+    StatusStopped   = "Stopped"
+    StatusCompleted = "Completed"
+)
+
+// transitions lists the statuses a task may move to from each status. A
+// status missing from the map (Completed) is final. The duration math in
+// GetManagerTasksWithDetails relies on every Started row being closed by a
+// Paused, Stopped or Completed row before the next Started one.
+var transitions = map[string][]string{
+    StatusCreated:  {StatusAssigned},
+    StatusAssigned: {StatusStarted},
+    StatusStarted:  {StatusPaused, StatusStopped, StatusCompleted},
+    StatusPaused:   {StatusStarted, StatusStopped, StatusCompleted},
+    StatusStopped:  {StatusStarted, StatusCompleted},
+}
+
+var knownStatuses = []string{
+    StatusCreated, StatusAssigned, StatusStarted, StatusPaused, StatusStopped, StatusCompleted,
+}
+
+// canonicalStatus returns the stored spelling of status, matched case
+// insensitively, and false for an unknown status.
+func canonicalStatus(status string) (string, bool) {
+    for _, s := range knownStatuses {
+        if strings.EqualFold(s, status) {
+            return s, true
+        }
+    }
+    return "", false
+}
+
+// allowedTransitions returns the statuses reachable from current. An empty
+// current means the task has no history yet, and only Created can be
+// recorded.
+func allowedTransitions(current string) []string {
+    if current == "" {
+        return []string{StatusCreated}
+    }
+    return transitions[current]
+}
+
+func canTransition(current, next string) bool {
+    return slices.Contains(allowedTransitions(current), next)
+}